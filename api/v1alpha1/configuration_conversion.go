@@ -0,0 +1,183 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"golab.io/kubedredger/api/v1alpha2"
+)
+
+const (
+	// StatusAnnotation preserves the v1alpha2 status fields which v1alpha1 cannot represent,
+	// so converting back to v1alpha2 is lossless. It is managed by the conversion.
+	StatusAnnotation = "workshop.golab.io/v1alpha2-status"
+	// SpecAnnotation preserves the v1alpha2 spec fields which v1alpha1 cannot represent,
+	// like StatusAnnotation does for the status. It is managed by the conversion.
+	SpecAnnotation = "workshop.golab.io/v1alpha2-spec"
+)
+
+// hubSpec holds the fields of the v1alpha2 spec missing from v1alpha1.
+type hubSpec struct {
+	Rollout  *v1alpha2.RolloutStrategy     `json:"rollout,omitempty"`
+	Schedule *v1alpha2.MaintenanceSchedule `json:"schedule,omitempty"`
+}
+
+// hubStatus holds the fields of the v1alpha2 status missing from v1alpha1.
+type hubStatus struct {
+	ContentHash string `json:"contentHash,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	TargetNodes int32  `json:"targetNodes,omitempty"`
+
+	Nodes   []v1alpha2.NodeStatus   `json:"nodes,omitempty"`
+	Rollout *v1alpha2.RolloutStatus `json:"rollout,omitempty"`
+	DryRun  *v1alpha2.DryRunStatus  `json:"dryRun,omitempty"`
+}
+
+// ConvertTo converts this Configuration to the hub version (v1alpha2).
+func (src *Configuration) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha2.Configuration)
+	if !ok {
+		return fmt.Errorf("unsupported conversion to %T", dstRaw)
+	}
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = v1alpha2.ConfigurationSpec{
+		Filename:   spec.Filename,
+		Content:    spec.Content,
+		Create:     spec.Create,
+		Permission: spec.Permission,
+		MaxSize:    spec.MaxSize,
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = v1alpha2.ConfigurationStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		LastUpdated:        status.LastUpdated,
+		FileExists:         status.FileExists,
+		// v1alpha2 never mirrors the full content, but this is all we have
+		ContentPreview: status.Content,
+		Conditions:     status.Conditions,
+	}
+
+	var hs hubSpec
+	if err := popAnnotation(dst, SpecAnnotation, &hs); err != nil {
+		return err
+	}
+	dst.Spec.Rollout = hs.Rollout
+	dst.Spec.Schedule = hs.Schedule
+
+	var hst hubStatus
+	if err := popAnnotation(dst, StatusAnnotation, &hst); err != nil {
+		return err
+	}
+	dst.Status.ContentHash = hst.ContentHash
+	dst.Status.Size = hst.Size
+	dst.Status.Mode = hst.Mode
+	dst.Status.Owner = hst.Owner
+	dst.Status.TargetNodes = hst.TargetNodes
+	dst.Status.Nodes = hst.Nodes
+	dst.Status.Rollout = hst.Rollout
+	dst.Status.DryRun = hst.DryRun
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1alpha2) to this version.
+func (dst *Configuration) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha2.Configuration)
+	if !ok {
+		return fmt.Errorf("unsupported conversion from %T", srcRaw)
+	}
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = ConfigurationSpec{
+		Filename:   spec.Filename,
+		Content:    spec.Content,
+		Create:     spec.Create,
+		Permission: spec.Permission,
+		MaxSize:    spec.MaxSize,
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = ConfigurationStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		LastUpdated:        status.LastUpdated,
+		FileExists:         status.FileExists,
+		Content:            status.ContentPreview,
+		Conditions:         status.Conditions,
+	}
+
+	if err := pushAnnotation(dst, SpecAnnotation, hubSpec{
+		Rollout:  spec.Rollout,
+		Schedule: spec.Schedule,
+	}); err != nil {
+		return err
+	}
+	return pushAnnotation(dst, StatusAnnotation, hubStatus{
+		ContentHash: status.ContentHash,
+		Size:        status.Size,
+		Mode:        status.Mode,
+		Owner:       status.Owner,
+		TargetNodes: status.TargetNodes,
+		Nodes:       status.Nodes,
+		Rollout:     status.Rollout,
+		DryRun:      status.DryRun,
+	})
+}
+
+// popAnnotation decodes the given annotation of the object, if any, into v, and removes it.
+func popAnnotation(obj metav1.Object, key string, v any) error {
+	annotations := obj.GetAnnotations()
+	data, ok := annotations[key]
+	if !ok {
+		return nil
+	}
+	delete(annotations, key)
+	obj.SetAnnotations(annotations)
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("malformed annotation %q: %w", key, err)
+	}
+	return nil
+}
+
+// pushAnnotation encodes v into the given annotation of the object, unless there is nothing to preserve.
+func pushAnnotation(obj metav1.Object, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if string(data) == "{}" {
+		return nil
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = string(data)
+	obj.SetAnnotations(annotations)
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/randfill"
+
+	"golab.io/kubedredger/api/v1alpha2"
+)
+
+func FuzzConversionSpokeHubSpoke(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		src := &Configuration{}
+		newFiller(data).Fill(src)
+
+		hub := &v1alpha2.Configuration{}
+		if err := src.ConvertTo(hub); err != nil {
+			t.Fatalf("unexpected conversion error to hub: %v", err)
+		}
+		got := &Configuration{}
+		if err := got.ConvertFrom(hub); err != nil {
+			t.Fatalf("unexpected conversion error from hub: %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(src, got) {
+			t.Fatalf("round trip mismatch:\n%s", diff.ObjectReflectDiff(src, got))
+		}
+	})
+}
+
+func FuzzConversionHubSpokeHub(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		src := &v1alpha2.Configuration{}
+		newFiller(data).Fill(src)
+
+		spoke := &Configuration{}
+		if err := spoke.ConvertFrom(src); err != nil {
+			t.Fatalf("unexpected conversion error from hub: %v", err)
+		}
+		got := &v1alpha2.Configuration{}
+		if err := spoke.ConvertTo(got); err != nil {
+			t.Fatalf("unexpected conversion error to hub: %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(src, got) {
+			t.Fatalf("round trip mismatch:\n%s", diff.ObjectReflectDiff(src, got))
+		}
+	})
+}
+
+func TestConversionMalformedAnnotation(t *testing.T) {
+	src := &Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				StatusAnnotation: "{not json",
+			},
+		},
+	}
+	if err := src.ConvertTo(&v1alpha2.Configuration{}); err == nil {
+		t.Fatalf("malformed annotation converted")
+	}
+}
+
+func addSeeds(f *testing.F) {
+	f.Helper()
+	for _, seed := range []string{"", "kubedredger", "v1alpha1", "workshop.golab.io/v1alpha2-status", "workshop.golab.io/v1alpha2-spec"} {
+		f.Add([]byte(seed))
+	}
+}
+
+func newFiller(data []byte) *randfill.Filler {
+	return randfill.NewFromGoFuzz(data).NilChance(0.3).NumElements(0, 3).Funcs(
+		// the conversion does not touch the type, it is set by the caller
+		func(obj *metav1.TypeMeta, c randfill.Continue) {
+			*obj = metav1.TypeMeta{}
+		},
+		// the annotations are reserved to the conversion itself
+		func(obj *metav1.ObjectMeta, c randfill.Continue) {
+			c.FillNoCustom(obj)
+			delete(obj.Annotations, StatusAnnotation)
+			delete(obj.Annotations, SpecAnnotation)
+		},
+	)
+}
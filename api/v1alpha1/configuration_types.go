@@ -34,10 +34,20 @@ type ConfigurationSpec struct {
 	// Permission is the UNIX permission octal bit mask (example: 0644) the file should have
 	// +optional
 	Permission *uint32 `json:"permission,omitempty"`
+
+	// MaxSize is the maximum size in bytes of the content. It can only lower
+	// the limit enforced by the node agent, never raise it.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSize *int64 `json:"maxSize,omitempty"`
 }
 
 // ConfigurationStatus defines the observed state of Configuration.
 type ConfigurationStatus struct {
+	// ObservedGeneration is the generation of the spec the status refers to
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// LastUpdated is the last time the configuration was updated
 	LastUpdated metav1.Time `json:"lastUpdated"`
 
@@ -56,9 +66,11 @@ type ConfigurationStatus struct {
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
-// +kubebuilder:printcolumn:name="Path",type="string",JSONPath=".spec.path",description="Path of the file"
+// +kubebuilder:printcolumn:name="Filename",type="string",JSONPath=".spec.filename",description="Name of the file within the root"
 // +kubebuilder:printcolumn:name="Exists",type="boolean",JSONPath=".status.fileExists",description="Tells if the file exists"
+// +kubebuilder:printcolumn:name="Available",type="string",JSONPath=".status.conditions[?(@.type==\"Available\")].status",description="Tells if the file is up to date"
 // +kubebuilder:printcolumn:name="LastUpdate",type="date",JSONPath=".status.lastUpdated",description="Last update of the file"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Configuration is the Schema for the configurations API
 type Configuration struct {
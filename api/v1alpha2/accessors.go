@@ -0,0 +1,37 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+// GetSpec returns the spec of the object, shared by all the kinds describing a configuration file.
+func (in *Configuration) GetSpec() *ConfigurationSpec {
+	return &in.Spec
+}
+
+// GetStatus returns the status of the object, shared by all the kinds describing a configuration file.
+func (in *Configuration) GetStatus() *ConfigurationStatus {
+	return &in.Status
+}
+
+// GetSpec returns the spec of the object, shared by all the kinds describing a configuration file.
+func (in *ClusterConfiguration) GetSpec() *ConfigurationSpec {
+	return &in.Spec
+}
+
+// GetStatus returns the status of the object, shared by all the kinds describing a configuration file.
+func (in *ClusterConfiguration) GetStatus() *ConfigurationStatus {
+	return &in.Status
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Filename",type="string",JSONPath=".spec.filename",description="Name of the file within the root"
+// +kubebuilder:printcolumn:name="Available",type="string",JSONPath=".status.conditions[?(@.type==\"Available\")].status",description="Tells if the file is up to date"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].reason",description="Reason of the Degraded condition"
+// +kubebuilder:printcolumn:name="Nodes",type="integer",JSONPath=".status.targetNodes",description="Number of nodes the file is applied on"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Hash",type="string",JSONPath=".status.contentHash",description="Hash of the content of the file",priority=1
+// +kubebuilder:printcolumn:name="Permission",type="string",JSONPath=".status.mode",description="Permission bits of the file",priority=1
+
+// ClusterConfiguration is the Schema for the clusterconfigurations API.
+// Unlike Configuration, its files live directly in the configuration root,
+// so it is meant for the node-wide files owned by the platform.
+type ClusterConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of ClusterConfiguration
+	// +required
+	Spec ConfigurationSpec `json:"spec"`
+
+	// status defines the observed state of ClusterConfiguration
+	// +optional
+	Status ConfigurationStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterConfigurationList contains a list of ClusterConfiguration
+type ClusterConfigurationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []ClusterConfiguration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterConfiguration{}, &ClusterConfigurationList{})
+}
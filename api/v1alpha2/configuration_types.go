@@ -0,0 +1,460 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// ResyncAnnotation requests the agents to rewrite the file, for example to restore it after
+	// an external change. Its value is the time of the request in RFC 3339 format: each agent rewrites
+	// the file once per value (see NodeStatus).
+	ResyncAnnotation = "workshop.golab.io/resync-requested-at"
+	// DryRunAnnotation, when set to "true", makes the agents only report the changes they would make
+	// to the file, without making them. The agents can also be run in dry run mode for all the objects.
+	DryRunAnnotation = "workshop.golab.io/dry-run"
+)
+
+// The types of the conditions reported in the status
+const (
+	ConditionAvailable   = "Available"
+	ConditionProgressing = "Progressing"
+	ConditionDegraded    = "Degraded"
+	// ConditionPolicyViolation is only reported while the spec violates the configuration policies
+	ConditionPolicyViolation = "PolicyViolation"
+	// ConditionPending is only reported while the changes to the file wait for a maintenance window
+	ConditionPending = "Pending"
+)
+
+// DryRunAction is the change the agent would make to the file
+// +kubebuilder:validation:Enum=Create;Update;Delete;None
+type DryRunAction string
+
+const (
+	DryRunActionCreate DryRunAction = "Create"
+	DryRunActionUpdate DryRunAction = "Update"
+	DryRunActionDelete DryRunAction = "Delete"
+	DryRunActionNone   DryRunAction = "None"
+)
+
+// ConfigurationSpec defines the desired state of Configuration
+type ConfigurationSpec struct {
+	// Filename is the full name of the configuration file within the root
+	Filename string `json:"filename"`
+
+	// Content is the content to be written to the file
+	Content string `json:"content"`
+
+	// Create indicates whether to create the file if it does not exist
+	Create bool `json:"create,omitempty"`
+
+	// Permission is the UNIX permission octal bit mask (example: 0644) the file should have
+	// +optional
+	Permission *uint32 `json:"permission,omitempty"`
+
+	// MaxSize is the maximum size in bytes of the content. It can only lower
+	// the limit enforced by the node agent, never raise it.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSize *int64 `json:"maxSize,omitempty"`
+
+	// Rollout paces the rollout of the changes across the nodes. If not set,
+	// all the nodes apply the changes as soon as they see them.
+	// +optional
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+
+	// Schedule restricts the changes of the file to the maintenance windows. If not set,
+	// the file can change at any time.
+	// +optional
+	Schedule *MaintenanceSchedule `json:"schedule,omitempty"`
+}
+
+// MaintenanceSchedule lists the maintenance windows the file can change in.
+// Outside the windows, the changes wait for the next window to start.
+// Deleting the object removes the file at any time.
+type MaintenanceSchedule struct {
+	// Windows are the maintenance windows: the file can change within any of them
+	// +kubebuilder:validation:MinItems=1
+	// +listType=atomic
+	Windows []MaintenanceWindow `json:"windows"`
+
+	// TimeZone is the IANA name of the time zone the windows start in (example: Europe/Rome).
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period of time.
+type MaintenanceWindow struct {
+	// Start is the cron expression of the start of the window: minute, hour, day of month,
+	// month and day of week (example: "0 2 * * sat,sun")
+	// +kubebuilder:validation:MinLength=1
+	Start string `json:"start"`
+
+	// Duration is how long the window lasts since its start
+	Duration metav1.Duration `json:"duration"`
+}
+
+// RolloutStrategy paces the rollout of the changes of the spec across the nodes.
+// The rollout controller allows the nodes to apply a new revision of the spec in batches.
+type RolloutStrategy struct {
+	// MaxUnavailable is the maximum number of nodes which can be applying the new revision,
+	// or failing to, at the same time. It can be a number or a percentage of the target nodes
+	// (example: 10%), rounded up. Defaults to 1.
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// BatchSize is the maximum number of nodes allowed to apply the new revision at each step. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	BatchSize *int32 `json:"batchSize,omitempty"`
+
+	// PauseBetweenBatches is the minimum time between allowing two batches of nodes
+	// +optional
+	PauseBetweenBatches *metav1.Duration `json:"pauseBetweenBatches,omitempty"`
+
+	// Paused stops the rollout: no more nodes are allowed to apply the new revision
+	// until it is unset. The nodes already allowed are not affected.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Canary makes the new revision reach a canary set of nodes first, and reach the
+	// other nodes only once the health gate passes on all the canaries.
+	// If the gate fails, the canaries revert the file to the version they had before, which
+	// the agents keep only in memory: the canaries whose agent restarted since keep the
+	// revision and report the error in their entry of the status.
+	// +optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+}
+
+// CanaryStrategy describes the canary step of a rollout.
+// If the health gate fails, the rollout is aborted and the canaries revert the file
+// to the version they had before. The reverts don't survive a restart of the agents,
+// which keep the previous versions only in memory.
+type CanaryStrategy struct {
+	// Nodes is the number of the canary nodes. It can be a number or a percentage
+	// of the target nodes (example: 10%), rounded up. Defaults to 1.
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	Nodes *intstr.IntOrString `json:"nodes,omitempty"`
+
+	// HealthGate must pass on all the canary nodes for the rollout to continue
+	HealthGate HealthGate `json:"healthGate"`
+}
+
+// HealthGate describes how the health of a node is evaluated once it applied a revision.
+// At least one of the checks must be set; all the checks set must pass.
+// The Configurations can only check what their namespace owns (see HTTPGet and PodReadiness).
+type HealthGate struct {
+	// HTTPGet probes an endpoint on the node.
+	// Configurations can use it only if a ConfigurationPolicy selecting their namespace allows it.
+	// +optional
+	HTTPGet *HTTPGetGate `json:"httpGet,omitempty"`
+
+	// PodReadiness requires the selected pods running on the node to be ready
+	// +optional
+	PodReadiness *PodReadinessGate `json:"podReadiness,omitempty"`
+
+	// InitialDelay is the time to wait after the canaries applied the revision before evaluating the gate
+	// +optional
+	InitialDelay *metav1.Duration `json:"initialDelay,omitempty"`
+
+	// Period is the time between two evaluations of the gate. Defaults to 10s.
+	// +optional
+	Period *metav1.Duration `json:"period,omitempty"`
+
+	// Timeout is how long the canaries can take to apply the revision and pass the gate
+	// before the rollout is aborted. Defaults to 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HTTPGetGate probes an HTTP endpoint on the internal IP address of the node.
+// Any status code between 200 and 399 is a success, like for the kubelet probes.
+type HTTPGetGate struct {
+	// Path is the path of the endpoint
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Port is the port of the endpoint
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Scheme is the scheme of the endpoint. The certificate of HTTPS endpoints is not verified. Defaults to HTTP.
+	// +kubebuilder:validation:Enum=HTTP;HTTPS
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// PodReadinessGate requires the selected pods running on the node to be ready.
+// At least one pod must be selected on the node.
+type PodReadinessGate struct {
+	// Namespace is the namespace of the pods.
+	// For Configurations, it must be the namespace of the object.
+	Namespace string `json:"namespace"`
+
+	// Selector selects the pods within the namespace
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
+// RolloutPhase is the phase of the rollout of a revision of the spec
+// +kubebuilder:validation:Enum=Canary;Progressing;Paused;Completed;Aborted
+type RolloutPhase string
+
+const (
+	// RolloutPhaseCanary is reported while the canaries apply the revision and the health gate is evaluated
+	RolloutPhaseCanary      RolloutPhase = "Canary"
+	RolloutPhaseProgressing RolloutPhase = "Progressing"
+	RolloutPhasePaused      RolloutPhase = "Paused"
+	RolloutPhaseCompleted   RolloutPhase = "Completed"
+	// RolloutPhaseAborted is reported once the health gate failed. It lasts until the spec changes.
+	RolloutPhaseAborted RolloutPhase = "Aborted"
+)
+
+// ConfigurationStatus defines the observed state of Configuration.
+// Unlike v1alpha1, the content of the file is not mirrored in the status:
+// the status reports its hash and, optionally, a truncated preview.
+// When the agents run on the nodes, the state of the file on each node is reported only in Nodes:
+// the other fields report the file only while it has the same content on all the nodes, and
+// the conditions summarize the state of all the nodes.
+type ConfigurationStatus struct {
+	// ObservedGeneration is the generation of the spec the status refers to
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastUpdated is the last time the configuration was updated
+	LastUpdated metav1.Time `json:"lastUpdated"`
+
+	// FileExists indicates whether the file exists at the specified path
+	FileExists bool `json:"fileExists,omitempty"`
+
+	// ContentHash is the hash of the content of the file. It is the same value
+	// reported in the node labels, and it can only be compared for equality.
+	// +optional
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// Size is the size in bytes of the file
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// Mode is the octal UNIX permission bit mask (example: 0644) the file has
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Owner is the owner of the file as "uid:gid", if known
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// TargetNodes is the number of nodes the configuration is applied on: the nodes
+	// the rollout targets, if paced by the spec, otherwise the nodes reporting the state of the file.
+	// +optional
+	TargetNodes int32 `json:"targetNodes,omitempty"`
+
+	// ContentPreview is the beginning of the content of the file, if enabled in the agent
+	// +optional
+	ContentPreview string `json:"contentPreview,omitempty"`
+
+	// Nodes reports the state of the file on each node
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Nodes []NodeStatus `json:"nodes,omitempty"`
+
+	// Rollout reports the progress of the rollout, if paced by the spec
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// DryRun reports the changes the agent would make to the file, if running in dry run mode
+	// and not on a node (see NodeStatus)
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// The status of each condition is one of True, False, or Unknown.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NodeStatus reports the state of the file on a node. It is updated by the agent running on the node.
+type NodeStatus struct {
+	// Name is the name of the node
+	Name string `json:"name"`
+
+	// ObservedGeneration is the generation of the spec the file was last synced to
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Revision is the revision of the spec the file was last synced to (see RolloutStatus)
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// ContentHash is the hash of the content of the file on the node
+	// +optional
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// Mode is the octal UNIX permission bit mask (example: 0644) the file has on the node
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Pending is the reason the node does not apply the revision of the spec yet, if it waits:
+	// RolloutPending, RolloutAborted, OutsideMaintenanceWindow or InvalidSchedule
+	// +optional
+	Pending string `json:"pending,omitempty"`
+
+	// DryRun reports the changes the agent would make to the file on the node, if running in dry run mode
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// Error is the error met syncing the file, if the last sync failed
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// ResyncRequestedAt is the value of the resync annotation (see ResyncAnnotation) the agent
+	// on the node last honored by rewriting the file
+	// +optional
+	ResyncRequestedAt string `json:"resyncRequestedAt,omitempty"`
+}
+
+// RolloutStatus reports the progress of the rollout of a revision of the spec.
+// It is updated by the rollout controller.
+type RolloutStatus struct {
+	// Revision identifies the spec being rolled out. It is the hash of all the fields of the spec
+	// except the rollout strategy, so changing the strategy, like pausing, does not restart the rollout.
+	Revision string `json:"revision"`
+
+	// ObservedGeneration is the generation of the spec the rollout controller last observed
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase is the phase of the rollout
+	Phase RolloutPhase `json:"phase"`
+
+	// AllowedNodes are the nodes allowed to apply the revision being rolled out.
+	// Once the rollout completes, all the nodes are allowed and the list is cleared.
+	// +listType=set
+	// +optional
+	AllowedNodes []string `json:"allowedNodes,omitempty"`
+
+	// TargetNodes is the number of nodes the revision is rolled out to
+	// +optional
+	TargetNodes int32 `json:"targetNodes,omitempty"`
+
+	// UpdatedNodes is the number of nodes which synced the revision
+	// +optional
+	UpdatedNodes int32 `json:"updatedNodes,omitempty"`
+
+	// UnavailableNodes is the number of allowed nodes which did not sync the revision yet
+	// +optional
+	UnavailableNodes int32 `json:"unavailableNodes,omitempty"`
+
+	// LastBatchTime is the last time a batch of nodes was allowed
+	// +optional
+	LastBatchTime *metav1.Time `json:"lastBatchTime,omitempty"`
+
+	// Canary reports the progress of the canary step, if required by the spec
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// Message describes why the rollout is aborted, or why the health gate did not pass yet
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CanaryStatus reports the progress of the canary step of a rollout
+type CanaryStatus struct {
+	// Nodes are the canary nodes
+	// +listType=set
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+
+	// UpdatedTime is the time all the canaries applied the revision
+	// +optional
+	UpdatedTime *metav1.Time `json:"updatedTime,omitempty"`
+
+	// Promoted is true once the health gate passed
+	// +optional
+	Promoted bool `json:"promoted,omitempty"`
+}
+
+// DryRunStatus describes the changes the agent would make to the file
+type DryRunStatus struct {
+	// ObservedGeneration is the generation of the spec the changes refer to
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Action is the change the agent would make to the file
+	Action DryRunAction `json:"action"`
+
+	// Diff is the unified diff between the current content of the file and the desired one.
+	// It is truncated if too long.
+	// +optional
+	Diff string `json:"diff,omitempty"`
+
+	// ModeChange is the change of the permission bits the agent would make (example: 0644 -> 0600)
+	// +optional
+	ModeChange string `json:"modeChange,omitempty"`
+
+	// Error is the error the agent would meet making the changes
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Filename",type="string",JSONPath=".spec.filename",description="Name of the file within the root"
+// +kubebuilder:printcolumn:name="Available",type="string",JSONPath=".status.conditions[?(@.type==\"Available\")].status",description="Tells if the file is up to date"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].reason",description="Reason of the Degraded condition"
+// +kubebuilder:printcolumn:name="Nodes",type="integer",JSONPath=".status.targetNodes",description="Number of nodes the file is applied on"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Hash",type="string",JSONPath=".status.contentHash",description="Hash of the content of the file",priority=1
+// +kubebuilder:printcolumn:name="Permission",type="string",JSONPath=".status.mode",description="Permission bits of the file",priority=1
+
+// Configuration is the Schema for the configurations API
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of Configuration
+	// +required
+	Spec ConfigurationSpec `json:"spec"`
+
+	// status defines the observed state of Configuration
+	// +optional
+	Status ConfigurationStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ConfigurationList contains a list of Configuration
+type ConfigurationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []Configuration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Configuration{}, &ConfigurationList{})
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ContentFormat is a format the content of the configuration files can have
+// +kubebuilder:validation:Enum=JSON;YAML;Text
+type ContentFormat string
+
+const (
+	// ContentFormatJSON is a JSON document
+	ContentFormatJSON ContentFormat = "JSON"
+	// ContentFormatYAML is a YAML document. Note that JSON is valid YAML.
+	ContentFormatYAML ContentFormat = "YAML"
+	// ContentFormatText is UTF-8 text without NUL characters
+	ContentFormatText ContentFormat = "Text"
+)
+
+// ConfigurationPolicySpec defines which files the namespaces can write, and how
+type ConfigurationPolicySpec struct {
+	// Namespaces are the names of the namespaces the policy applies to
+	// +kubebuilder:validation:MinItems=1
+	Namespaces []string `json:"namespaces"`
+
+	// Paths are the glob patterns, in the path.Match syntax (example: "*.conf"), of the filenames
+	// the namespaces can write, relative to their own directory. Empty means no file can be written.
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+
+	// MaxPermission is the UNIX permission octal bit mask (example: 0644) the files can have at most:
+	// the files requesting any other bit, like the world-writable or the setuid ones, are rejected.
+	// Files not requesting a permission get 0644.
+	// +optional
+	MaxPermission *uint32 `json:"maxPermission,omitempty"`
+
+	// MaxSize is the maximum size in bytes of the content of the files
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSize *int64 `json:"maxSize,omitempty"`
+
+	// Formats are the formats the content of the files can have. Empty means any content.
+	// +optional
+	Formats []ContentFormat `json:"formats,omitempty"`
+
+	// AllowHTTPHealthGates allows the namespaces to use HTTP health gates in their canary rollouts.
+	// The rollout controller probes the nodes on their behalf, so they can reach any endpoint it can.
+	// +optional
+	AllowHTTPHealthGates bool `json:"allowHTTPHealthGates,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Namespaces",type="string",JSONPath=".spec.namespaces",description="Namespaces the policy applies to"
+// +kubebuilder:printcolumn:name="Paths",type="string",JSONPath=".spec.paths",description="Filenames the namespaces can write"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ConfigurationPolicy is the Schema for the configurationpolicies API.
+// The namespaces selected by at least one policy can only write the files matching
+// the paths of any of the policies selecting them, honoring the constraints of at least
+// one of the policies matching the file; the namespaces not selected by any policy
+// are not restricted. Only the namespaces selected by a policy allowing them can use
+// HTTP health gates.
+type ConfigurationPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the files the namespaces can write
+	// +required
+	Spec ConfigurationPolicySpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// ConfigurationPolicyList contains a list of ConfigurationPolicy
+type ConfigurationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []ConfigurationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConfigurationPolicy{}, &ConfigurationPolicyList{})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/offline"
+)
+
+const applyUsage = `Usage: kubedredger apply --from-dir DIR [flags]
+
+Applies the Configuration and ClusterConfiguration manifests found in DIR to the
+configuration root, without connecting to the API server. The ConfigurationPolicy
+manifests found in DIR constrain the Configurations like they do in the cluster.
+Exits with a non-zero status if any of the manifests can't be applied.
+
+Flags:
+`
+
+// runApply runs the apply command and returns the exit status:
+// 0 on success, 1 if any manifest can't be applied, 2 on invalid usage.
+func runApply(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(fs.Output(), applyUsage)
+		fs.PrintDefaults()
+	}
+	var fromDir string
+	var files fileFlags
+	fs.StringVar(&fromDir, "from-dir", "", "The directory holding the manifests to apply.")
+	files.bind(fs)
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fromDir == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	lh := zap.New(zap.UseFlagOptions(&opts)).WithName("apply")
+
+	managerOpts, err := files.managerOptions()
+	if err != nil {
+		lh.Error(err, "invalid file lock mode")
+		return 2
+	}
+	validationOpts, err := files.validationOptions()
+	if err != nil {
+		lh.Error(err, "invalid maximum permission")
+		return 2
+	}
+
+	mf, err := offline.LoadDir(fromDir)
+	if err != nil {
+		lh.Error(err, "unable to load the manifests", "dir", fromDir)
+		return 1
+	}
+
+	confMgr := configfile.NewManagerWithOptions(files.configurationRoot, managerOpts)
+	if err := confMgr.CleanStaleTempFiles(lh); err != nil {
+		lh.Error(err, "unable to clean the stale temporary files")
+		return 1
+	}
+
+	results := offline.Apply(lh, confMgr, mf, offline.Options{
+		Validation:        validationOpts,
+		AllowedNamespaces: files.namespaces(),
+	})
+	if err := offline.WriteResults(out, results); err != nil {
+		lh.Error(err, "unable to write the results")
+		return 1
+	}
+	if offline.Failed(results) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+)
+
+// fileFlags tune how the configuration files are validated and written.
+// They are shared by the agent and the apply command.
+type fileFlags struct {
+	configurationRoot          string
+	fileLockMode               string
+	fileLockTimeout            time.Duration
+	maxFileSize, maxRootSize   int64
+	minFreeSpace               uint64
+	allowedNamespaces          string
+	allowSpecialPermissionBits bool
+	maxPermission              string
+	historySize                int
+}
+
+func (ff *fileFlags) bind(fs *flag.FlagSet) {
+	fs.StringVar(&ff.configurationRoot, "configuration-root", "/tmp/config.d", "The configuration file root (directory)")
+	fs.StringVar(&ff.fileLockMode, "file-lock", string(configfile.LockNone),
+		"The advisory lock to hold on the sidecar lock file while updating a configuration file. "+
+			"One of: none, flock, fcntl. Unless none, the filenames ending in "+configfile.LockSuffix+" are reserved.")
+	fs.DurationVar(&ff.fileLockTimeout, "file-lock-timeout", configfile.DefaultLockTimeout,
+		"How long to wait for the advisory lock before reporting a failure.")
+	fs.Int64Var(&ff.maxFileSize, "max-file-size", 1024*1024,
+		"The maximum size in bytes of each configuration file. Use 0 for no limit.")
+	fs.Int64Var(&ff.maxRootSize, "max-root-size", 0,
+		"The maximum size in bytes of all the configuration files in the root. Use 0 for no limit.")
+	fs.Uint64Var(&ff.minFreeSpace, "min-free-space", 16*1024*1024,
+		"The bytes which must be left free on the filesystem holding the configuration root.")
+	fs.StringVar(&ff.allowedNamespaces, "allowed-namespaces", "",
+		"Comma-separated list of the only namespaces whose Configurations can write files. Empty allows all namespaces.")
+	fs.BoolVar(&ff.allowSpecialPermissionBits, "allow-special-permission-bits", false,
+		"If set, the configuration files can have the setuid, setgid and sticky permission bits.")
+	fs.StringVar(&ff.maxPermission, "max-permission", fmt.Sprintf("%04o", validate.DefaultMaxPermission),
+		"The octal UNIX permission bit mask the configuration files can have at most. "+
+			"The existing configurations requesting more are not synced anymore, and reported as degraded.")
+	fs.IntVar(&ff.historySize, "history-size", configfile.DefaultHistorySize,
+		"The number of previous versions of each configuration file kept in memory to revert aborted rollouts. "+
+			"Use a negative value to disable the history.")
+}
+
+func (ff *fileFlags) namespaces() []string {
+	if ff.allowedNamespaces == "" {
+		return nil
+	}
+	var res []string
+	for _, namespace := range strings.Split(ff.allowedNamespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			res = append(res, namespace)
+		}
+	}
+	return res
+}
+
+func (ff *fileFlags) validationOptions() (validate.Options, error) {
+	maxPerm, err := strconv.ParseUint(ff.maxPermission, 8, 32)
+	if err != nil || maxPerm == 0 || maxPerm > 0777 {
+		return validate.Options{}, fmt.Errorf("invalid maximum permission %q", ff.maxPermission)
+	}
+	lockMode, err := configfile.ParseLockMode(ff.fileLockMode)
+	if err != nil {
+		return validate.Options{}, err
+	}
+	return validate.Options{
+		AllowSpecialBits: ff.allowSpecialPermissionBits,
+		MaxPermission:    uint32(maxPerm),
+		LockFiles:        lockMode != configfile.LockNone,
+	}, nil
+}
+
+func (ff *fileFlags) managerOptions() (configfile.Options, error) {
+	lockMode, err := configfile.ParseLockMode(ff.fileLockMode)
+	if err != nil {
+		return configfile.Options{}, err
+	}
+	return configfile.Options{
+		LockMode:     lockMode,
+		LockTimeout:  ff.fileLockTimeout,
+		MaxFileSize:  ff.maxFileSize,
+		MaxRootSize:  ff.maxRootSize,
+		MinFreeSpace: ff.minFreeSpace,
+		HistorySize:  ff.historySize,
+	}, nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-dredger is a kubectl plugin to inspect the Configurations and the state of their files on the nodes.
+// Install it anywhere in the PATH, then run "kubectl dredger help".
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/kubectlplugin"
+)
+
+const usage = `Usage: kubectl dredger <command> [flags] [NAME]
+
+Commands:
+  list              list the Configurations and the state of their files on each node
+  diff NAME         show the differences between the desired content and the reported one
+  history NAME      show the generations and the events of a Configuration
+  resync NAME       request the agents to sync the file of a Configuration again
+
+Flags:
+`
+
+var errUsage = errors.New("invalid usage")
+
+// commandArgs maps the commands to the number of positional arguments they take
+var commandArgs = map[string]int{
+	"list":    0,
+	"diff":    1,
+	"history": 1,
+	"resync":  1,
+}
+
+type options struct {
+	kubeconfig    string
+	kubeContext   string
+	namespace     string
+	allNamespaces bool
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout)
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	opts := options{}
+	flags := flag.NewFlagSet("kubectl-dredger", flag.ContinueOnError)
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	flags.StringVar(&opts.kubeContext, "context", "", "The name of the kubeconfig context to use.")
+	flags.StringVar(&opts.namespace, "namespace", "", "The namespace of the Configurations. Defaults to the one of the context.")
+	flags.StringVar(&opts.namespace, "n", "", "Shorthand for --namespace.")
+	flags.BoolVar(&opts.allNamespaces, "all-namespaces", false, "List the Configurations in all the namespaces.")
+	flags.BoolVar(&opts.allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		flags.Usage()
+		return nil
+	}
+	command := args[0]
+	positional, err := parseInterspersed(flags, args[1:])
+	if err != nil {
+		return errUsage
+	}
+	expectedArgs, ok := commandArgs[command]
+	if !ok || len(positional) != expectedArgs {
+		flags.Usage()
+		return errUsage
+	}
+
+	cli, namespace, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	if opts.namespace != "" {
+		namespace = opts.namespace
+	}
+
+	if command == "list" {
+		return list(ctx, cli, namespace, opts.allNamespaces, out)
+	}
+
+	conf := &workshopv1alpha2.Configuration{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: positional[0]}, conf); err != nil {
+		return err
+	}
+
+	switch command {
+	case "diff":
+		nodes := corev1.NodeList{}
+		if err := cli.List(ctx, &nodes); err != nil {
+			return err
+		}
+		return kubectlplugin.WriteDiff(out, conf, nodes.Items)
+	case "history":
+		events := corev1.EventList{}
+		if err := cli.List(ctx, &events, client.InNamespace(conf.Namespace), kubectlplugin.EventsSelector(conf.UID)); err != nil {
+			return err
+		}
+		return kubectlplugin.WriteHistory(out, conf, events.Items)
+	case "resync":
+		if err := cli.Patch(ctx, conf, kubectlplugin.ResyncPatch(time.Now())); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(out, "configuration %s/%s resync requested\n", conf.Namespace, conf.Name)
+		return err
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func list(ctx context.Context, cli client.Client, namespace string, allNamespaces bool, out io.Writer) error {
+	listOpts := []client.ListOption{}
+	if !allNamespaces {
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	confs := workshopv1alpha2.ConfigurationList{}
+	if err := cli.List(ctx, &confs, listOpts...); err != nil {
+		return err
+	}
+	nodes := corev1.NodeList{}
+	if err := cli.List(ctx, &nodes); err != nil {
+		return err
+	}
+	return kubectlplugin.WriteList(out, confs.Items, nodes.Items)
+}
+
+// newClient creates the client like kubectl does, returning also the namespace of the current context.
+func newClient(opts options) (client.Client, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{
+		CurrentContext: opts.kubeContext,
+	})
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(workshopv1alpha2.AddToScheme(scheme))
+	cli, err := client.New(cfg, client.Options{Scheme: scheme})
+	return cli, namespace, err
+}
+
+// parseInterspersed parses the flags among the positional arguments, like kubectl allows,
+// and returns the positional arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
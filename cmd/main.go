@@ -18,6 +18,7 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"flag"
 	"os"
 
@@ -25,10 +26,14 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -36,8 +41,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
 	"golab.io/kubedredger/internal/configfile"
 	"golab.io/kubedredger/internal/controller"
+	"golab.io/kubedredger/internal/healthgate"
+	"golab.io/kubedredger/internal/nodelabel"
+	webhookv1alpha2 "golab.io/kubedredger/internal/webhook/v1alpha2"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -50,12 +59,25 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(workshopv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(workshopv1alpha2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
 // nolint:gocyclo
 func main() {
-	var configurationRoot string
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		os.Exit(runApply(os.Args[2:], os.Stdout))
+	}
+
+	var files fileFlags
+	var maxConcurrentReconciles int
+	var statusPreviewSize int
+	var dryRun bool
+	var enableAgent bool
+	var enableRollout bool
+	var rolloutNodeSelector string
+	var rolloutAgentSelector string
+	var nodeReadiness string
 	var metricsAddr string
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
@@ -64,7 +86,30 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
-	flag.StringVar(&configurationRoot, "configuration-root", "/tmp/config.d", "The configuration file root (directory)")
+	files.bind(flag.CommandLine)
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The maximum number of configurations which can be reconciled concurrently.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, the changes to the configuration files are only reported in the status and in the events, never made.")
+	flag.BoolVar(&enableAgent, "enable-agent", true,
+		"If set, this manager runs the agent, which syncs the configuration files of the node it runs on, "+
+			"named by the NODE_NAME environment variable. The agent must run on every node, like in a DaemonSet, "+
+			"so it can't use leader election.")
+	flag.BoolVar(&enableRollout, "enable-rollout-controller", false,
+		"If set, the rollout of the configurations setting a rollout strategy is paced by this manager. "+
+			"Must be enabled on exactly one manager in the cluster, separate from the agents, using leader election.")
+	flag.StringVar(&rolloutNodeSelector, "rollout-node-selector", "",
+		"The label selector of the nodes the configurations are rolled out to. If empty, all the nodes are.")
+	flag.StringVar(&rolloutAgentSelector, "rollout-agent-selector", "control-plane=agent,app.kubernetes.io/name=kubedredger",
+		"The label selector of the agent pods. The configurations are rolled out only to the nodes running an agent, "+
+			"looked up in the namespace named by the POD_NAMESPACE environment variable, or in all the namespaces if unset.")
+	flag.StringVar(&nodeReadiness, "node-readiness", "",
+		"How the agent reports on its node whether the files of all the configurations are in place. "+
+			"One of: taint (keeps the "+controller.ConfigNotReadyTaint+" taint until they are), "+
+			"condition (reports the "+string(controller.NodeConditionConfigurationsReady)+" node condition). "+
+			"Empty disables the report. Requires the agent (see --enable-agent), running on every node.")
+	flag.IntVar(&statusPreviewSize, "status-preview-size", 256,
+		"The maximum size in bytes of the content preview reported in the status. Use 0 to disable the preview.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -90,9 +135,18 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if enableAgent && enableLeaderElection {
+		setupLog.Error(errors.New("the agent runs on every node"), "leader election can't be enabled with the agent")
+		os.Exit(1)
+	}
+	if !enableAgent && !enableRollout {
+		setupLog.Error(errors.New("nothing to run"), "either the agent or the rollout controller must be enabled")
+		os.Exit(1)
+	}
+
 	var err error
 	nodeName := os.Getenv("NODE_NAME")
-	if nodeName == "" {
+	if enableAgent && nodeName == "" {
 		setupLog.Error(err, "unable to detect the name of the node")
 		os.Exit(1)
 	}
@@ -159,8 +213,32 @@ func main() {
 		metricsServerOptions.KeyName = metricsCertKey
 	}
 
+	nodeSelector, err := labels.Parse(rolloutNodeSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid rollout node selector")
+		os.Exit(1)
+	}
+
+	agentSelector, err := labels.Parse(rolloutAgentSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid rollout agent selector")
+		os.Exit(1)
+	}
+	agentNamespace := os.Getenv("POD_NAMESPACE")
+
+	cacheOpts := cache.Options{}
+	if enableRollout {
+		// only the agent pods are cached
+		podCache := cache.ByObject{Label: agentSelector}
+		if agentNamespace != "" {
+			podCache.Namespaces = map[string]cache.Config{agentNamespace: {}}
+		}
+		cacheOpts.ByObject = map[client.Object]cache.ByObject{&corev1.Pod{}: podCache}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  cacheOpts,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -183,21 +261,129 @@ func main() {
 		os.Exit(1)
 	}
 
-	confMgr := configfile.NewManager(configurationRoot)
-	if err := confMgr.CleanAll(setupLog); err != nil {
-		setupLog.Error(err, "unable to clean all the stale configuration")
+	readinessMode, err := controller.ParseNodeReadinessMode(nodeReadiness)
+	if err != nil {
+		setupLog.Error(err, "invalid node readiness mode")
+		os.Exit(1)
+	}
+	if readinessMode != "" && !enableAgent {
+		// only the agent knows the node it runs on, and it must run on every node to gate them all
+		setupLog.Error(errors.New("the node readiness is reported by the agents"), "node readiness requires the agent", "mode", readinessMode)
 		os.Exit(1)
 	}
 
-	cli := mgr.GetClient()
-	if err := (&controller.ConfigurationReconciler{
-		Client:  cli,
-		Scheme:  mgr.GetScheme(),
-		ConfMgr: confMgr,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Configuration")
+	managerOpts, err := files.managerOptions()
+	if err != nil {
+		setupLog.Error(err, "invalid file lock mode")
+		os.Exit(1)
+	}
+
+	validationOpts, err := files.validationOptions()
+	if err != nil {
+		setupLog.Error(err, "invalid maximum permission")
 		os.Exit(1)
 	}
+
+	namespaces := files.namespaces()
+	cli := mgr.GetClient()
+	ctx := ctrl.SetupSignalHandler()
+	if enableAgent {
+		confMgr := configfile.NewManagerWithOptions(files.configurationRoot, managerOpts)
+		if dryRun {
+			// the root is left as it is
+			setupLog.Info("running in dry run mode", "configurationRoot", files.configurationRoot)
+		} else {
+			if err := confMgr.CleanStaleTempFiles(setupLog); err != nil {
+				setupLog.Error(err, "unable to clean the stale temporary files")
+				os.Exit(1)
+			}
+			// the files described by the objects in the cluster, like the ones "kubedredger apply"
+			// wrote on bootstrap, are kept until reconciled: only the other ones are removed
+			described, err := controller.DescribedFiles(ctx, mgr.GetAPIReader())
+			if err != nil {
+				setupLog.Error(err, "unable to list the configurations")
+				os.Exit(1)
+			}
+			if err := confMgr.CleanAllExcept(setupLog, described...); err != nil {
+				setupLog.Error(err, "unable to clean all the stale configuration")
+				os.Exit(1)
+			}
+		}
+
+		// the node is read live: it changes often, on every kubelet heartbeat, so the cache is often stale
+		nodes := nodelabel.NewManagerWithReader(nodeName, cli, mgr.GetAPIReader())
+		confRec := controller.ConfigurationReconciler{
+			Client:  cli,
+			Scheme:  mgr.GetScheme(),
+			ConfMgr: confMgr,
+
+			Recorder:                mgr.GetEventRecorderFor("kubedredger"),
+			NodeName:                nodeName,
+			Nodes:                   nodes,
+			StatusPreviewSize:       statusPreviewSize,
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+			AllowedNamespaces:       namespaces,
+			Validation:              validationOpts,
+			DryRun:                  dryRun,
+		}
+		if err := (&confRec).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Configuration")
+			os.Exit(1)
+		}
+		if err := (&controller.ClusterConfigurationReconciler{
+			ConfigurationReconciler: confRec,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterConfiguration")
+			os.Exit(1)
+		}
+		if readinessMode != "" && dryRun {
+			// the files are never written, so the node would never be ready
+			setupLog.Info("node readiness report disabled in dry run mode", "mode", readinessMode)
+		} else if readinessMode != "" {
+			if err := (&controller.NodeReadinessReconciler{
+				Client:   cli,
+				NodeName: nodeName,
+				Mode:     readinessMode,
+				Nodes:    nodes,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "NodeReadiness")
+				os.Exit(1)
+			}
+		}
+	}
+	if enableRollout {
+		rolloutRec := controller.RolloutReconciler{
+			Client:         cli,
+			Scheme:         mgr.GetScheme(),
+			Recorder:       mgr.GetEventRecorderFor("kubedredger-rollout"),
+			NodeSelector:   nodeSelector,
+			AgentSelector:  agentSelector,
+			AgentNamespace: agentNamespace,
+			// the pods of the health gates are read live, to avoid caching all the pods of the cluster
+			HealthChecker: healthgate.New(mgr.GetAPIReader(), nil),
+		}
+		if err := (&rolloutRec).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Rollout")
+			os.Exit(1)
+		}
+		if err := (&controller.ClusterRolloutReconciler{
+			RolloutReconciler: rolloutRec,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterRollout")
+			os.Exit(1)
+		}
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1alpha2.SetupConfigurationWebhookWithManager(mgr, namespaces, validationOpts); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Configuration")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha2.SetupClusterConfigurationWebhookWithManager(mgr, validationOpts); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterConfiguration")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -210,7 +396,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
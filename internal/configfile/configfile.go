@@ -19,37 +19,96 @@ limitations under the License.
 package configfile
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+
+	"golab.io/kubedredger/internal/contenthash"
+	"golab.io/kubedredger/internal/metrics"
 )
 
 // DefaultPermission is the default UNIX permission expressed in octal form
-// the file(s) will be using. Example: 644 (rw-r--r--)
-const DefaultPermission = 644
+// the file(s) will be using. Example: 0644 (rw-r--r--)
+const DefaultPermission = 0644
 
 // ConfigurationStatus represents informations about how the last configuration
 // sync went
 type ConfigurationStatus struct {
 	// LastWriteError is the last occurred error in human-friendly way
 	LastWriteError string
+	// LastWriteErrorReason classifies the last occurred error, if known (ErrorReason*)
+	LastWriteErrorReason string
 	// FileExists is true if the file was created. Note this is true even if the content is out of sync
 	FileExists bool
 	// Content is a mirror of the last content written on storage
 	Content string
+	// ContentHash is the hash of Content, computed using the contenthash package
+	ContentHash string
+	// Size is the size in bytes of the file
+	Size int64
+	// Mode is the permission bits of the file, including the special bits
+	Mode fs.FileMode
+	// Owner is the owner of the file as "uid:gid", if known
+	Owner string
 	// FileUpdate is a timestamp of the last time the file was successfully updated
 	FileUpdated time.Time
 }
 
-// Manager represent an object capable of storing the configuration on a given path
+const (
+	// ErrorReasonLockTimeout is reported when the application held the lock for too long
+	ErrorReasonLockTimeout = "LockTimeout"
+	// ErrorReasonQuotaExceeded is reported when the content exceeds the per-file or per-root quota
+	ErrorReasonQuotaExceeded = "QuotaExceeded"
+	// ErrorReasonInsufficientSpace is reported when the filesystem is running out of space
+	ErrorReasonInsufficientSpace = "InsufficientSpace"
+)
+
+// Options tunes the behavior of a Manager. The zero value is valid.
+type Options struct {
+	// LockMode sets the kind of the advisory lock to hold while updating a file.
+	LockMode LockMode
+	// LockTimeout is how long to wait for the lock. If zero, DefaultLockTimeout is used.
+	LockTimeout time.Duration
+	// Storage is where the files are stored. If nil, the host filesystem is used.
+	// Advisory locking (LockMode) is only supported on the host filesystem.
+	Storage Storage
+	// MaxFileSize is the maximum size in bytes of each file. Zero means unlimited.
+	MaxFileSize int64
+	// MaxRootSize is the maximum size in bytes of all the files in the root. Zero means unlimited.
+	MaxRootSize int64
+	// MinFreeSpace is the amount of bytes which must be left free on the filesystem after a write.
+	MinFreeSpace uint64
+	// HistorySize is the number of the versions replaced by the writes kept for each file,
+	// so they can be reverted to. If zero, DefaultHistorySize is used; if negative, none is kept.
+	HistorySize int
+}
+
+// Manager represent an object capable of storing the configuration on a given path.
+// Manager is safe for concurrent use. Operations on the same file are serialized,
+// while operations on different files can proceed in parallel.
 type Manager struct {
-	path string
-	errs map[string]error
+	path    string
+	opts    Options
+	storage Storage
+	// lock protects errs, locks, written, revisions and history, not the files themselves
+	lock  sync.Mutex
+	errs  map[string]error
+	locks map[string]*sync.Mutex
+	// written tracks the digest of the content last written, to detect external changes
+	written map[string][sha256.Size]byte
+	// revisions tracks the revision of the requests which last wrote the files
+	revisions map[string]string
+	// history tracks the versions replaced by the writes, the most recent last
+	history map[string][]Version
+	// quotaLock serializes the writes when MaxRootSize is enforced
+	quotaLock sync.Mutex
 }
 
 // NewManager creates a Manager owning a given <configurationPath>
@@ -59,10 +118,93 @@ type Manager struct {
 // at any time and change according to its policies.
 // The manager will guarantee data is stored in the configuration files.
 func NewManager(configurationPath string) *Manager {
+	return NewManagerWithOptions(configurationPath, Options{})
+}
+
+// NewManagerWithOptions creates a Manager like NewManager, using the given Options.
+func NewManagerWithOptions(configurationPath string, opts Options) *Manager {
+	if opts.LockMode == "" {
+		opts.LockMode = LockNone
+	}
+	if opts.LockTimeout == 0 {
+		opts.LockTimeout = DefaultLockTimeout
+	}
+	if opts.HistorySize == 0 {
+		opts.HistorySize = DefaultHistorySize
+	}
+	storage := opts.Storage
+	if storage == nil {
+		storage = NewOSStorage()
+	}
 	return &Manager{
-		path: configurationPath,
-		errs: make(map[string]error),
+		path:      configurationPath,
+		opts:      opts,
+		storage:   storage,
+		errs:      make(map[string]error),
+		locks:     make(map[string]*sync.Mutex),
+		written:   make(map[string][sha256.Size]byte),
+		revisions: make(map[string]string),
+		history:   make(map[string][]Version),
+	}
+}
+
+// fileLock returns the lock serializing the operations on the given file.
+// Locks are never released, but their number is bound by the number of
+// the managed files, which is expected to be small.
+func (mgr *Manager) fileLock(fileName string) *sync.Mutex {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	fl, ok := mgr.locks[fileName]
+	if !ok {
+		fl = &sync.Mutex{}
+		mgr.locks[fileName] = fl
 	}
+	return fl
+}
+
+func (mgr *Manager) setError(fileName string, err error) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	if err == nil {
+		delete(mgr.errs, fileName)
+		return
+	}
+	mgr.errs[fileName] = err
+}
+
+// setWritten records the digest of the content written on the given file, and the revision of the request writing it.
+func (mgr *Manager) setWritten(fileName string, content []byte, revision string) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	mgr.written[fileName] = sha256.Sum256(content)
+	mgr.revisions[fileName] = revision
+	metrics.ManagedFiles.Set(float64(len(mgr.written)))
+}
+
+// forgetWritten records the given file is no longer managed. Its history is dropped.
+func (mgr *Manager) forgetWritten(fileName string) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	delete(mgr.written, fileName)
+	delete(mgr.revisions, fileName)
+	delete(mgr.history, fileName)
+	metrics.ForgetFile(fileName)
+	metrics.ManagedFiles.Set(float64(len(mgr.written)))
+}
+
+// isDrifted returns true if the given file was written by the Manager, but its content
+// changed meanwhile.
+func (mgr *Manager) isDrifted(fileName string, content []byte) bool {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	digest, ok := mgr.written[fileName]
+	return ok && digest != sha256.Sum256(content)
+}
+
+func (mgr *Manager) getError(fileName string) error {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	return mgr.errs[fileName]
 }
 
 // NonRecoverableError is an error which can't be retried. Parameters must change.
@@ -75,17 +217,20 @@ func (e NonRecoverableError) Error() string {
 }
 
 func (mgr *Manager) CleanAll(lh logr.Logger) error {
-	entries, err := os.ReadDir(mgr.path)
+	entries, err := mgr.storage.ReadDir(mgr.path)
 	if err != nil {
 		lh.Info("configuration root missing, recreating", "configRoot", mgr.path)
-		if os.IsNotExist(err) {
-			return os.MkdirAll(mgr.path, 0755)
+		if errors.Is(err, fs.ErrNotExist) {
+			return mgr.storage.MkdirAll(mgr.path, 0755)
 		}
 		return err
 	}
 
 	entryNames := make([]string, 0, len(entries))
 	for _, entry := range entries {
+		if mgr.opts.LockMode != LockNone && IsLockFile(entry.Name()) {
+			continue // applications may be waiting on them
+		}
 		entryNames = append(entryNames, entry.Name())
 	}
 
@@ -98,11 +243,70 @@ func (mgr *Manager) CleanAll(lh logr.Logger) error {
 	return nil
 }
 
+// CleanAllExcept removes everything in the configuration root like CleanAll does, but the given files,
+// relative to the root, and the directories holding them. The directories left empty are removed too.
+func (mgr *Manager) CleanAllExcept(lh logr.Logger, fileNames ...string) error {
+	if _, err := mgr.storage.Stat(mgr.path); err != nil {
+		lh.Info("configuration root missing, recreating", "configRoot", mgr.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return mgr.storage.MkdirAll(mgr.path, 0755)
+		}
+		return err
+	}
+	keep := make(map[string]bool, len(fileNames))
+	for _, fileName := range fileNames {
+		keep[filepath.Clean(fileName)] = true
+	}
+	if _, err := mgr.cleanDir(lh, ".", keep); err != nil {
+		return NonRecoverableError{
+			err: err,
+		}
+	}
+	return nil
+}
+
+// cleanDir removes the entries of the given directory, relative to the root, which are not kept.
+// Returns true if the directory is left empty.
+func (mgr *Manager) cleanDir(lh logr.Logger, dir string, keep map[string]bool) (bool, error) {
+	entries, err := mgr.storage.ReadDir(filepath.Join(mgr.path, dir))
+	if err != nil {
+		return false, err
+	}
+	var errs []error
+	left := 0
+	for _, entry := range entries {
+		name := filepath.Join(dir, entry.Name())
+		entryPath := filepath.Join(mgr.path, name)
+		switch {
+		case keep[name], mgr.opts.LockMode != LockNone && IsLockFile(entry.Name()):
+			left++
+		case entry.IsDir():
+			empty, err := mgr.cleanDir(lh, name, keep)
+			if err == nil && empty {
+				err = mgr.storage.Remove(entryPath)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				left++
+			} else if !empty {
+				left++
+			}
+		default:
+			lh.Info("removing configuration file not described by any object", "path", entryPath)
+			if err := mgr.storage.RemoveAll(entryPath); err != nil {
+				errs = append(errs, err)
+				left++
+			}
+		}
+	}
+	return left == 0, errors.Join(errs...)
+}
+
 func (mgr *Manager) CleanEntries(entries ...string) error {
 	var errs []error
 	for _, entry := range entries {
 		entryPath := filepath.Join(mgr.path, entry)
-		err := os.RemoveAll(entryPath)
+		err := mgr.storage.RemoveAll(entryPath)
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -110,123 +314,325 @@ func (mgr *Manager) CleanEntries(entries ...string) error {
 	return errors.Join(errs...)
 }
 
+// CleanStaleTempFiles removes the temporary files left behind by interrupted writes.
+// Besides the configuration root, it inspects the parent of the root, because
+// older versions used to stage the temporary files there.
+func (mgr *Manager) CleanStaleTempFiles(lh logr.Logger) error {
+	var errs []error
+	errs = append(errs, mgr.cleanTempFilesIn(lh, filepath.Dir(mgr.path), false))
+	errs = append(errs, mgr.cleanTempFilesIn(lh, mgr.path, true))
+	return errors.Join(errs...)
+}
+
+func (mgr *Manager) cleanTempFilesIn(lh logr.Logger, dir string, recursive bool) error {
+	entries, err := mgr.storage.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if recursive {
+				errs = append(errs, mgr.cleanTempFilesIn(lh, entryPath, recursive))
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() || !IsTempFile(entry.Name()) {
+			continue
+		}
+		lh.Info("removing stale temporary file", "path", entryPath)
+		if err := mgr.storage.Remove(entryPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // ConfigRequest represents a request to write configuration on storage.
 type ConfigRequest struct {
 	Filename   string
 	Content    string
 	Create     bool
 	Permission *uint32
+	MaxSize    *int64
+	// Revision optionally identifies the request. It is recorded in the history of the file.
+	Revision string
+	// Force rewrites the file even if it already has the expected content and permissions.
+	Force bool
+}
+
+// permBits are the bits of the file mode the Manager controls
+const permBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+const (
+	// UnixSetuid, UnixSetgid and UnixSticky are the special bits of the UNIX octal permission notation
+	UnixSetuid = 04000
+	UnixSetgid = 02000
+	UnixSticky = 01000
+	// UnixSpecialBits are all the special bits of the UNIX octal permission notation
+	UnixSpecialBits = UnixSetuid | UnixSetgid | UnixSticky
+)
+
+// FileModeFromUnix translates the UNIX octal permission notation (example: 04755) to a FileMode.
+// Unlike the permission bits, the special bits have a different representation in FileMode,
+// so the value can't be simply converted. The bits not part of the notation are ignored.
+func FileModeFromUnix(perm uint32) fs.FileMode {
+	mode := fs.FileMode(perm) & fs.ModePerm
+	if perm&UnixSetuid != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if perm&UnixSetgid != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if perm&UnixSticky != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
 }
 
+// UnixFromFileMode translates the permission bits of the given FileMode to the UNIX octal permission notation.
+// It is the inverse of FileModeFromUnix.
+func UnixFromFileMode(mode fs.FileMode) uint32 {
+	perm := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		perm |= UnixSetuid
+	}
+	if mode&fs.ModeSetgid != 0 {
+		perm |= UnixSetgid
+	}
+	if mode&fs.ModeSticky != 0 {
+		perm |= UnixSticky
+	}
+	return perm
+}
+
+// SyncOutcome describes what HandleSync did to the configuration file
+type SyncOutcome string
+
+const (
+	// SyncCreated means the file did not exist and was created
+	SyncCreated SyncOutcome = "Created"
+	// SyncUpdated means the file existed and was updated with new content or permissions
+	SyncUpdated SyncOutcome = "Updated"
+	// SyncUnchanged means the file already had the expected content and permissions
+	SyncUnchanged SyncOutcome = "Unchanged"
+	// SyncDriftCorrected means the file was modified externally and was restored
+	SyncDriftCorrected SyncOutcome = "DriftCorrected"
+	// SyncDeleted means the file existed and was removed. Only DryRunDelete reports it.
+	SyncDeleted SyncOutcome = "Deleted"
+)
+
 // HandleSync reconciles the on-disk configuration with the given request.
 // Once it returns, the operation is completed.
-// On failure, returns non-nil error; on success, returns nil
-func (mgr *Manager) HandleSync(lh logr.Logger, request ConfigRequest) error {
-	err := mgr.handle(lh, request)
-	if err != nil {
-		mgr.errs[request.Filename] = err
-		return err
-	}
-	return nil
+// On failure, returns non-nil error; on success, returns nil and the
+// outcome of the operation. On failure the outcome must be ignored.
+func (mgr *Manager) HandleSync(lh logr.Logger, request ConfigRequest) (SyncOutcome, error) {
+	fl := mgr.fileLock(request.Filename)
+	fl.Lock()
+	defer fl.Unlock()
+
+	outcome, err := mgr.handle(lh, request)
+	mgr.setError(request.Filename, err)
+	return outcome, err
 }
 
-func (mgr *Manager) handle(lh logr.Logger, request ConfigRequest) error {
-	content := request.Content
+func (mgr *Manager) handle(lh logr.Logger, request ConfigRequest) (SyncOutcome, error) {
+	content := []byte(request.Content)
 	fullPath := filepath.Join(mgr.path, request.Filename)
-	exists, err := FileExists(fullPath)
+	exists, err := mgr.fileExists(fullPath)
 	if err != nil {
-		return fmt.Errorf("failed to check if file %q exists: %w", fullPath, err)
+		return "", fmt.Errorf("failed to check if file %q exists: %w", fullPath, err)
 	}
 
 	if !exists && !request.Create {
-		return NonRecoverableError{
+		return "", NonRecoverableError{
 			err: fmt.Errorf("file %q does not exist and creation is not allowed", mgr.path),
 		}
 	}
 
-	lh.Info("creating temporary configuration file", "path", fullPath)
+	if dir := filepath.Dir(fullPath); !exists && dir != filepath.Clean(mgr.path) {
+		// files can live in subdirectories of the root, like the namespaced ones do,
+		// but the root itself is never created here: it can be a mount point.
+		if _, err := mgr.storage.Stat(mgr.path); err != nil {
+			return "", fmt.Errorf("failed to check the configuration root %q: %w", mgr.path, err)
+		}
+		if err := mgr.storage.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create the directory %q: %w", dir, err)
+		}
+	}
 
-	tmpFile, err := os.CreateTemp(filepath.Dir(mgr.path), "kubedredger-")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
+	perm := FileModeFromUnix(DefaultPermission)
+	if request.Permission != nil {
+		perm = FileModeFromUnix(*request.Permission)
 	}
-	defer func() {
-		_ = os.Remove(tmpFile.Name())
-	}()
 
-	lh.Info("updating temporary configuration file")
-	if _, err := tmpFile.WriteString(content); err != nil {
-		return fmt.Errorf("failed to write to temporary file: %w", err)
+	outcome := SyncCreated
+	previous := Version{}
+	rewrite := false
+	if exists {
+		mode, current, err := mgr.current(fullPath)
+		if err != nil {
+			return "", err
+		}
+		if mode == perm && bytes.Equal(current, content) {
+			if !request.Force {
+				lh.Info("configuration file up to date", "path", fullPath)
+				mgr.setWritten(request.Filename, content, request.Revision)
+				metrics.MarkSynced(request.Filename)
+				return SyncUnchanged, nil
+			}
+			// the version is written again, not replaced
+			rewrite = true
+		}
+		outcome = SyncUpdated
+		previous = Version{Exists: true, Content: string(current), Mode: mode}
+		if mgr.isDrifted(request.Filename, current) {
+			// the content was not written by any request
+			outcome = SyncDriftCorrected
+			previous.Drifted = true
+		} else {
+			previous.Revision = mgr.revision(request.Filename)
+		}
 	}
 
-	perm := fs.FileMode(0644)
-	if request.Permission != nil {
-		perm = fs.FileMode(*request.Permission)
+	if mgr.opts.MaxRootSize > 0 {
+		// the root usage must not change between the check and the write
+		mgr.quotaLock.Lock()
+		defer mgr.quotaLock.Unlock()
 	}
-	lh.Info("setting permissions", "perms", perm)
-	if err := tmpFile.Chmod(perm); err != nil {
-		return fmt.Errorf("failed to set permissions on temporary file: %w", err)
+	if err := mgr.checkQuota(fullPath, request); err != nil {
+		return "", err
+	}
+
+	started := time.Now()
+	lh.Info("acquiring file lock", "mode", mgr.opts.LockMode, "timeout", mgr.opts.LockTimeout)
+	al, err := acquireLock(fullPath, mgr.opts.LockMode, mgr.opts.LockTimeout)
+	if err != nil {
+		return "", err
 	}
+	defer func() {
+		if err := al.Unlock(); err != nil {
+			lh.Error(err, "failed to release file lock", "path", fullPath)
+		}
+	}()
 
-	lh.Info("finalizing file content")
-	if err := tmpFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temporary file: %w", err)
+	lh.Info("updating configuration file", "path", fullPath, "perms", perm, "outcome", outcome)
+	if err := mgr.storage.WriteFileAtomic(fullPath, content, perm); err != nil {
+		return "", err
 	}
-	if err := os.Rename(tmpFile.Name(), fullPath); err != nil {
-		return fmt.Errorf("failed to rename temporary file: %w", err)
+	if !rewrite {
+		mgr.pushVersion(request.Filename, previous)
+	}
+	mgr.setWritten(request.Filename, content, request.Revision)
+	metrics.ObserveWrite(request.Filename, len(content), started)
+	if outcome == SyncDriftCorrected {
+		metrics.DriftCorrectionsTotal.Inc()
 	}
 
 	lh.Info("configuration updated")
-	return nil
+	return outcome, nil
 }
 
-// Delete removes the configuration file at the manager's path.
-func (mgr *Manager) Delete(fileName string) error {
-	fullPath := filepath.Join(mgr.path, fileName)
-	err := os.Remove(fullPath)
-	if os.IsNotExist(err) {
-		delete(mgr.errs, fileName)
-		return nil
+// current returns the permissions and the content of the existing file.
+func (mgr *Manager) current(fullPath string) (fs.FileMode, []byte, error) {
+	finfo, err := mgr.storage.Stat(fullPath)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to check the current state of %q: %w", fullPath, err)
 	}
+	content, err := mgr.storage.ReadFile(fullPath)
 	if err != nil {
-		mgr.errs[fileName] = err
+		return 0, nil, fmt.Errorf("failed to read the current content of %q: %w", fullPath, err)
+	}
+	return finfo.Mode() & permBits, content, nil
+}
+
+// Delete removes the configuration file at the manager's path, and its sidecar lock file, if any.
+func (mgr *Manager) Delete(fileName string) error {
+	fl := mgr.fileLock(fileName)
+	fl.Lock()
+	defer fl.Unlock()
+
+	fullPath := filepath.Join(mgr.path, fileName)
+	err := mgr.storage.Remove(fullPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		mgr.setError(fileName, err)
 		return fmt.Errorf("failed to delete file %q: %w", fullPath, err)
 	}
-	delete(mgr.errs, fileName)
+	if err := removeLockFile(fullPath, mgr.opts.LockMode); err != nil {
+		mgr.setError(fileName, err)
+		return err
+	}
+	mgr.setError(fileName, nil)
+	mgr.forgetWritten(fileName)
 	return nil
 }
 
 // Status reports how the last sync attempt went.
 func (mgr *Manager) Status(fileName string) ConfigurationStatus {
+	fl := mgr.fileLock(fileName)
+	fl.Lock()
+	defer fl.Unlock()
+
 	fullPath := filepath.Join(mgr.path, fileName)
 	res := ConfigurationStatus{}
-	if err := mgr.errs[fileName]; err != nil {
+	if err := mgr.getError(fileName); err != nil {
 		res.LastWriteError = err.Error()
+		res.LastWriteErrorReason = errorReason(err)
 	}
-	finfo, err := os.Stat(fullPath)
-	if os.IsNotExist(err) {
+	finfo, err := mgr.storage.Stat(fullPath)
+	if err != nil {
+		// includes fs.ErrNotExist. We can't tell much about the file.
 		res.FileExists = false
 		return res
 	}
 	res.FileUpdated = finfo.ModTime()
-	content, err := os.ReadFile(fullPath)
-	if os.IsNotExist(err) {
+	content, err := mgr.storage.ReadFile(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
 		res.FileExists = false
 		return res
 	}
 	res.FileExists = true
 	res.Content = string(content)
+	res.ContentHash = contenthash.Sum(content)
+	res.Size = finfo.Size()
+	res.Mode = finfo.Mode() & permBits
+	res.Owner = fileOwner(finfo)
 	return res
 }
 
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrLockTimeout):
+		return ErrorReasonLockTimeout
+	case errors.Is(err, ErrQuotaExceeded):
+		return ErrorReasonQuotaExceeded
+	case errors.Is(err, ErrInsufficientSpace):
+		return ErrorReasonInsufficientSpace
+	default:
+		return ""
+	}
+}
+
+func (mgr *Manager) fileExists(filePath string) (bool, error) {
+	return fileExistsOn(mgr.storage, filePath)
+}
+
 // FileExists return true if the given path exists;
 // On failure, returns non-nil error and the truth value should be ignored.
 func FileExists(filePath string) (bool, error) {
-	_, err := os.Stat(filePath)
+	return fileExistsOn(NewOSStorage(), filePath)
+}
+
+func fileExistsOn(storage Storage, filePath string) (bool, error) {
+	_, err := storage.Stat(filePath)
 	if err == nil {
 		return true, nil
 	}
-	if os.IsNotExist(err) {
+	if errors.Is(err, fs.ErrNotExist) {
 		return false, nil
 	}
 	return false, fmt.Errorf("error checking existence of %s: %w", filePath, err)
@@ -17,13 +17,18 @@ limitations under the License.
 package configfile
 
 import (
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-logr/logr/testr"
 	"github.com/google/go-cmp/cmp"
+
+	"golab.io/kubedredger/internal/contenthash"
 )
 
 const (
@@ -49,7 +54,7 @@ func TestCreateFromScratch(t *testing.T) {
 
 	tmpDir := t.TempDir()
 	mgr := NewManager(tmpDir)
-	err := mgr.HandleSync(lh, ConfigRequest{
+	_, err := mgr.HandleSync(lh, ConfigRequest{
 		Filename: defaultConfName,
 		Content:  minimalConfContent,
 		Create:   true,
@@ -68,7 +73,7 @@ func TestCreateNotSet(t *testing.T) {
 
 	tmpDir := t.TempDir()
 	mgr := NewManager(tmpDir)
-	err := mgr.HandleSync(lh, ConfigRequest{
+	_, err := mgr.HandleSync(lh, ConfigRequest{
 		Filename: defaultConfName,
 		Content:  minimalConfContent,
 		Create:   false,
@@ -85,7 +90,7 @@ func TestCreateDelete(t *testing.T) {
 
 	tmpDir := t.TempDir()
 	mgr := NewManager(tmpDir)
-	err := mgr.HandleSync(lh, ConfigRequest{
+	_, err := mgr.HandleSync(lh, ConfigRequest{
 		Filename: defaultConfName,
 		Content:  minimalConfContent,
 		Create:   true,
@@ -119,7 +124,7 @@ func TestCreateUpdate(t *testing.T) {
 
 	tmpDir := t.TempDir()
 	mgr := NewManager(tmpDir)
-	err := mgr.HandleSync(lh, ConfigRequest{
+	_, err := mgr.HandleSync(lh, ConfigRequest{
 		Filename: defaultConfName,
 		Content:  minimalConfContent,
 		Create:   true,
@@ -134,7 +139,7 @@ func TestCreateUpdate(t *testing.T) {
 
 	time.Sleep(51 * time.Millisecond) // ensure update time diff
 	content2 := `{\n"  foo": "bar"\n}`
-	err = mgr.HandleSync(lh, ConfigRequest{
+	_, err = mgr.HandleSync(lh, ConfigRequest{
 		Filename: defaultConfName,
 		Content:  content2,
 	})
@@ -146,6 +151,124 @@ func TestCreateUpdate(t *testing.T) {
 	verifyFileExistsWithContent(t, st2, confPath, content2, ts)
 }
 
+func TestConcurrentSameFile(t *testing.T) {
+	lh := testr.New(t)
+
+	tmpDir := t.TempDir()
+	mgr := NewManager(tmpDir)
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 8; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			for iter := 0; iter < 16; iter++ {
+				content := fmt.Sprintf("worker=%d\niteration=%d\n", idx, iter)
+				_, err := mgr.HandleSync(lh, ConfigRequest{
+					Filename: defaultConfName,
+					Content:  content,
+					Create:   true,
+				})
+				if err != nil {
+					t.Errorf("worker %d: unexpected sync error: %v", idx, err)
+				}
+				_ = mgr.Status(defaultConfName)
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	st := mgr.Status(defaultConfName)
+	if !st.FileExists || st.LastWriteError != "" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	bindata, err := os.ReadFile(filepath.Join(tmpDir, defaultConfName))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(bindata) != st.Content {
+		t.Fatalf("torn content: got=%q status=%q", string(bindata), st.Content)
+	}
+}
+
+func TestConcurrentManyFiles(t *testing.T) {
+	lh := testr.New(t)
+
+	tmpDir := t.TempDir()
+	mgr := NewManager(tmpDir)
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 8; idx++ {
+		fileName := fmt.Sprintf("workshop-%d.conf", idx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for iter := 0; iter < 16; iter++ {
+				_, err := mgr.HandleSync(lh, ConfigRequest{
+					Filename: fileName,
+					Content:  minimalConfContent,
+					Create:   true,
+				})
+				if err != nil {
+					t.Errorf("file %q: unexpected sync error: %v", fileName, err)
+				}
+				_ = mgr.Status(fileName)
+				// trigger some failures to exercise the error tracking
+				_, _ = mgr.HandleSync(lh, ConfigRequest{
+					Filename: fileName + ".missing",
+					Content:  minimalConfContent,
+				})
+				_ = mgr.Status(fileName + ".missing")
+				if err := mgr.Delete(fileName); err != nil {
+					t.Errorf("file %q: unexpected delete error: %v", fileName, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for idx := 0; idx < 8; idx++ {
+		fileName := fmt.Sprintf("workshop-%d.conf", idx)
+		st := mgr.Status(fileName)
+		if st.FileExists || st.LastWriteError != "" {
+			t.Errorf("file %q: unexpected status: %+v", fileName, st)
+		}
+		st = mgr.Status(fileName + ".missing")
+		if st.LastWriteError == "" {
+			t.Errorf("file %q: missing expected error", fileName+".missing")
+		}
+	}
+}
+
+func TestErrorClearedOnSuccess(t *testing.T) {
+	lh := testr.New(t)
+
+	tmpDir := t.TempDir()
+	mgr := NewManager(tmpDir)
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+	})
+	if err == nil {
+		t.Fatalf("create not set, but file does not exist and this was allowed")
+	}
+	if st := mgr.Status(defaultConfName); st.LastWriteError == "" {
+		t.Fatalf("error not reported in status")
+	}
+
+	_, err = mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if st := mgr.Status(defaultConfName); st.LastWriteError != "" {
+		t.Fatalf("stale error reported in status: %q", st.LastWriteError)
+	}
+}
+
 func verifyFileExistsWithContent(t *testing.T, st ConfigurationStatus, confPath, content string, ts time.Time) {
 	t.Helper()
 	bindata, err := os.ReadFile(confPath)
@@ -160,12 +283,167 @@ func verifyFileExistsWithContent(t *testing.T, st ConfigurationStatus, confPath,
 		t.Fatalf("unexpected update: got=%v ref=%v", st.FileUpdated, ts)
 	}
 	st.FileUpdated = ts // normalize
+	st.Owner = ""       // platform dependent
 	expected := ConfigurationStatus{
 		FileExists:  true,
 		Content:     content,
+		ContentHash: contenthash.Sum([]byte(content)),
+		Size:        int64(len(content)),
+		Mode:        0644,
 		FileUpdated: ts,
 	}
 	if diff := cmp.Diff(st, expected); diff != "" {
 		t.Fatalf("status mismatch: %v", diff)
 	}
 }
+
+func TestSyncOutcome(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	confPath := filepath.Join(memoryRoot, defaultConfName)
+
+	type testCase struct {
+		name            string
+		content         string
+		permission      uint32
+		force           bool
+		tamper          func() error
+		expectedOutcome SyncOutcome
+	}
+
+	// the steps build on each other, so the order matters
+	testCases := []testCase{
+		{
+			name:            "missing file",
+			content:         minimalConfContent,
+			permission:      0644,
+			expectedOutcome: SyncCreated,
+		},
+		{
+			name:            "same content",
+			content:         minimalConfContent,
+			permission:      0644,
+			expectedOutcome: SyncUnchanged,
+		},
+		{
+			name:            "new content",
+			content:         "[main]\nfoo=baz\n",
+			permission:      0644,
+			expectedOutcome: SyncUpdated,
+		},
+		{
+			name:            "new permission",
+			content:         "[main]\nfoo=baz\n",
+			permission:      0600,
+			expectedOutcome: SyncUpdated,
+		},
+		{
+			name:       "external change",
+			content:    "[main]\nfoo=baz\n",
+			permission: 0600,
+			tamper: func() error {
+				return storage.WriteFileAtomic(confPath, []byte("[main]\nfoo=qux\n"), 0600)
+			},
+			expectedOutcome: SyncDriftCorrected,
+		},
+		{
+			name:            "forced same content",
+			content:         "[main]\nfoo=baz\n",
+			permission:      0600,
+			force:           true,
+			expectedOutcome: SyncUpdated,
+		},
+	}
+
+	for _, tcase := range testCases {
+		if tcase.tamper != nil {
+			if err := tcase.tamper(); err != nil {
+				t.Fatalf("%s: unexpected tamper error: %v", tcase.name, err)
+			}
+		}
+		outcome, err := mgr.HandleSync(lh, ConfigRequest{
+			Filename:   defaultConfName,
+			Content:    tcase.content,
+			Create:     true,
+			Permission: &tcase.permission,
+			Force:      tcase.force,
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected sync error: %v", tcase.name, err)
+		}
+		if outcome != tcase.expectedOutcome {
+			t.Fatalf("%s: unexpected outcome got=%q expected=%q", tcase.name, outcome, tcase.expectedOutcome)
+		}
+	}
+}
+
+func TestFileModeFromUnix(t *testing.T) {
+	type testCase struct {
+		perm         uint32
+		expectedMode fs.FileMode
+	}
+
+	testCases := []testCase{
+		{perm: 0644, expectedMode: 0644},
+		{perm: 0, expectedMode: 0},
+		{perm: 04755, expectedMode: 0755 | fs.ModeSetuid},
+		{perm: 02750, expectedMode: 0750 | fs.ModeSetgid},
+		{perm: 01777, expectedMode: 0777 | fs.ModeSticky},
+		{perm: 07000, expectedMode: fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky},
+		// bits outside of the notation are ignored
+		{perm: 010644, expectedMode: 0644},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(fmt.Sprintf("%o", tcase.perm), func(t *testing.T) {
+			mode := FileModeFromUnix(tcase.perm)
+			if mode != tcase.expectedMode {
+				t.Fatalf("unexpected mode got=%v expected=%v", mode, tcase.expectedMode)
+			}
+			if perm := UnixFromFileMode(mode); perm != tcase.perm&(UnixSpecialBits|0777) {
+				t.Fatalf("unexpected round trip perm got=%o expected=%o", perm, tcase.perm)
+			}
+		})
+	}
+}
+
+func TestSyncSpecialBits(t *testing.T) {
+	lh := testr.New(t)
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: NewMemoryStorage()})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	perm := uint32(02640)
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename:   defaultConfName,
+		Content:    minimalConfContent,
+		Create:     true,
+		Permission: &perm,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	st := mgr.Status(defaultConfName)
+	if st.Mode != 0640|fs.ModeSetgid {
+		t.Fatalf("unexpected mode: %v", st.Mode)
+	}
+
+	// the file is already up to date, special bits included
+	outcome, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename:   defaultConfName,
+		Content:    minimalConfContent,
+		Create:     true,
+		Permission: &perm,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if outcome != SyncUnchanged {
+		t.Fatalf("unexpected outcome: %q", outcome)
+	}
+}
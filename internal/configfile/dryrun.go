@@ -0,0 +1,124 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"golab.io/kubedredger/internal/textdiff"
+)
+
+// DryRunResult describes the changes HandleSync or Delete would make to a file.
+type DryRunResult struct {
+	// Outcome is the outcome the operation would report
+	Outcome SyncOutcome
+	// Diff is the unified diff between the current and the resulting content. Empty if they match.
+	Diff string
+	// Mode is the current permission bits of the file, if it exists
+	Mode fs.FileMode
+	// DesiredMode is the permission bits the file would have, if it is not deleted
+	DesiredMode fs.FileMode
+}
+
+// ModeChanged tells if the permission bits of an existing file would change.
+func (res DryRunResult) ModeChanged() bool {
+	return res.Outcome != SyncCreated && res.Outcome != SyncDeleted && res.Mode != res.DesiredMode
+}
+
+// DryRunSync computes the changes HandleSync would make to the configuration file,
+// without touching it. It returns the errors HandleSync would return, except the
+// ones which can only happen while writing, like the lock timeouts.
+// Unlike HandleSync, the outcome is not reflected in Status.
+func (mgr *Manager) DryRunSync(request ConfigRequest) (DryRunResult, error) {
+	fl := mgr.fileLock(request.Filename)
+	fl.Lock()
+	defer fl.Unlock()
+
+	content := []byte(request.Content)
+	fullPath := filepath.Join(mgr.path, request.Filename)
+	exists, err := mgr.fileExists(fullPath)
+	if err != nil {
+		return DryRunResult{}, fmt.Errorf("failed to check if file %q exists: %w", fullPath, err)
+	}
+
+	if !exists && !request.Create {
+		return DryRunResult{}, NonRecoverableError{
+			err: fmt.Errorf("file %q does not exist and creation is not allowed", mgr.path),
+		}
+	}
+
+	res := DryRunResult{
+		Outcome:     SyncCreated,
+		DesiredMode: FileModeFromUnix(DefaultPermission),
+	}
+	if request.Permission != nil {
+		res.DesiredMode = FileModeFromUnix(*request.Permission)
+	}
+
+	if !exists {
+		res.Diff = textdiff.Unified(request.Filename+" (missing)", request.Filename+" (desired)", "", request.Content)
+	} else {
+		mode, current, err := mgr.current(fullPath)
+		if err != nil {
+			return DryRunResult{}, err
+		}
+		res.Mode = mode
+		if mode == res.DesiredMode && bytes.Equal(current, content) {
+			res.Outcome = SyncUnchanged
+			return res, nil
+		}
+		res.Outcome = SyncUpdated
+		if mgr.isDrifted(request.Filename, current) {
+			res.Outcome = SyncDriftCorrected
+		}
+		res.Diff = textdiff.Unified(request.Filename+" (current)", request.Filename+" (desired)", string(current), request.Content)
+	}
+
+	if err := mgr.checkQuota(fullPath, request); err != nil {
+		return DryRunResult{}, err
+	}
+	return res, nil
+}
+
+// DryRunDelete computes the changes Delete would make to the configuration file, without touching it.
+// The outcome is SyncDeleted if the file exists, SyncUnchanged otherwise.
+func (mgr *Manager) DryRunDelete(fileName string) (DryRunResult, error) {
+	fl := mgr.fileLock(fileName)
+	fl.Lock()
+	defer fl.Unlock()
+
+	fullPath := filepath.Join(mgr.path, fileName)
+	exists, err := mgr.fileExists(fullPath)
+	if err != nil {
+		return DryRunResult{}, fmt.Errorf("failed to check if file %q exists: %w", fullPath, err)
+	}
+	if !exists {
+		return DryRunResult{Outcome: SyncUnchanged}, nil
+	}
+	mode, current, err := mgr.current(fullPath)
+	if err != nil {
+		return DryRunResult{}, err
+	}
+	return DryRunResult{
+		Outcome: SyncDeleted,
+		Diff:    textdiff.Unified(fileName+" (current)", fileName+" (deleted)", string(current), ""),
+		Mode:    mode,
+	}, nil
+}
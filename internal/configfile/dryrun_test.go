@@ -0,0 +1,182 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"k8s.io/utils/ptr"
+)
+
+func TestDryRunSync(t *testing.T) {
+	type testCase struct {
+		name            string
+		existing        *ConfigRequest
+		request         ConfigRequest
+		expectedOutcome SyncOutcome
+		expectedErr     error
+		nonRecoverable  bool
+		expectedDiff    []string
+		modeChanged     bool
+	}
+
+	testCases := []testCase{
+		{
+			name:            "create",
+			request:         ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Create: true},
+			expectedOutcome: SyncCreated,
+			expectedDiff:    []string{"+foo=1"},
+		},
+		{
+			name:            "create in the namespaced area",
+			request:         ConfigRequest{Filename: NamespacedFilename("team-a", defaultConfName), Content: "foo=1\n", Create: true},
+			expectedOutcome: SyncCreated,
+			expectedDiff:    []string{"+foo=1"},
+		},
+		{
+			name:           "creation not allowed",
+			request:        ConfigRequest{Filename: defaultConfName, Content: "foo=1\n"},
+			nonRecoverable: true,
+		},
+		{
+			name:            "unchanged",
+			existing:        &ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Create: true},
+			request:         ConfigRequest{Filename: defaultConfName, Content: "foo=1\n"},
+			expectedOutcome: SyncUnchanged,
+		},
+		{
+			name:            "content update",
+			existing:        &ConfigRequest{Filename: defaultConfName, Content: "foo=1\nbar=2\n", Create: true},
+			request:         ConfigRequest{Filename: defaultConfName, Content: "foo=1\nbar=3\n"},
+			expectedOutcome: SyncUpdated,
+			expectedDiff:    []string{"-bar=2", "+bar=3"},
+		},
+		{
+			name:            "mode update",
+			existing:        &ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Create: true},
+			request:         ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Permission: ptr.To[uint32](0600)},
+			expectedOutcome: SyncUpdated,
+			modeChanged:     true,
+		},
+		{
+			name:        "quota exceeded",
+			request:     ConfigRequest{Filename: defaultConfName, Content: strings.Repeat("x", 16), Create: true, MaxSize: ptr.To[int64](8)},
+			expectedErr: ErrQuotaExceeded,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			lh := testr.New(t)
+			storage := NewMemoryStorage()
+			mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+			if err := mgr.CleanAll(lh); err != nil {
+				t.Fatalf("unexpected clean error: %v", err)
+			}
+			if tcase.existing != nil {
+				if _, err := mgr.HandleSync(lh, *tcase.existing); err != nil {
+					t.Fatalf("unexpected sync error: %v", err)
+				}
+			}
+			before := mgr.Status(tcase.request.Filename)
+
+			res, err := mgr.DryRunSync(tcase.request)
+			if !tcase.nonRecoverable && !errors.Is(err, tcase.expectedErr) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, tcase.expectedErr)
+			}
+			if errors.As(err, &NonRecoverableError{}) != tcase.nonRecoverable {
+				t.Fatalf("unexpected error got=%v non-recoverable=%v", err, tcase.nonRecoverable)
+			}
+			if res.Outcome != tcase.expectedOutcome {
+				t.Fatalf("unexpected outcome got=%q expected=%q", res.Outcome, tcase.expectedOutcome)
+			}
+			for _, line := range tcase.expectedDiff {
+				if !strings.Contains(res.Diff, "\n"+line+"\n") {
+					t.Fatalf("missing line %q in diff:\n%s", line, res.Diff)
+				}
+			}
+			if res.ModeChanged() != tcase.modeChanged {
+				t.Fatalf("unexpected mode change got=%v expected=%v (%v -> %v)", res.ModeChanged(), tcase.modeChanged, res.Mode, res.DesiredMode)
+			}
+
+			// nothing must change on disk, nor in the reported status
+			after := mgr.Status(tcase.request.Filename)
+			if after.FileExists != before.FileExists || after.Content != before.Content || after.Mode != before.Mode || after.LastWriteError != before.LastWriteError {
+				t.Fatalf("unexpected change got=%+v expected=%+v", after, before)
+			}
+			if _, err := storage.Stat(filepath.Join(memoryRoot, NamespacesDir)); tcase.existing == nil && err == nil {
+				t.Fatalf("unexpected directory created")
+			}
+		})
+	}
+}
+
+func TestDryRunSyncDrift(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	req := ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Create: true}
+	if _, err := mgr.HandleSync(lh, req); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(memoryRoot, defaultConfName), []byte("foo=2\n"), 0644); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+
+	res, err := mgr.DryRunSync(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Outcome != SyncDriftCorrected {
+		t.Fatalf("unexpected outcome got=%q expected=%q", res.Outcome, SyncDriftCorrected)
+	}
+}
+
+func TestDryRunDelete(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	res, err := mgr.DryRunDelete(defaultConfName)
+	if err != nil || res.Outcome != SyncUnchanged {
+		t.Fatalf("unexpected result for a missing file: %+v err=%v", res, err)
+	}
+
+	if _, err := mgr.HandleSync(lh, ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Create: true}); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	res, err = mgr.DryRunDelete(defaultConfName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Outcome != SyncDeleted || !strings.Contains(res.Diff, "\n-foo=1\n") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if !mgr.Status(defaultConfName).FileExists {
+		t.Fatalf("unexpected file removal")
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// LockSuffix is appended to the configuration file name to get the name
+// of the sidecar lock file. Applications which want to coordinate with the
+// Manager must lock the same file, using the same LockMode.
+const LockSuffix = ".lock"
+
+// DefaultLockTimeout is how long the Manager waits for the lock by default.
+const DefaultLockTimeout = 5 * time.Second
+
+// lockPollInterval is how often the Manager retries to acquire a busy lock.
+const lockPollInterval = 10 * time.Millisecond
+
+// LockMode is the kind of advisory lock the Manager acquires on the sidecar lock file
+type LockMode string
+
+const (
+	// LockNone disables the cross-process locking. This is the default.
+	LockNone LockMode = "none"
+	// LockFlock uses BSD-style locks (flock(2)), owned by the open file description.
+	LockFlock LockMode = "flock"
+	// LockFcntl uses POSIX record locks (fcntl(2) F_SETLK), owned by the process.
+	LockFcntl LockMode = "fcntl"
+)
+
+var (
+	// ErrLockTimeout is returned if the lock could not be acquired within the configured timeout.
+	ErrLockTimeout = errors.New("timed out acquiring the configuration file lock")
+	// ErrUnsupportedLockMode is returned if the requested LockMode is unknown or not supported on this platform.
+	ErrUnsupportedLockMode = errors.New("unsupported lock mode")
+)
+
+// ParseLockMode converts the given string to a LockMode, validating it.
+func ParseLockMode(val string) (LockMode, error) {
+	switch mode := LockMode(strings.ToLower(val)); mode {
+	case "", LockNone:
+		return LockNone, nil
+	case LockFlock, LockFcntl:
+		return mode, nil
+	default:
+		return LockNone, fmt.Errorf("%w: %q", ErrUnsupportedLockMode, val)
+	}
+}
+
+// IsLockFile returns true if the given name is a sidecar lock file.
+func IsLockFile(name string) bool {
+	return strings.HasSuffix(name, LockSuffix)
+}
+
+// advisoryLock is an acquired advisory lock. Must be released calling Unlock.
+type advisoryLock struct {
+	file *os.File
+	mode LockMode
+}
+
+// acquireLock tries to lock the sidecar lock file of the given configuration file
+// until it succeeds or the timeout expires. If the mode is LockNone, acquireLock
+// succeeds immediately returning a nil lock, which is safe to Unlock.
+func acquireLock(fullPath string, mode LockMode, timeout time.Duration) (*advisoryLock, error) {
+	if mode == "" || mode == LockNone {
+		return nil, nil
+	}
+	lockPath := fullPath + LockSuffix
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %q: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file, mode)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to lock %q: %w", lockPath, err)
+		}
+		if locked {
+			return &advisoryLock{file: file, mode: mode}, nil
+		}
+		if time.Now().After(deadline) {
+			_ = file.Close()
+			return nil, fmt.Errorf("%w: %q held for more than %v", ErrLockTimeout, lockPath, timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// removeLockFile removes the sidecar lock file of the given configuration file, which is
+// going away. Applications still waiting on the lock file end up locking the removed file,
+// which is harmless since there is no configuration file to coordinate on anymore.
+func removeLockFile(fullPath string, mode LockMode) error {
+	if mode == "" || mode == LockNone {
+		return nil
+	}
+	lockPath := fullPath + LockSuffix
+	if err := os.Remove(lockPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock file %q: %w", lockPath, err)
+	}
+	return nil
+}
+
+// Unlock releases the lock. The lock file is intentionally left in place:
+// removing it would race with other processes waiting on it.
+func (al *advisoryLock) Unlock() error {
+	if al == nil {
+		return nil
+	}
+	err := unlock(al.file, al.mode)
+	return errors.Join(err, al.file.Close())
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock attempts to acquire an exclusive lock without blocking.
+// Returns true if the lock was acquired, false if it is held by someone else.
+func tryLock(file *os.File, mode LockMode) (bool, error) {
+	var err error
+	switch mode {
+	case LockFlock:
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	case LockFcntl:
+		err = syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &syscall.Flock_t{
+			Type:   syscall.F_WRLCK,
+			Whence: 0, // SEEK_SET
+		})
+	default:
+		return false, ErrUnsupportedLockMode
+	}
+	if errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EACCES) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(file *os.File, mode LockMode) error {
+	switch mode {
+	case LockFlock:
+		return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	case LockFcntl:
+		return syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &syscall.Flock_t{
+			Type:   syscall.F_UNLCK,
+			Whence: 0, // SEEK_SET
+		})
+	default:
+		return ErrUnsupportedLockMode
+	}
+}
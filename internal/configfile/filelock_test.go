@@ -0,0 +1,187 @@
+//go:build linux
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/testr"
+)
+
+func TestParseLockMode(t *testing.T) {
+	type testCase struct {
+		value        string
+		expectedMode LockMode
+		expectedErr  error
+	}
+
+	testCases := []testCase{
+		{value: "", expectedMode: LockNone},
+		{value: "none", expectedMode: LockNone},
+		{value: "flock", expectedMode: LockFlock},
+		{value: "FCNTL", expectedMode: LockFcntl},
+		{value: "lockf", expectedMode: LockNone, expectedErr: ErrUnsupportedLockMode},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.value, func(t *testing.T) {
+			mode, err := ParseLockMode(tcase.value)
+			if !errors.Is(err, tcase.expectedErr) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, tcase.expectedErr)
+			}
+			if mode != tcase.expectedMode {
+				t.Fatalf("unexpected mode got=%v expected=%v", mode, tcase.expectedMode)
+			}
+		})
+	}
+}
+
+func TestLockFreeSync(t *testing.T) {
+	for _, mode := range []LockMode{LockFlock, LockFcntl} {
+		t.Run(string(mode), func(t *testing.T) {
+			lh := testr.New(t)
+			tmpDir := t.TempDir()
+			mgr := NewManagerWithOptions(tmpDir, Options{
+				LockMode:    mode,
+				LockTimeout: 100 * time.Millisecond,
+			})
+			_, err := mgr.HandleSync(lh, ConfigRequest{
+				Filename: defaultConfName,
+				Content:  minimalConfContent,
+				Create:   true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected sync error: %v", err)
+			}
+			// the lock file is left behind on purpose
+			if _, err := os.Stat(filepath.Join(tmpDir, defaultConfName+LockSuffix)); err != nil {
+				t.Fatalf("missing lock file: %v", err)
+			}
+		})
+	}
+}
+
+func TestLockHeldByApplication(t *testing.T) {
+	lh := testr.New(t)
+	tmpDir := t.TempDir()
+	mgr := NewManagerWithOptions(tmpDir, Options{
+		LockMode:    LockFlock,
+		LockTimeout: 100 * time.Millisecond,
+	})
+
+	// flock(2) locks are bound to the open file description, so a separate
+	// open in the same process behaves like another application would.
+	appLock, err := os.OpenFile(filepath.Join(tmpDir, defaultConfName+LockSuffix), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("cannot open the lock file: %v", err)
+	}
+	defer appLock.Close()
+	if err := syscall.Flock(int(appLock.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatalf("cannot lock the lock file: %v", err)
+	}
+
+	_, err = mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	st := mgr.Status(defaultConfName)
+	if st.FileExists {
+		t.Fatalf("file written while locked")
+	}
+	if st.LastWriteErrorReason != ErrorReasonLockTimeout {
+		t.Fatalf("unexpected error reason: %q", st.LastWriteErrorReason)
+	}
+
+	if err := syscall.Flock(int(appLock.Fd()), syscall.LOCK_UN); err != nil {
+		t.Fatalf("cannot unlock the lock file: %v", err)
+	}
+	_, err = mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	st = mgr.Status(defaultConfName)
+	if !st.FileExists || st.LastWriteErrorReason != "" {
+		t.Fatalf("unexpected status after unlock: %+v", st)
+	}
+}
+
+func TestCleanAllKeepsLockFiles(t *testing.T) {
+	lh := testr.New(t)
+	tmpDir := t.TempDir()
+	lockPath := filepath.Join(tmpDir, defaultConfName+LockSuffix)
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatalf("cannot create the lock file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, defaultConfName), []byte(minimalConfContent), 0644); err != nil {
+		t.Fatalf("cannot create the stale file: %v", err)
+	}
+
+	mgr := NewManagerWithOptions(tmpDir, Options{LockMode: LockFlock})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Fatalf("lock file removed: %v", err)
+	}
+	if ok, _ := FileExists(filepath.Join(tmpDir, defaultConfName)); ok {
+		t.Fatalf("stale file not removed")
+	}
+}
+
+func TestDeleteRemovesLockFile(t *testing.T) {
+	lh := testr.New(t)
+	tmpDir := t.TempDir()
+	mgr := NewManagerWithOptions(tmpDir, Options{LockMode: LockFlock})
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	lockPath := filepath.Join(tmpDir, defaultConfName+LockSuffix)
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Fatalf("lock file not created: %v", err)
+	}
+
+	if err := mgr.Delete(defaultConfName); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("lock file not removed: %v", err)
+	}
+	// deleting again is fine
+	if err := mgr.Delete(defaultConfName); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"golab.io/kubedredger/internal/metrics"
+)
+
+// DefaultHistorySize is the number of versions kept for each file, unless configured otherwise
+const DefaultHistorySize = 3
+
+var (
+	// ErrNoHistory is returned when reverting a file with no version to revert to.
+	ErrNoHistory = errors.New("no previous version to revert to")
+)
+
+// Version is a state of a file replaced by a write. The history lives in memory:
+// it is lost when the Manager is recreated, like it happens on restarts.
+type Version struct {
+	// Revision is the revision of the request which wrote the content, if known (see ConfigRequest.Revision)
+	Revision string
+	// Exists is false if the file did not exist, like before being created
+	Exists bool
+	// Content is the content of the file, if it existed
+	Content string
+	// Mode is the permission bits of the file, if it existed
+	Mode fs.FileMode
+	// Drifted is true if the content was changed outside of the Manager, and replaced by a drift correction
+	Drifted bool
+	// Replaced is the time the version was replaced
+	Replaced time.Time
+}
+
+// History returns the versions replaced by the writes to the given file, the most recent first.
+func (mgr *Manager) History(fileName string) []Version {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	res := slices.Clone(mgr.history[fileName])
+	slices.Reverse(res)
+	return res
+}
+
+// Revert restores the most recent version in the history of the given file, and drops it from the history.
+// The drifted versions are skipped, and dropped too: the drift was corrected, so it is not a version to go back to.
+// Restoring a version of a file which did not exist removes the file. Unlike HandleSync, no quota is enforced,
+// because the version was already accepted. Returns ErrNoHistory if there is no version to revert to.
+// On success, returns the version restored.
+func (mgr *Manager) Revert(lh logr.Logger, fileName string) (Version, error) {
+	fl := mgr.fileLock(fileName)
+	fl.Lock()
+	defer fl.Unlock()
+
+	ver, idx, ok := mgr.lastVersion(fileName)
+	if !ok {
+		return Version{}, ErrNoHistory
+	}
+	err := mgr.restore(lh, fileName, ver)
+	mgr.setError(fileName, err)
+	if err != nil {
+		return Version{}, err
+	}
+	mgr.truncateHistory(fileName, idx)
+	return ver, nil
+}
+
+func (mgr *Manager) restore(lh logr.Logger, fileName string, ver Version) error {
+	fullPath := filepath.Join(mgr.path, fileName)
+	al, err := acquireLock(fullPath, mgr.opts.LockMode, mgr.opts.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := al.Unlock(); err != nil {
+			lh.Error(err, "failed to release file lock", "path", fullPath)
+		}
+	}()
+
+	if !ver.Exists {
+		lh.Info("reverting configuration file", "path", fullPath, "revision", ver.Revision, "exists", false)
+		if err := mgr.storage.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete file %q: %w", fullPath, err)
+		}
+		// unlike Delete, the history is kept: the file may be reverted further
+		mgr.forgetContent(fileName)
+		return nil
+	}
+	lh.Info("reverting configuration file", "path", fullPath, "revision", ver.Revision, "perms", ver.Mode)
+	if err := mgr.storage.WriteFileAtomic(fullPath, []byte(ver.Content), ver.Mode); err != nil {
+		return err
+	}
+	mgr.setWritten(fileName, []byte(ver.Content), ver.Revision)
+	return nil
+}
+
+// forgetContent records the given file was removed, keeping its history.
+func (mgr *Manager) forgetContent(fileName string) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	delete(mgr.written, fileName)
+	delete(mgr.revisions, fileName)
+	metrics.ManagedFiles.Set(float64(len(mgr.written)))
+}
+
+// revision returns the revision of the request which last wrote the given file, if known.
+func (mgr *Manager) revision(fileName string) string {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	return mgr.revisions[fileName]
+}
+
+// pushVersion records the given version of the file was just replaced, dropping the oldest ones beyond HistorySize.
+func (mgr *Manager) pushVersion(fileName string, ver Version) {
+	if mgr.opts.HistorySize < 0 {
+		return
+	}
+	ver.Replaced = time.Now()
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	history := append(mgr.history[fileName], ver)
+	if extra := len(history) - mgr.opts.HistorySize; extra > 0 {
+		history = slices.Delete(history, 0, extra)
+	}
+	mgr.history[fileName] = history
+}
+
+// lastVersion returns the most recent version of the given file which did not drift, and its index in the history.
+func (mgr *Manager) lastVersion(fileName string) (Version, int, bool) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	history := mgr.history[fileName]
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		if !history[idx].Drifted {
+			return history[idx], idx, true
+		}
+	}
+	return Version{}, 0, false
+}
+
+// truncateHistory drops the versions of the given file from the given index on.
+func (mgr *Manager) truncateHistory(fileName string, idx int) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	history := mgr.history[fileName]
+	if idx < len(history) {
+		mgr.history[fileName] = history[:idx]
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"k8s.io/utils/ptr"
+)
+
+func TestRevert(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	fullPath := filepath.Join(memoryRoot, defaultConfName)
+
+	if _, err := mgr.Revert(lh, defaultConfName); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("unexpected error got=%v expected=%v", err, ErrNoHistory)
+	}
+
+	requests := []ConfigRequest{
+		{Filename: defaultConfName, Content: "foo=1\n", Create: true, Revision: "rev1"},
+		{Filename: defaultConfName, Content: "foo=2\n", Permission: ptr.To[uint32](0600), Revision: "rev2"},
+		// unchanged, not recorded in the history
+		{Filename: defaultConfName, Content: "foo=2\n", Permission: ptr.To[uint32](0600), Revision: "rev2"},
+		{Filename: defaultConfName, Content: "foo=3\n", Revision: "rev3"},
+	}
+	for _, req := range requests {
+		if _, err := mgr.HandleSync(lh, req); err != nil {
+			t.Fatalf("unexpected sync error: %v", err)
+		}
+	}
+
+	history := mgr.History(defaultConfName)
+	if len(history) != 3 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	if history[0].Revision != "rev2" || history[1].Revision != "rev1" || history[2].Exists {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	ver, err := mgr.Revert(lh, defaultConfName)
+	if err != nil {
+		t.Fatalf("unexpected revert error: %v", err)
+	}
+	if ver.Revision != "rev2" {
+		t.Fatalf("unexpected version reverted to got=%q expected=%q", ver.Revision, "rev2")
+	}
+	st := mgr.Status(defaultConfName)
+	if st.Content != "foo=2\n" || st.Mode != FileModeFromUnix(0600) {
+		t.Fatalf("unexpected state after revert: content=%q mode=%v", st.Content, st.Mode)
+	}
+	if _, err := mgr.HandleSync(lh, requests[2]); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if got := len(mgr.History(defaultConfName)); got != 2 {
+		t.Fatalf("unexpected history length got=%d expected=2", got)
+	}
+
+	if _, err := mgr.Revert(lh, defaultConfName); err != nil {
+		t.Fatalf("unexpected revert error: %v", err)
+	}
+	// reverting the creation removes the file
+	ver, err = mgr.Revert(lh, defaultConfName)
+	if err != nil {
+		t.Fatalf("unexpected revert error: %v", err)
+	}
+	if ver.Exists {
+		t.Fatalf("unexpected version reverted to: %+v", ver)
+	}
+	if _, err := storage.Stat(fullPath); err == nil {
+		t.Fatalf("unexpected file left after reverting its creation")
+	}
+	if _, err := mgr.Revert(lh, defaultConfName); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("unexpected error got=%v expected=%v", err, ErrNoHistory)
+	}
+}
+
+func TestRevertSkipsDrift(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	fullPath := filepath.Join(memoryRoot, defaultConfName)
+
+	rev2 := ConfigRequest{Filename: defaultConfName, Content: "foo=2\n", Revision: "rev2"}
+	for _, req := range []ConfigRequest{
+		{Filename: defaultConfName, Content: "foo=1\n", Create: true, Revision: "rev1"},
+		rev2,
+	} {
+		if _, err := mgr.HandleSync(lh, req); err != nil {
+			t.Fatalf("unexpected sync error: %v", err)
+		}
+	}
+	// someone else changed the file, and the drift is corrected
+	if err := storage.WriteFileAtomic(fullPath, []byte("foo=drift\n"), 0644); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if outcome, err := mgr.HandleSync(lh, rev2); err != nil || outcome != SyncDriftCorrected {
+		t.Fatalf("unexpected sync result outcome=%q err=%v", outcome, err)
+	}
+	if history := mgr.History(defaultConfName); len(history) != 3 || !history[0].Drifted {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	ver, err := mgr.Revert(lh, defaultConfName)
+	if err != nil {
+		t.Fatalf("unexpected revert error: %v", err)
+	}
+	if ver.Revision != "rev1" {
+		t.Fatalf("unexpected version reverted to got=%q expected=%q", ver.Revision, "rev1")
+	}
+	if st := mgr.Status(defaultConfName); st.Content != "foo=1\n" {
+		t.Fatalf("unexpected content after revert: %q", st.Content)
+	}
+	// the drifted version is dropped along with the restored one
+	if history := mgr.History(defaultConfName); len(history) != 1 || history[0].Exists {
+		t.Fatalf("unexpected history after revert: %+v", history)
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	type testCase struct {
+		name           string
+		historySize    int
+		expectedLength int
+	}
+
+	testCases := []testCase{
+		{name: "default", expectedLength: DefaultHistorySize},
+		{name: "custom", historySize: 1, expectedLength: 1},
+		{name: "disabled", historySize: -1, expectedLength: 0},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			lh := testr.New(t)
+			mgr := NewManagerWithOptions(memoryRoot, Options{Storage: NewMemoryStorage(), HistorySize: tcase.historySize})
+			if err := mgr.CleanAll(lh); err != nil {
+				t.Fatalf("unexpected clean error: %v", err)
+			}
+			for _, content := range []string{"1", "2", "3", "4", "5"} {
+				if _, err := mgr.HandleSync(lh, ConfigRequest{Filename: defaultConfName, Content: content, Create: true}); err != nil {
+					t.Fatalf("unexpected sync error: %v", err)
+				}
+			}
+			history := mgr.History(defaultConfName)
+			if len(history) != tcase.expectedLength {
+				t.Fatalf("unexpected history length got=%d expected=%d", len(history), tcase.expectedLength)
+			}
+			if len(history) > 0 && history[0].Content != "4" {
+				t.Fatalf("unexpected most recent version %+v", history[0])
+			}
+		})
+	}
+}
+
+func TestHistoryDroppedOnDelete(t *testing.T) {
+	lh := testr.New(t)
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: NewMemoryStorage()})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	if _, err := mgr.HandleSync(lh, ConfigRequest{Filename: defaultConfName, Content: "foo=1\n", Create: true}); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if err := mgr.Delete(defaultConfName); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if history := mgr.History(defaultConfName); len(history) != 0 {
+		t.Fatalf("unexpected history after delete: %+v", history)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// NamespacesDir is the directory within the root holding the per-namespace directories.
+	// Only the files owned by namespaced objects live there.
+	NamespacesDir = "namespaces"
+)
+
+// NamespacedFilename returns the name, relative to the root, of the given file owned by the given namespace.
+func NamespacedFilename(namespace, fileName string) string {
+	return filepath.Join(NamespacesDir, namespace, fileName)
+}
+
+// IsNamespaced returns true if the given name, relative to the root, is reserved to namespaced objects.
+func IsNamespaced(fileName string) bool {
+	return fileName == NamespacesDir || strings.HasPrefix(fileName, NamespacesDir+string(filepath.Separator))
+}
+
+// NamespaceOf returns the namespace owning the given file, relative to the root,
+// and true; returns false if the file is not owned by any namespace.
+func NamespaceOf(fileName string) (string, bool) {
+	rest, ok := strings.CutPrefix(fileName, NamespacesDir+string(filepath.Separator))
+	if !ok {
+		return "", false
+	}
+	namespace, _, ok := strings.Cut(rest, string(filepath.Separator))
+	if !ok || namespace == "" {
+		return "", false
+	}
+	return namespace, true
+}
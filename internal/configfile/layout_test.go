@@ -0,0 +1,47 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"testing"
+)
+
+func TestNamespaceOf(t *testing.T) {
+	type testCase struct {
+		fileName          string
+		expectedNamespace string
+		expectedOK        bool
+	}
+
+	testCases := []testCase{
+		{fileName: NamespacedFilename("team-a", "golab.conf"), expectedNamespace: "team-a", expectedOK: true},
+		{fileName: NamespacedFilename("team-a", "sub/golab.conf"), expectedNamespace: "team-a", expectedOK: true},
+		{fileName: "golab.conf"},
+		{fileName: "namespaces.conf"},
+		{fileName: NamespacesDir},
+		{fileName: "namespaces/team-a"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.fileName, func(t *testing.T) {
+			namespace, ok := NamespaceOf(tcase.fileName)
+			if namespace != tcase.expectedNamespace || ok != tcase.expectedOK {
+				t.Fatalf("unexpected result got=(%q, %v) expected=(%q, %v)", namespace, ok, tcase.expectedNamespace, tcase.expectedOK)
+			}
+		})
+	}
+}
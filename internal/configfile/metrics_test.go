@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"golab.io/kubedredger/internal/metrics"
+)
+
+func TestMetricsDriftAndWrites(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	drifts := testutil.ToFloat64(metrics.DriftCorrectionsTotal)
+	written := testutil.ToFloat64(metrics.BytesWrittenTotal)
+
+	request := ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	}
+	if _, err := mgr.HandleSync(lh, request); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.ManagedFiles); got != 1 {
+		t.Errorf("unexpected managed files: %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.LastSuccessfulSync.WithLabelValues(defaultConfName)); got == 0 {
+		t.Errorf("missing last successful sync timestamp")
+	}
+
+	// resync with no changes is not a drift, and does not write
+	if _, err := mgr.HandleSync(lh, request); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.DriftCorrectionsTotal) - drifts; got != 0 {
+		t.Errorf("unexpected drift corrections: %v", got)
+	}
+
+	// someone else changed the file
+	confPath := filepath.Join(memoryRoot, defaultConfName)
+	if err := storage.WriteFileAtomic(confPath, []byte("[main]\nfoo=baz\n"), 0644); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if _, err := mgr.HandleSync(lh, request); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.DriftCorrectionsTotal) - drifts; got != 1 {
+		t.Errorf("unexpected drift corrections: %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.BytesWrittenTotal) - written; got != float64(2*len(minimalConfContent)) {
+		t.Errorf("unexpected bytes written: %v", got)
+	}
+
+	if err := mgr.Delete(defaultConfName); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.ManagedFiles); got != 0 {
+		t.Errorf("unexpected managed files: %v", got)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+var (
+	// ErrQuotaExceeded is returned if writing the content would exceed the per-file or the per-root quota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInsufficientSpace is returned if the filesystem holding the root has not enough free space.
+	ErrInsufficientSpace = errors.New("insufficient free space")
+)
+
+// maxFileSize returns the effective per-file quota: the lowest between the
+// Manager limit and the request limit. Returns false if there is no limit.
+func (mgr *Manager) maxFileSize(request ConfigRequest) (int64, bool) {
+	limit, ok := mgr.opts.MaxFileSize, mgr.opts.MaxFileSize > 0
+	if request.MaxSize != nil && (!ok || *request.MaxSize < limit) {
+		limit, ok = *request.MaxSize, true
+	}
+	return limit, ok
+}
+
+// checkQuota verifies the given content can be written on fullPath without exceeding
+// the configured quotas, and that the storage has enough free space left.
+func (mgr *Manager) checkQuota(fullPath string, request ConfigRequest) error {
+	size := int64(len(request.Content))
+	if limit, ok := mgr.maxFileSize(request); ok && size > limit {
+		return fmt.Errorf("%w: content of %q is %d bytes, limit is %d bytes", ErrQuotaExceeded, request.Filename, size, limit)
+	}
+
+	if mgr.opts.MaxRootSize > 0 {
+		used, err := mgr.rootUsage(mgr.path, fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to compute the usage of %q: %w", mgr.path, err)
+		}
+		if used+size > mgr.opts.MaxRootSize {
+			return fmt.Errorf("%w: root %q would use %d bytes, limit is %d bytes", ErrQuotaExceeded, mgr.path, used+size, mgr.opts.MaxRootSize)
+		}
+	}
+
+	dir := mgr.existingDir(filepath.Dir(fullPath))
+	free, err := mgr.storage.FreeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check the free space of %q: %w", dir, err)
+	}
+	// the old content is still in place while the new one is staged, hence we need room for all of it
+	needed := uint64(size) + mgr.opts.MinFreeSpace //nolint:gosec // size is a length, never negative
+	if free < needed {
+		return fmt.Errorf("%w: %d bytes needed, %d bytes available", ErrInsufficientSpace, needed, free)
+	}
+	return nil
+}
+
+// existingDir returns the given directory or, if it does not exist yet, its closest existing ancestor
+// within the root, like it happens when the quota is checked without writing the file (see DryRunSync).
+func (mgr *Manager) existingDir(dir string) string {
+	root := filepath.Clean(mgr.path)
+	for dir != root && dir != filepath.Dir(dir) {
+		if _, err := mgr.storage.Stat(dir); err == nil {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// rootUsage returns the bytes used by the regular files within dir, skipping the excluded path.
+func (mgr *Manager) rootUsage(dir, excluded string) (int64, error) {
+	entries, err := mgr.storage.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var used int64
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		if entryPath == excluded {
+			continue
+		}
+		if entry.IsDir() {
+			dirUsage, err := mgr.rootUsage(entryPath, excluded)
+			if err != nil {
+				return 0, err
+			}
+			used += dirUsage
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		finfo, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // removed meanwhile
+		}
+		if err != nil {
+			return 0, err
+		}
+		used += finfo.Size()
+	}
+	return used, nil
+}
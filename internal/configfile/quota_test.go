@@ -0,0 +1,180 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"k8s.io/utils/ptr"
+)
+
+func TestQuotaPerFile(t *testing.T) {
+	type testCase struct {
+		name        string
+		maxFileSize int64
+		maxSize     *int64
+		content     string
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:    "unlimited",
+			content: strings.Repeat("x", 4096),
+		},
+		{
+			name:        "within the agent limit",
+			maxFileSize: 16,
+			content:     strings.Repeat("x", 16),
+		},
+		{
+			name:        "exceeds the agent limit",
+			maxFileSize: 16,
+			content:     strings.Repeat("x", 17),
+			expectedErr: ErrQuotaExceeded,
+		},
+		{
+			name:        "request lowers the agent limit",
+			maxFileSize: 16,
+			maxSize:     ptr.To[int64](8),
+			content:     strings.Repeat("x", 9),
+			expectedErr: ErrQuotaExceeded,
+		},
+		{
+			name:        "request can't raise the agent limit",
+			maxFileSize: 16,
+			maxSize:     ptr.To[int64](32),
+			content:     strings.Repeat("x", 17),
+			expectedErr: ErrQuotaExceeded,
+		},
+		{
+			name:        "request limit without agent limit",
+			maxSize:     ptr.To[int64](0),
+			content:     "x",
+			expectedErr: ErrQuotaExceeded,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			lh := testr.New(t)
+			mgr := NewManagerWithOptions(memoryRoot, Options{
+				Storage:     NewMemoryStorage(),
+				MaxFileSize: tcase.maxFileSize,
+			})
+			if err := mgr.CleanAll(lh); err != nil {
+				t.Fatalf("unexpected clean error: %v", err)
+			}
+			_, err := mgr.HandleSync(lh, ConfigRequest{
+				Filename: defaultConfName,
+				Content:  tcase.content,
+				Create:   true,
+				MaxSize:  tcase.maxSize,
+			})
+			if !errors.Is(err, tcase.expectedErr) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, tcase.expectedErr)
+			}
+			st := mgr.Status(defaultConfName)
+			if tcase.expectedErr == nil {
+				if !st.FileExists || st.LastWriteErrorReason != "" {
+					t.Fatalf("unexpected status: %+v", st)
+				}
+				return
+			}
+			if st.FileExists || st.LastWriteErrorReason != ErrorReasonQuotaExceeded {
+				t.Fatalf("unexpected status: %+v", st)
+			}
+		})
+	}
+}
+
+func TestQuotaPerRoot(t *testing.T) {
+	lh := testr.New(t)
+	mgr := NewManagerWithOptions(memoryRoot, Options{
+		Storage:     NewMemoryStorage(),
+		MaxRootSize: 32,
+	})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	sync := func(fileName string, size int) error {
+		_, err := mgr.HandleSync(lh, ConfigRequest{
+			Filename: fileName,
+			Content:  strings.Repeat("x", size),
+			Create:   true,
+		})
+		return err
+	}
+
+	if err := sync("a.conf", 16); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if err := sync("b.conf", 16); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if err := sync("c.conf", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	// replacing a file only accounts the new size
+	if err := sync("b.conf", 8); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if err := sync("c.conf", 8); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	if err := sync("a.conf", 17); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+}
+
+func TestFreeSpacePreflight(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	storage.SetCapacity(64)
+	mgr := NewManagerWithOptions(memoryRoot, Options{
+		Storage:      storage,
+		MinFreeSpace: 16,
+	})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  strings.Repeat("x", 48),
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	_, err = mgr.HandleSync(lh, ConfigRequest{
+		Filename: "other.conf",
+		Content:  "x",
+		Create:   true,
+	})
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	st := mgr.Status("other.conf")
+	if st.FileExists || st.LastWriteErrorReason != ErrorReasonInsufficientSpace {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"encoding/json"
+
+	"k8s.io/utils/ptr"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/contenthash"
+)
+
+// RequestFromSpec builds the request for the given file, relative to the root.
+// The file name can differ from the one in the spec, like it happens for namespaced objects.
+func RequestFromSpec(fileName string, desired workshopv1alpha2.ConfigurationSpec) ConfigRequest {
+	res := ConfigRequest{
+		Filename: fileName,
+		Content:  desired.Content,
+		Create:   desired.Create,
+		Revision: SpecRevision(desired),
+	}
+	if desired.Permission != nil {
+		res.Permission = ptr.To(*desired.Permission)
+	}
+	if desired.MaxSize != nil {
+		res.MaxSize = ptr.To(*desired.MaxSize)
+	}
+	return res
+}
+
+// SpecRevision returns the revision of the given spec: the hash of all its fields but the rollout strategy
+// and the maintenance schedule, which do not affect the file.
+func SpecRevision(spec workshopv1alpha2.ConfigurationSpec) string {
+	spec.Rollout = nil
+	spec.Schedule = nil
+	// the spec is made only of plain fields, so it can always be encoded
+	data, _ := json.Marshal(spec)
+	return contenthash.Sum(data)
+}
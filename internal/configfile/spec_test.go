@@ -0,0 +1,55 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+)
+
+func TestSpecRevision(t *testing.T) {
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Filename: "foo.conf",
+		Content:  "foo=1\n",
+	}
+	revision := SpecRevision(spec)
+
+	paused := spec
+	paused.Rollout = &workshopv1alpha2.RolloutStrategy{Paused: true}
+	if got := SpecRevision(paused); got != revision {
+		t.Fatalf("unexpected revision change on rollout strategy change got=%q expected=%q", got, revision)
+	}
+
+	scheduled := spec
+	scheduled.Schedule = &workshopv1alpha2.MaintenanceSchedule{
+		Windows: []workshopv1alpha2.MaintenanceWindow{{Start: "@daily", Duration: metav1.Duration{Duration: time.Hour}}},
+	}
+	if got := SpecRevision(scheduled); got != revision {
+		t.Fatalf("unexpected revision change on schedule change got=%q expected=%q", got, revision)
+	}
+
+	updated := spec
+	updated.Permission = ptr.To[uint32](0600)
+	if got := SpecRevision(updated); got == revision {
+		t.Fatalf("unexpected revision %q unchanged on permission change", got)
+	}
+}
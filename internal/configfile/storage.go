@@ -0,0 +1,126 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TempFilePrefix is the prefix of the temporary files used to stage the
+// configuration content before it is moved in its final place.
+const TempFilePrefix = "kubedredger-"
+
+// IsTempFile returns true if the given name looks like a temporary file
+// created by OSStorage.WriteFileAtomic.
+func IsTempFile(name string) bool {
+	suffix, ok := strings.CutPrefix(filepath.Base(name), TempFilePrefix)
+	if !ok || suffix == "" {
+		return false
+	}
+	return strings.Trim(suffix, "0123456789") == ""
+}
+
+// Storage abstracts the filesystem operations the Manager needs.
+// All the names are full paths. Errors must be compatible with the ones
+// returned by the os package, so callers can use errors.Is(err, fs.ErrNotExist)
+// and similar checks.
+type Storage interface {
+	// Stat returns the FileInfo describing the named file.
+	Stat(name string) (fs.FileInfo, error)
+	// ReadFile returns the content of the named file.
+	ReadFile(name string) ([]byte, error)
+	// WriteFileAtomic replaces the content of the named file with data, setting
+	// the given permissions. Readers must observe either the old or the new content.
+	WriteFileAtomic(name string, data []byte, perm fs.FileMode) error
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// RemoveAll removes the named path and any children it contains.
+	RemoveAll(name string) error
+	// ReadDir returns the entries of the named directory, sorted by filename.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// MkdirAll creates the named directory, along with any necessary parents.
+	MkdirAll(name string, perm fs.FileMode) error
+	// FreeSpace returns the bytes available to unprivileged users on the
+	// filesystem holding the named path.
+	FreeSpace(name string) (uint64, error)
+}
+
+// OSStorage is a Storage backed by the host filesystem.
+type OSStorage struct{}
+
+// NewOSStorage creates a Storage using the host filesystem.
+func NewOSStorage() OSStorage {
+	return OSStorage{}
+}
+
+func (OSStorage) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSStorage) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFileAtomic writes data in a temporary file, then renames it over the destination.
+// The temporary file is created in the same directory of the destination, because
+// rename(2) can't cross filesystem boundaries and the destination directory may be
+// a mount point on its own.
+func (OSStorage) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(name), TempFilePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+	if err := tmpFile.Chmod(perm); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed to set permissions on temporary file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), name); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return nil
+}
+
+func (OSStorage) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSStorage) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (OSStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSStorage) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// Operation identifies a Storage method, for fault injection purposes.
+type Operation string
+
+const (
+	OpStat            Operation = "stat"
+	OpReadFile        Operation = "readfile"
+	OpWriteFileAtomic Operation = "writefile"
+	OpRemove          Operation = "remove"
+	OpRemoveAll       Operation = "removeall"
+	OpReadDir         Operation = "readdir"
+	OpMkdirAll        Operation = "mkdirall"
+	OpFreeSpace       Operation = "freespace"
+)
+
+// FaultyStorage wraps a Storage, failing the operations on request.
+// It is meant for tests to simulate errors like ENOSPC, EACCES or EROFS
+// deterministically. FaultyStorage is safe for concurrent use.
+type FaultyStorage struct {
+	Storage
+	lock   sync.Mutex
+	faults map[Operation]map[string]error
+}
+
+// NewFaultyStorage creates a FaultyStorage wrapping the given Storage.
+// No faults are injected initially.
+func NewFaultyStorage(storage Storage) *FaultyStorage {
+	return &FaultyStorage{
+		Storage: storage,
+		faults:  make(map[Operation]map[string]error),
+	}
+}
+
+// Inject makes the given operation fail with the given error when done on the
+// given path. If name is empty, the operation fails on every path.
+// The error is wrapped in a *fs.PathError like the os package does.
+func (fst *FaultyStorage) Inject(op Operation, name string, err error) {
+	fst.lock.Lock()
+	defer fst.lock.Unlock()
+	if fst.faults[op] == nil {
+		fst.faults[op] = make(map[string]error)
+	}
+	if name != "" {
+		name = cleanPath(name)
+	}
+	fst.faults[op][name] = err
+}
+
+// Reset removes all the injected faults.
+func (fst *FaultyStorage) Reset() {
+	fst.lock.Lock()
+	defer fst.lock.Unlock()
+	clear(fst.faults)
+}
+
+func (fst *FaultyStorage) fault(op Operation, name string) error {
+	fst.lock.Lock()
+	defer fst.lock.Unlock()
+	errs := fst.faults[op]
+	if err, ok := errs[cleanPath(name)]; ok {
+		return pathError(string(op), name, err)
+	}
+	if err, ok := errs[""]; ok {
+		return pathError(string(op), name, err)
+	}
+	return nil
+}
+
+func (fst *FaultyStorage) Stat(name string) (fs.FileInfo, error) {
+	if err := fst.fault(OpStat, name); err != nil {
+		return nil, err
+	}
+	return fst.Storage.Stat(name)
+}
+
+func (fst *FaultyStorage) ReadFile(name string) ([]byte, error) {
+	if err := fst.fault(OpReadFile, name); err != nil {
+		return nil, err
+	}
+	return fst.Storage.ReadFile(name)
+}
+
+func (fst *FaultyStorage) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	if err := fst.fault(OpWriteFileAtomic, name); err != nil {
+		return err
+	}
+	return fst.Storage.WriteFileAtomic(name, data, perm)
+}
+
+func (fst *FaultyStorage) Remove(name string) error {
+	if err := fst.fault(OpRemove, name); err != nil {
+		return err
+	}
+	return fst.Storage.Remove(name)
+}
+
+func (fst *FaultyStorage) RemoveAll(name string) error {
+	if err := fst.fault(OpRemoveAll, name); err != nil {
+		return err
+	}
+	return fst.Storage.RemoveAll(name)
+}
+
+func (fst *FaultyStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := fst.fault(OpReadDir, name); err != nil {
+		return nil, err
+	}
+	return fst.Storage.ReadDir(name)
+}
+
+func (fst *FaultyStorage) MkdirAll(name string, perm fs.FileMode) error {
+	if err := fst.fault(OpMkdirAll, name); err != nil {
+		return err
+	}
+	return fst.Storage.MkdirAll(name, perm)
+}
+
+func (fst *FaultyStorage) FreeSpace(name string) (uint64, error) {
+	if err := fst.fault(OpFreeSpace, name); err != nil {
+		return 0, err
+	}
+	return fst.Storage.FreeSpace(name)
+}
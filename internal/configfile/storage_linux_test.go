@@ -0,0 +1,90 @@
+//go:build linux
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+)
+
+// TestOSStorageCrossDeviceRoot checks the root can live on a filesystem different
+// from its parent, like it happens when the root is a mount point on its own.
+// Staging the temporary files outside the root would fail with EXDEV.
+func TestOSStorageCrossDeviceRoot(t *testing.T) {
+	lh := testr.New(t)
+	hostDir := t.TempDir()
+	backingDir, err := os.MkdirTemp("/dev/shm", "kubedredger-test-")
+	if err != nil {
+		t.Skipf("cannot create the backing directory: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(backingDir)
+	})
+	if deviceOf(t, hostDir) == deviceOf(t, backingDir) {
+		t.Skipf("%q and %q are on the same device", hostDir, backingDir)
+	}
+
+	root := filepath.Join(hostDir, "config.d")
+	if err := os.Symlink(backingDir, root); err != nil {
+		t.Fatalf("cannot link the root: %v", err)
+	}
+
+	mgr := NewManager(root)
+	_, err = mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	expectDirEntries(t, hostDir, "config.d")
+	expectDirEntries(t, backingDir, defaultConfName)
+}
+
+func TestStatusOwner(t *testing.T) {
+	lh := testr.New(t)
+	mgr := NewManager(t.TempDir())
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	st := mgr.Status(defaultConfName)
+	if expected := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()); st.Owner != expected {
+		t.Fatalf("unexpected owner got=%q expected=%q", st.Owner, expected)
+	}
+}
+
+func deviceOf(t *testing.T, path string) uint64 {
+	t.Helper()
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		t.Fatalf("cannot stat %q: %v", path, err)
+	}
+	return uint64(st.Dev) //nolint:unconvert // Dev is not uint64 on all the architectures
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"io/fs"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemoryStorage is a Storage which keeps everything in memory.
+// It is meant for tests. The root directory always exists.
+// MemoryStorage is safe for concurrent use.
+type MemoryStorage struct {
+	lock     sync.Mutex
+	files    map[string]*memoryFile
+	now      func() time.Time
+	capacity uint64
+}
+
+type memoryFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files: map[string]*memoryFile{
+			"/": {mode: fs.ModeDir | 0755},
+		},
+		now: time.Now,
+	}
+}
+
+func (ms *MemoryStorage) Stat(name string) (fs.FileInfo, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	mf, ok := ms.files[name]
+	if !ok {
+		return nil, pathError("stat", name, fs.ErrNotExist)
+	}
+	return mf.info(name), nil
+}
+
+func (ms *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	mf, ok := ms.files[name]
+	if !ok {
+		return nil, pathError("open", name, fs.ErrNotExist)
+	}
+	if mf.mode.IsDir() {
+		return nil, pathError("read", name, syscall.EISDIR)
+	}
+	return slices.Clone(mf.data), nil
+}
+
+func (ms *MemoryStorage) WriteFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	parent, ok := ms.files[filepath.Dir(name)]
+	if !ok {
+		return pathError("open", name, fs.ErrNotExist)
+	}
+	if !parent.mode.IsDir() {
+		return pathError("open", name, syscall.ENOTDIR)
+	}
+	if mf, ok := ms.files[name]; ok && mf.mode.IsDir() {
+		return pathError("rename", name, syscall.EISDIR)
+	}
+	if ms.capacity > 0 && uint64(len(data)) > ms.freeSpace() {
+		return pathError("write", name, syscall.ENOSPC)
+	}
+	ms.files[name] = &memoryFile{
+		data:    slices.Clone(data),
+		mode:    perm & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky),
+		modTime: ms.now(),
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) Remove(name string) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	mf, ok := ms.files[name]
+	if !ok {
+		return pathError("remove", name, fs.ErrNotExist)
+	}
+	if mf.mode.IsDir() && len(ms.children(name)) > 0 {
+		return pathError("remove", name, syscall.ENOTEMPTY)
+	}
+	delete(ms.files, name)
+	return nil
+}
+
+func (ms *MemoryStorage) RemoveAll(name string) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	for path := range ms.files {
+		if path == name || strings.HasPrefix(path, prefix) {
+			delete(ms.files, path)
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	mf, ok := ms.files[name]
+	if !ok {
+		return nil, pathError("open", name, fs.ErrNotExist)
+	}
+	if !mf.mode.IsDir() {
+		return nil, pathError("readdirent", name, syscall.ENOTDIR)
+	}
+	children := ms.children(name)
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(ms.files[child].info(child)))
+	}
+	return entries, nil
+}
+
+func (ms *MemoryStorage) MkdirAll(name string, perm fs.FileMode) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	name = cleanPath(name)
+	for path := name; ; path = filepath.Dir(path) {
+		mf, ok := ms.files[path]
+		if ok {
+			if !mf.mode.IsDir() {
+				return pathError("mkdir", path, syscall.ENOTDIR)
+			}
+			break
+		}
+		ms.files[path] = &memoryFile{
+			mode:    fs.ModeDir | (perm & fs.ModePerm),
+			modTime: ms.now(),
+		}
+	}
+	return nil
+}
+
+// SetCapacity sets the total bytes the MemoryStorage can hold.
+// Zero, the default, means unlimited.
+func (ms *MemoryStorage) SetCapacity(capacity uint64) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.capacity = capacity
+}
+
+func (ms *MemoryStorage) FreeSpace(_ string) (uint64, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return ms.freeSpace(), nil
+}
+
+// freeSpace must be called with the lock held.
+func (ms *MemoryStorage) freeSpace() uint64 {
+	if ms.capacity == 0 {
+		return math.MaxUint64
+	}
+	var used uint64
+	for _, mf := range ms.files {
+		used += uint64(len(mf.data))
+	}
+	if used >= ms.capacity {
+		return 0
+	}
+	return ms.capacity - used
+}
+
+// children returns the sorted full paths of the direct children of the given directory.
+// Must be called with the lock held.
+func (ms *MemoryStorage) children(dir string) []string {
+	var res []string
+	for path := range ms.files {
+		if path != dir && filepath.Dir(path) == dir {
+			res = append(res, path)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func (mf *memoryFile) info(name string) fs.FileInfo {
+	return memoryFileInfo{
+		name:    filepath.Base(name),
+		size:    int64(len(mf.data)),
+		mode:    mf.mode,
+		modTime: mf.modTime,
+	}
+}
+
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi memoryFileInfo) Name() string       { return fi.name }
+func (fi memoryFileInfo) Size() int64        { return fi.size }
+func (fi memoryFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi memoryFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memoryFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi memoryFileInfo) Sys() any           { return nil }
+
+func cleanPath(name string) string {
+	return filepath.Clean("/" + name)
+}
+
+func pathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
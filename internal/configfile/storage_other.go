@@ -0,0 +1,28 @@
+//go:build !linux
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"math"
+)
+
+// FreeSpace is not implemented on this platform, so it reports unlimited space.
+func (OSStorage) FreeSpace(_ string) (uint64, error) {
+	return math.MaxUint64, nil
+}
@@ -0,0 +1,399 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+)
+
+const memoryRoot = "/etc/workshop.d"
+
+func TestMemoryStorageLifecycle(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+
+	confPath := filepath.Join(memoryRoot, defaultConfName)
+	finfo, err := storage.Stat(confPath)
+	if err != nil {
+		t.Fatalf("unexpected stat error: %v", err)
+	}
+	if finfo.Mode() != 0644 {
+		t.Errorf("unexpected mode: %v", finfo.Mode())
+	}
+	st := mgr.Status(defaultConfName)
+	if !st.FileExists || st.Content != minimalConfContent || st.LastWriteError != "" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	entries, err := storage.ReadDir(memoryRoot)
+	if err != nil {
+		t.Fatalf("unexpected readdir error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != defaultConfName {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	if err := mgr.Delete(defaultConfName); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if _, err := storage.Stat(confPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("file exists after deletion: %v", err)
+	}
+}
+
+func TestMemoryStorageMissingRoot(t *testing.T) {
+	lh := testr.New(t)
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: NewMemoryStorage()})
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+}
+
+func TestFaultyStorageWrite(t *testing.T) {
+	type testCase struct {
+		name  string
+		fault error
+	}
+
+	testCases := []testCase{
+		{name: "no space left", fault: syscall.ENOSPC},
+		{name: "permission denied", fault: syscall.EACCES},
+		{name: "read-only filesystem", fault: syscall.EROFS},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			lh := testr.New(t)
+			storage := NewFaultyStorage(NewMemoryStorage())
+			mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+			if err := mgr.CleanAll(lh); err != nil {
+				t.Fatalf("unexpected clean error: %v", err)
+			}
+
+			storage.Inject(OpWriteFileAtomic, filepath.Join(memoryRoot, defaultConfName), tcase.fault)
+			_, err := mgr.HandleSync(lh, ConfigRequest{
+				Filename: defaultConfName,
+				Content:  minimalConfContent,
+				Create:   true,
+			})
+			if !errors.Is(err, tcase.fault) {
+				t.Fatalf("unexpected sync error: got=%v expected=%v", err, tcase.fault)
+			}
+			st := mgr.Status(defaultConfName)
+			if st.FileExists || st.LastWriteError == "" {
+				t.Fatalf("unexpected status: %+v", st)
+			}
+
+			// other files are not affected
+			_, err = mgr.HandleSync(lh, ConfigRequest{
+				Filename: "other.conf",
+				Content:  minimalConfContent,
+				Create:   true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected sync error on unaffected file: %v", err)
+			}
+
+			storage.Reset()
+			_, err = mgr.HandleSync(lh, ConfigRequest{
+				Filename: defaultConfName,
+				Content:  minimalConfContent,
+				Create:   true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected sync error after recovery: %v", err)
+			}
+			st = mgr.Status(defaultConfName)
+			if !st.FileExists || st.LastWriteError != "" {
+				t.Fatalf("unexpected status after recovery: %+v", st)
+			}
+		})
+	}
+}
+
+func TestFaultyStorageDelete(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewFaultyStorage(NewMemoryStorage())
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+
+	storage.Inject(OpRemove, "", syscall.EROFS)
+	err = mgr.Delete(defaultConfName)
+	if !errors.Is(err, syscall.EROFS) {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	st := mgr.Status(defaultConfName)
+	if !st.FileExists || st.LastWriteError == "" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
+
+func TestFaultyStorageStat(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewFaultyStorage(NewMemoryStorage())
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	storage.Inject(OpStat, "", syscall.EACCES)
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	st := mgr.Status(defaultConfName)
+	if st.FileExists || st.LastWriteError == "" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
+
+func TestIsTempFile(t *testing.T) {
+	type testCase struct {
+		name     string
+		expected bool
+	}
+
+	testCases := []testCase{
+		{name: "kubedredger-1234567", expected: true},
+		{name: "/etc/conf.d/kubedredger-42", expected: true},
+		{name: "kubedredger-", expected: false},
+		{name: "kubedredger-golab.conf", expected: false},
+		{name: "workshop.conf", expected: false},
+		{name: "my-kubedredger-42", expected: false},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if got := IsTempFile(tcase.name); got != tcase.expected {
+				t.Errorf("got=%v expected=%v", got, tcase.expected)
+			}
+		})
+	}
+}
+
+// TestOSStorageSeparateRoot mimics a root which is bind mounted from somewhere else:
+// the parent of the root and the storage backing it are unrelated directories.
+func TestOSStorageSeparateRoot(t *testing.T) {
+	lh := testr.New(t)
+	hostDir := t.TempDir()
+	backingDir := t.TempDir()
+	root := filepath.Join(hostDir, "config.d")
+	if err := os.Symlink(backingDir, root); err != nil {
+		t.Fatalf("cannot link the root: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(backingDir, "app"), 0755); err != nil {
+		t.Fatalf("cannot create the subdirectory: %v", err)
+	}
+
+	mgr := NewManager(root)
+	for _, fileName := range []string{defaultConfName, "app/app.conf"} {
+		_, err := mgr.HandleSync(lh, ConfigRequest{
+			Filename: fileName,
+			Content:  minimalConfContent,
+			Create:   true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected sync error for %q: %v", fileName, err)
+		}
+	}
+
+	expectDirEntries(t, hostDir, "config.d")
+	expectDirEntries(t, backingDir, "app", defaultConfName)
+	expectDirEntries(t, filepath.Join(backingDir, "app"), "app.conf")
+}
+
+func TestOSStorageNoTempLeakOnFailure(t *testing.T) {
+	lh := testr.New(t)
+	root := t.TempDir()
+	// rename(2) can't replace a directory with a file
+	if err := os.Mkdir(filepath.Join(root, defaultConfName), 0755); err != nil {
+		t.Fatalf("cannot create the blocking directory: %v", err)
+	}
+
+	mgr := NewManager(root)
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: defaultConfName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err == nil {
+		t.Fatalf("sync succeeded replacing a directory")
+	}
+	expectDirEntries(t, root, defaultConfName)
+}
+
+func TestCleanStaleTempFiles(t *testing.T) {
+	lh := testr.New(t)
+	hostDir := t.TempDir()
+	root := filepath.Join(hostDir, "config.d")
+	for _, dir := range []string{root, filepath.Join(root, "app")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("cannot create %q: %v", dir, err)
+		}
+	}
+	for _, name := range []string{
+		filepath.Join(hostDir, "kubedredger-1111"),
+		filepath.Join(hostDir, "kubedredger.conf"),
+		filepath.Join(root, "kubedredger-2222"),
+		filepath.Join(root, defaultConfName),
+		filepath.Join(root, "app", "kubedredger-3333"),
+		filepath.Join(root, "app", "app.conf"),
+	} {
+		if err := os.WriteFile(name, []byte(minimalConfContent), 0644); err != nil {
+			t.Fatalf("cannot create %q: %v", name, err)
+		}
+	}
+
+	mgr := NewManager(root)
+	if err := mgr.CleanStaleTempFiles(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	expectDirEntries(t, hostDir, "config.d", "kubedredger.conf")
+	expectDirEntries(t, root, "app", defaultConfName)
+	expectDirEntries(t, filepath.Join(root, "app"), "app.conf")
+}
+
+func expectDirEntries(t *testing.T, dir string, expected ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read %q: %v", dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Fatalf("unexpected content of %q: got=%v expected=%v", dir, names, expected)
+	}
+}
+
+func TestSyncInSubdirectory(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	fileName := NamespacedFilename("team-a", defaultConfName)
+	_, err := mgr.HandleSync(lh, ConfigRequest{
+		Filename: fileName,
+		Content:  minimalConfContent,
+		Create:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	data, err := storage.ReadFile(filepath.Join(memoryRoot, NamespacesDir, "team-a", defaultConfName))
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if string(data) != minimalConfContent {
+		t.Fatalf("unexpected content: %q", string(data))
+	}
+	if st := mgr.Status(fileName); !st.FileExists || st.Content != minimalConfContent {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	if err := mgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	if entries, err := storage.ReadDir(memoryRoot); err != nil || len(entries) > 0 {
+		t.Fatalf("root not cleaned: entries=%v err=%v", entries, err)
+	}
+}
+
+func TestCleanAllExcept(t *testing.T) {
+	lh := testr.New(t)
+	storage := NewMemoryStorage()
+	mgr := NewManagerWithOptions(memoryRoot, Options{Storage: storage})
+	if err := mgr.CleanAllExcept(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+
+	kept := []string{"kept.conf", NamespacedFilename("team-a", "app.conf")}
+	stale := []string{"stale.conf", NamespacedFilename("team-a", "old.conf"), NamespacedFilename("team-b", "app.conf")}
+	for _, fileName := range slices.Concat(kept, stale) {
+		fullPath := filepath.Join(memoryRoot, fileName)
+		if err := storage.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("unexpected mkdir error: %v", err)
+		}
+		if err := storage.WriteFileAtomic(fullPath, []byte(minimalConfContent), 0644); err != nil {
+			t.Fatalf("unexpected write error: %v", err)
+		}
+	}
+
+	if err := mgr.CleanAllExcept(lh, kept...); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	for _, fileName := range kept {
+		if _, err := storage.Stat(filepath.Join(memoryRoot, fileName)); err != nil {
+			t.Fatalf("described file %q removed: %v", fileName, err)
+		}
+	}
+	for _, fileName := range stale {
+		if _, err := storage.Stat(filepath.Join(memoryRoot, fileName)); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("stale file %q not removed: %v", fileName, err)
+		}
+	}
+	// the directories left empty are removed too
+	if _, err := storage.Stat(filepath.Join(memoryRoot, NamespacedFilename("team-b", ""))); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("empty directory not removed: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package contenthash computes the hash of the configuration content.
+// The very same value is reported in the Configuration status and in the
+// node labels, so the two can be compared for equality.
+package contenthash
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+)
+
+const (
+	// Algorithm is the name of the hash algorithm used
+	Algorithm = "sha256"
+)
+
+// the hex encoding of a sha256 sum is 64 characters, one more than a label
+// value allows, so we use the lowercase base32 encoding, without padding.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Sum returns the hash of the given content. The value is 52 characters long,
+// made only by lowercase letters and digits, thus it is a valid label value.
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return strings.ToLower(encoding.EncodeToString(sum[:]))
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contenthash
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestSum(t *testing.T) {
+	type testCase struct {
+		name    string
+		content string
+	}
+
+	testCases := []testCase{
+		{name: "empty"},
+		{name: "minimal", content: "[main]\nfoo=bar\n"},
+		{name: "large", content: strings.Repeat("x", 1<<20)},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			sum := Sum([]byte(tcase.content))
+			if errs := validation.IsValidLabelValue(sum); len(errs) > 0 {
+				t.Fatalf("invalid label value %q: %v", sum, errs)
+			}
+			if again := Sum([]byte(tcase.content)); again != sum {
+				t.Fatalf("unstable sum got=%q expected=%q", again, sum)
+			}
+			if other := Sum([]byte(tcase.content + "\n")); other == sum {
+				t.Fatalf("different content, same sum %q", sum)
+			}
+		})
+	}
+}
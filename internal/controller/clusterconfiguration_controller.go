@@ -0,0 +1,68 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/validate"
+)
+
+// ClusterConfigurationReconciler reconciles a ClusterConfiguration object.
+// It shares the settings and the configuration manager with the ConfigurationReconciler.
+type ClusterConfigurationReconciler struct {
+	ConfigurationReconciler
+}
+
+// +kubebuilder:rbac:groups=workshop.golab.io,resources=clusterconfigurations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=workshop.golab.io,resources=clusterconfigurations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=workshop.golab.io,resources=clusterconfigurations/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *ClusterConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	conf := &workshopv1alpha2.ClusterConfiguration{}
+	err := r.Get(ctx, req.NamespacedName, conf)
+	if err != nil {
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	err = validate.ClusterRequestWithOptions(conf.Spec, r.Validation)
+	if err != nil {
+		return r.reconcileInvalid(ctx, conf, err)
+	}
+
+	// cluster-scoped objects own the files directly within the root, and no policy applies to them
+	return r.reconcileObject(ctx, conf, conf.Spec.Filename, nil)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterConfigurationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&workshopv1alpha2.ClusterConfiguration{}).
+		Named("clusterconfiguration").
+		WithOptions(crcontroller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		}).
+		Complete(r)
+}
@@ -22,16 +22,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
 	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/metrics"
+	"golab.io/kubedredger/internal/nodelabel"
+	"golab.io/kubedredger/internal/schedule"
 	"golab.io/kubedredger/internal/validate"
 )
 
@@ -44,11 +54,38 @@ type ConfigurationReconciler struct {
 	client.Client
 	Scheme  *runtime.Scheme
 	ConfMgr *configfile.Manager
+	// Recorder emits the events about the Configuration objects.
+	// If nil, no events are emitted.
+	Recorder record.EventRecorder
+	// NodeName is the name of the node this reconciler runs on, if known.
+	// The state of the file on the node is reported in the status only if known,
+	// and the rollout strategy of the spec can only gate the nodes with a known name.
+	NodeName string
+	// Nodes sets the content hash labels of the files on the node this reconciler runs on.
+	// If nil, no labels are set.
+	Nodes *nodelabel.Manager
+	// StatusPreviewSize is the maximum size in bytes of the content preview
+	// reported in the status. If zero, no preview is reported.
+	StatusPreviewSize int
+	// MaxConcurrentReconciles is the maximum number of concurrent Reconciles
+	// which can be run. Defaults to 1.
+	MaxConcurrentReconciles int
+	// AllowedNamespaces are the only namespaces whose Configurations can write files.
+	// If empty, all namespaces can.
+	AllowedNamespaces []string
+	// Validation tunes the validation of the spec
+	Validation validate.Options
+	// DryRun makes the reconciler only report the changes it would make to the files,
+	// without making them. It can also be enabled per object (see workshopv1alpha2.DryRunAnnotation).
+	DryRun bool
 }
 
 // +kubebuilder:rbac:groups=workshop.golab.io,resources=configurations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=workshop.golab.io,resources=configurations/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=workshop.golab.io,resources=configurations/finalizers,verbs=update
+// +kubebuilder:rbac:groups=workshop.golab.io,resources=configurationpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,69 +93,432 @@ type ConfigurationReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	lh := logf.FromContext(ctx)
-
-	conf := &workshopv1alpha1.Configuration{}
+	conf := &workshopv1alpha2.Configuration{}
 	err := r.Get(ctx, req.NamespacedName, conf)
 	if err != nil {
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	err = validate.Request(conf.Spec)
+	err = validate.RequestWithOptions(conf.Spec, r.Validation)
 	if err != nil {
-		return ctrl.Result{}, err
+		return r.reconcileInvalid(ctx, conf, err)
 	}
 
-	if !conf.DeletionTimestamp.IsZero() {
+	// namespaced objects can only write within the directory of their namespace
+	return r.reconcileObject(ctx, conf, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename), func(ctx context.Context) error {
+		policies := workshopv1alpha2.ConfigurationPolicyList{}
+		if err := r.List(ctx, &policies); err != nil {
+			return fmt.Errorf("failed to list the configuration policies: %w", err)
+		}
+		return validate.Namespace(conf.Namespace, conf.Spec, r.AllowedNamespaces, policies.Items)
+	})
+}
+
+// configurationObject is implemented by all the kinds describing a configuration file
+type configurationObject interface {
+	client.Object
+	GetSpec() *workshopv1alpha2.ConfigurationSpec
+	GetStatus() *workshopv1alpha2.ConfigurationStatus
+}
+
+// reconcileObject reconciles the given file, relative to the root, with the state described by the given object.
+// The object must be already validated. If not nil, authorize is called before writing the file:
+// if it returns a forbidden or a policy violation error (see validate.IsForbidden and validate.IsPolicyViolation),
+// the file is left untouched.
+func (r *ConfigurationReconciler) reconcileObject(ctx context.Context, conf configurationObject, fileName string, authorize func(ctx context.Context) error) (ctrl.Result, error) {
+	lh := logf.FromContext(ctx)
+
+	if !conf.GetDeletionTimestamp().IsZero() {
 		// Deletion
 		if controllerutil.ContainsFinalizer(conf, Finalizer) {
-			if err := r.ConfMgr.Delete(conf.Spec.Filename); err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to delete the configuration %q: %w", conf.Spec.Filename, err)
+			if r.isDryRun(conf) {
+				// the file is left in place, otherwise the object would never go away
+				res, err := r.ConfMgr.DryRunDelete(fileName)
+				r.recordDryRunEvent(conf, fileName, res, err)
+			} else {
+				if err := r.ConfMgr.Delete(fileName); err != nil {
+					return ctrl.Result{}, fmt.Errorf("failed to delete the configuration %q: %w", fileName, err)
+				}
+				if err := r.clearContentHashLabel(ctx, fileName); err != nil {
+					return ctrl.Result{}, err
+				}
+				r.recordEvent(conf, corev1.EventTypeNormal, EventReasonDeleted, fmt.Sprintf("configuration file %q deleted", fileName))
 			}
 			controllerutil.RemoveFinalizer(conf, Finalizer)
-			err = r.Update(ctx, conf)
+			err := r.Update(ctx, conf)
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, nil
 	}
 
+	oldStatus := conf.GetStatus().DeepCopy()
+
+	if authorize != nil {
+		if err := authorize(ctx); err != nil {
+			isForbidden := validate.IsForbidden(err)
+			if !isForbidden && !validate.IsPolicyViolation(err) {
+				return ctrl.Result{}, err
+			}
+			lh.Info("configuration rejected", "fileName", fileName, "reason", err.Error())
+			// the file, if any, is left untouched, so it is still reported as is
+			status := conf.GetStatus()
+			if r.NodeName != "" {
+				// the state of the file on the node is still the one reported in its entry
+				resetNodeConditions(status, conf.GetGeneration())
+			} else {
+				confStatus := r.ConfMgr.Status(fileName)
+				*status = statusFromConfStatus(oldStatus, conf.GetGeneration(), *conf.GetSpec(), confStatus, nil)
+				status.ContentPreview = contentPreview(confStatus.Content, r.StatusPreviewSize)
+			}
+			if isForbidden {
+				r.recordEvent(conf, corev1.EventTypeWarning, EventReasonForbidden, fmt.Sprintf("cannot write configuration file %q: %v", fileName, err))
+				setForbiddenConditions(status, conf.GetGeneration(), err)
+			} else {
+				r.recordEvent(conf, corev1.EventTypeWarning, EventReasonPolicyViolation, fmt.Sprintf("cannot write configuration file %q: %v", fileName, err))
+				setPolicyViolationConditions(status, conf.GetGeneration(), err)
+			}
+			return ctrl.Result{}, r.updateStatus(ctx, conf, oldStatus)
+		}
+	}
+
 	// Add or Update
 	if !controllerutil.ContainsFinalizer(conf, Finalizer) {
 		controllerutil.AddFinalizer(conf, Finalizer)
-		err = r.Update(ctx, conf)
+		err := r.Update(ctx, conf)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
-	oldStatus := conf.Status.DeepCopy()
-	configurationRequest := configurationRequestFromSpec(conf.Spec)
+	configurationRequest := configfile.RequestFromSpec(fileName, *conf.GetSpec())
+	if r.isDryRun(conf) {
+		return r.reconcileDryRun(ctx, conf, configurationRequest, oldStatus)
+	}
+	if r.NodeName != "" && rolloutReverts(*conf.GetSpec(), oldStatus.Rollout, oldStatus.Nodes, r.NodeName) {
+		return r.reconcileRevert(ctx, conf, configurationRequest.Filename, oldStatus)
+	}
+	if r.NodeName != "" && !rolloutAllows(*conf.GetSpec(), oldStatus.Rollout, r.NodeName) {
+		return r.reconcileRolloutPending(ctx, conf, configurationRequest.Filename, oldStatus)
+	}
 
-	err = r.ConfMgr.HandleSync(lh, configurationRequest)
+	if pending, nextWindow, err := r.windowPending(conf, configurationRequest, time.Now()); pending {
+		return r.reconcileWindowPending(ctx, conf, configurationRequest.Filename, nextWindow, err, oldStatus)
+	}
+
+	resync := conf.GetAnnotations()[workshopv1alpha2.ResyncAnnotation]
+	if r.NodeName != "" {
+		node, _ := findNodeStatus(oldStatus.Nodes, r.NodeName)
+		configurationRequest.Force = resync != node.ResyncRequestedAt
+	}
+
+	syncStarted := time.Now()
+	outcome, err := r.ConfMgr.HandleSync(lh, configurationRequest)
+	r.recordSyncEvent(conf, configurationRequest.Filename, outcome, err)
 	if errors.As(err, &configfile.NonRecoverableError{}) {
+		metrics.ObserveSync(metrics.ResultNonRecoverable, syncStarted)
 		lh.Error(err, "Non-recoverable error handling configuration")
 		return ctrl.Result{}, nil
 	}
+	if err != nil {
+		metrics.ObserveSync(metrics.ResultError, syncStarted)
+	} else {
+		metrics.ObserveSync(metrics.ResultSuccess, syncStarted)
+	}
 
 	confStatus := r.ConfMgr.Status(configurationRequest.Filename)
-	lh.Info("file status", "fileName", configurationRequest.Filename, "status", confStatus)
-	conf.Status = statusFromConfStatus(conf.Spec, confStatus, err)
-
-	if !statusesAreEqual(oldStatus, &conf.Status) {
-		updErr := r.Client.Status().Update(ctx, conf)
-		if updErr != nil && !apierrors.IsNotFound(updErr) {
-			lh.Error(updErr, "Failed to update configuration status")
-			return ctrl.Result{}, fmt.Errorf("could not update status for object %s: %w", client.ObjectKeyFromObject(conf), updErr)
+	lh.Info("file status", "fileName", configurationRequest.Filename, "exists", confStatus.FileExists, "contentHash", confStatus.ContentHash, "lastWriteError", confStatus.LastWriteError)
+	if r.NodeName != "" {
+		previous, _ := findNodeStatus(oldStatus.Nodes, r.NodeName)
+		node := nodeStatusAfterSync(previous, conf.GetGeneration(), configfile.SpecRevision(*conf.GetSpec()), confStatus, err)
+		if err == nil {
+			// the resync is honored, until the annotation changes again
+			node.ResyncRequestedAt = resync
+		}
+		r.reportNodeStatus(conf, node, confStatus)
+	} else {
+		status := conf.GetStatus()
+		*status = statusFromConfStatus(oldStatus, conf.GetGeneration(), *conf.GetSpec(), confStatus, err)
+		status.ContentPreview = contentPreview(confStatus.Content, r.StatusPreviewSize)
+	}
+
+	if updErr := r.updateStatus(ctx, conf, oldStatus); updErr != nil {
+		return ctrl.Result{}, updErr
+	}
+	if err != nil {
+		if confStatus.LastWriteErrorReason == configfile.ErrorReasonQuotaExceeded {
+			// retrying can't help until the spec changes, which triggers a new reconcile anyway
+			return ctrl.Result{}, nil
+		}
+		// lock timeouts, full filesystems and write errors are expected to be transient
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.setContentHashLabel(ctx, configurationRequest.Filename, confStatus)
+}
+
+// setContentHashLabel sets the content hash label of the given file, as it is after a successful sync, on the node.
+func (r *ConfigurationReconciler) setContentHashLabel(ctx context.Context, fileName string, confStatus configfile.ConfigurationStatus) error {
+	if r.Nodes == nil || !confStatus.FileExists {
+		return nil
+	}
+	labels, annotations := nodelabel.ContentHashLabels(fileName, confStatus.ContentHash)
+	if err := r.Nodes.Apply(ctx, labels, annotations, nil); err != nil {
+		return fmt.Errorf("failed to set the content hash label of %q: %w", fileName, err)
+	}
+	return nil
+}
+
+// clearContentHashLabel removes the content hash label of the given file, once deleted, from the node.
+func (r *ConfigurationReconciler) clearContentHashLabel(ctx context.Context, fileName string) error {
+	if r.Nodes == nil {
+		return nil
+	}
+	if err := r.Nodes.Apply(ctx, nil, nil, []string{nodelabel.MakeContentHashLabel(fileName)}); err != nil {
+		return fmt.Errorf("failed to clear the content hash label of %q: %w", fileName, err)
+	}
+	return nil
+}
+
+// reconcileInvalid reports in the status the given validation error of the spec of the given object, like a
+// permission broader than allowed. The spec can't be trusted to name the file, so the file is left untouched,
+// also on deletion, and the status keeps reporting it as it was. There is nothing to retry: the object is
+// reconciled again once changed.
+func (r *ConfigurationReconciler) reconcileInvalid(ctx context.Context, conf configurationObject, err error) (ctrl.Result, error) {
+	if !conf.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.RemoveFinalizer(conf, Finalizer) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.Update(ctx, conf)
+	}
+
+	logf.FromContext(ctx).Info("invalid configuration spec", "reason", err.Error())
+	r.recordEvent(conf, corev1.EventTypeWarning, EventReasonInvalidSpec, fmt.Sprintf("invalid configuration spec: %v", err))
+	oldStatus := conf.GetStatus().DeepCopy()
+	status := conf.GetStatus()
+	status.ObservedGeneration = conf.GetGeneration()
+	setInvalidSpecConditions(status, conf.GetGeneration(), err)
+	return ctrl.Result{}, r.updateStatus(ctx, conf, oldStatus)
+}
+
+// reconcileRolloutPending reports the file as it is, because the rollout controller did not allow
+// the node to apply the spec yet. The agent is triggered again by the rollout controller updating the status.
+func (r *ConfigurationReconciler) reconcileRolloutPending(ctx context.Context, conf configurationObject, fileName string, oldStatus *workshopv1alpha2.ConfigurationStatus) (ctrl.Result, error) {
+	logf.FromContext(ctx).Info("waiting for the rollout", "fileName", fileName, "node", r.NodeName)
+
+	node, _ := findNodeStatus(oldStatus.Nodes, r.NodeName)
+	node.Pending = ConditionReasonRolloutPending
+	if oldStatus.Rollout != nil && oldStatus.Rollout.Phase == workshopv1alpha2.RolloutPhaseAborted {
+		node.Pending = ConditionReasonRolloutAborted
+	}
+	confStatus := r.ConfMgr.Status(fileName)
+	r.reportNodeStatus(conf, nodeStatusOfFile(node, confStatus), confStatus)
+
+	return ctrl.Result{}, r.updateStatus(ctx, conf, oldStatus)
+}
+
+// reconcileRevert restores the version of the file the node had before applying the revision
+// whose rollout was aborted. If the revert fails, like when the history was lost on restart,
+// the file is left as it is and the error is reported in the node entry. The history is kept
+// only in memory, so once it is reported missing the revert is not attempted again.
+func (r *ConfigurationReconciler) reconcileRevert(ctx context.Context, conf configurationObject, fileName string, oldStatus *workshopv1alpha2.ConfigurationStatus) (ctrl.Result, error) {
+	lh := logf.FromContext(ctx)
+	node, _ := findNodeStatus(oldStatus.Nodes, r.NodeName)
+	if node.Error == revertError(configfile.ErrNoHistory) {
+		lh.V(1).Info("no history to revert configuration file to", "fileName", fileName)
+		return ctrl.Result{}, nil
+	}
+	ver, err := r.ConfMgr.Revert(lh, fileName)
+	if err != nil {
+		lh.Error(err, "failed to revert configuration file", "fileName", fileName)
+		r.recordEvent(conf, corev1.EventTypeWarning, EventReasonRevertFailed, fmt.Sprintf("failed to revert configuration file %q: %v", fileName, err))
+		node.Error = revertError(err)
+	} else {
+		r.recordEvent(conf, corev1.EventTypeNormal, EventReasonReverted, fmt.Sprintf("configuration file %q reverted, the rollout was aborted", fileName))
+		node.Revision = ver.Revision
+		node.ObservedGeneration = 0
+		node.Error = ""
+	}
+
+	// the node stays out of the rollout until the spec changes
+	node.Pending = ConditionReasonRolloutAborted
+	confStatus := r.ConfMgr.Status(fileName)
+	r.reportNodeStatus(conf, nodeStatusOfFile(node, confStatus), confStatus)
+
+	return ctrl.Result{}, r.updateStatus(ctx, conf, oldStatus)
+}
+
+func revertError(err error) string {
+	return fmt.Sprintf("failed to revert: %v", err)
+}
+
+// windowPending tells if the changes the given request makes to the file must wait for a maintenance window
+// of the spec, and when the next window starts: the zero time if no window starts again. A file already up to
+// date never waits. If the schedule is not valid, the changes wait until it is fixed and the error is returned.
+func (r *ConfigurationReconciler) windowPending(conf configurationObject, request configfile.ConfigRequest, now time.Time) (bool, time.Time, error) {
+	spec := conf.GetSpec()
+	if spec.Schedule == nil {
+		return false, time.Time{}, nil
+	}
+	sched, err := schedule.New(*spec.Schedule)
+	if err != nil {
+		return true, time.Time{}, err
+	}
+	open, nextWindow := sched.Open(now)
+	if open {
+		return false, time.Time{}, nil
+	}
+	// a sync which would fail is let through to report the failure: it can't change the file either
+	if res, err := r.ConfMgr.DryRunSync(request); err != nil || res.Outcome == configfile.SyncUnchanged {
+		return false, time.Time{}, nil
+	}
+	return true, nextWindow, nil
+}
+
+// reconcileWindowPending reports the file as it is, because the changes wait for a maintenance window.
+// The object is reconciled again when the next window starts.
+func (r *ConfigurationReconciler) reconcileWindowPending(ctx context.Context, conf configurationObject, fileName string, nextWindow time.Time, scheduleErr error, oldStatus *workshopv1alpha2.ConfigurationStatus) (ctrl.Result, error) {
+	lh := logf.FromContext(ctx)
+
+	var res ctrl.Result
+	var reason, message string
+	switch {
+	case scheduleErr != nil:
+		lh.Info("invalid maintenance schedule", "fileName", fileName, "reason", scheduleErr.Error())
+		reason, message = ConditionReasonInvalidSchedule, scheduleErr.Error()
+	case nextWindow.IsZero():
+		lh.Info("no maintenance window starts again", "fileName", fileName)
+		reason, message = ConditionReasonOutsideWindow, "waiting for a maintenance window, but none starts again"
+	default:
+		lh.Info("waiting for the maintenance window", "fileName", fileName, "nextWindow", nextWindow)
+		reason, message = ConditionReasonOutsideWindow, fmt.Sprintf("waiting for the maintenance window starting at %s", nextWindow.Format(time.RFC3339))
+		res.RequeueAfter = time.Until(nextWindow)
+	}
+
+	confStatus := r.ConfMgr.Status(fileName)
+	if r.NodeName != "" {
+		node, _ := findNodeStatus(oldStatus.Nodes, r.NodeName)
+		node.Pending = reason
+		r.reportNodeStatus(conf, nodeStatusOfFile(node, confStatus), confStatus)
+	} else {
+		status := conf.GetStatus()
+		*status = statusFromConfStatus(oldStatus, conf.GetGeneration(), *conf.GetSpec(), confStatus, nil)
+		status.ContentPreview = contentPreview(confStatus.Content, r.StatusPreviewSize)
+		setWindowPendingCondition(status, conf.GetGeneration(), reason, message)
+	}
+	return res, r.updateStatus(ctx, conf, oldStatus)
+}
+
+// isDryRun tells if the changes to the file of the given object must only be reported.
+// The annotation can only enable the dry run mode, never disable it.
+func (r *ConfigurationReconciler) isDryRun(conf configurationObject) bool {
+	return r.DryRun || conf.GetAnnotations()[workshopv1alpha2.DryRunAnnotation] == "true"
+}
+
+// reconcileDryRun reports in the status and in the events the changes the sync of the given request would make,
+// without touching the file. The rest of the status keeps reporting the file as it is.
+// The event is emitted only when the reported changes change, not on every reconcile.
+func (r *ConfigurationReconciler) reconcileDryRun(ctx context.Context, conf configurationObject, request configfile.ConfigRequest, oldStatus *workshopv1alpha2.ConfigurationStatus) (ctrl.Result, error) {
+	res, err := r.ConfMgr.DryRunSync(request)
+	if err != nil {
+		logf.FromContext(ctx).Info("dry run sync would fail", "fileName", request.Filename, "reason", err.Error())
+	}
+
+	confStatus := r.ConfMgr.Status(request.Filename)
+	dryRun := dryRunStatus(conf.GetGeneration(), res, err)
+	changed := !ptr.Equal(oldStatus.DryRun, dryRun)
+	if r.NodeName != "" {
+		node, _ := findNodeStatus(oldStatus.Nodes, r.NodeName)
+		changed = !ptr.Equal(node.DryRun, dryRun)
+		node = nodeStatusOfFile(node, confStatus)
+		node.Pending = ""
+		node.DryRun = dryRun
+		r.reportNodeStatus(conf, node, confStatus)
+	} else {
+		status := conf.GetStatus()
+		*status = statusFromConfStatus(oldStatus, conf.GetGeneration(), *conf.GetSpec(), confStatus, nil)
+		status.ContentPreview = contentPreview(confStatus.Content, r.StatusPreviewSize)
+		status.DryRun = dryRun
+	}
+	if changed {
+		r.recordDryRunEvent(conf, request.Filename, res, err)
+	}
+
+	return ctrl.Result{}, r.updateStatus(ctx, conf, oldStatus)
+}
+
+// reportNodeStatus records the given state of the node this reconciler runs on, with the given state of the file,
+// in the status of the given object, and computes the rest of the status from the states of all the nodes.
+func (r *ConfigurationReconciler) reportNodeStatus(conf configurationObject, node workshopv1alpha2.NodeStatus, confStatus configfile.ConfigurationStatus) {
+	status := conf.GetStatus()
+	node.Name = r.NodeName
+	setNodeStatus(status, node)
+	aggregateNodeStatus(status, conf.GetGeneration(), configfile.SpecRevision(*conf.GetSpec()), confStatus, r.StatusPreviewSize)
+}
+
+// updateStatus updates the status of the given object, if changed from the given old status.
+func (r *ConfigurationReconciler) updateStatus(ctx context.Context, conf configurationObject, oldStatus *workshopv1alpha2.ConfigurationStatus) error {
+	status := conf.GetStatus()
+	status.TargetNodes = targetNodeCount(status)
+	if statusesAreEqual(oldStatus, conf.GetStatus()) {
+		return nil
+	}
+	updErr := r.Client.Status().Update(ctx, conf)
+	if updErr != nil && !apierrors.IsNotFound(updErr) {
+		logf.FromContext(ctx).Error(updErr, "Failed to update configuration status")
+		return fmt.Errorf("could not update status for object %s: %w", client.ObjectKeyFromObject(conf), updErr)
+	}
+	return nil
+}
+
+// DescribedFiles returns the files, relative to the root, described by all the Configurations and
+// the ClusterConfigurations, valid or not: the agent removes the other ones on startup.
+func DescribedFiles(ctx context.Context, reader client.Reader) ([]string, error) {
+	confs := workshopv1alpha2.ConfigurationList{}
+	if err := reader.List(ctx, &confs); err != nil {
+		return nil, fmt.Errorf("failed to list the configurations: %w", err)
+	}
+	clusterConfs := workshopv1alpha2.ClusterConfigurationList{}
+	if err := reader.List(ctx, &clusterConfs); err != nil {
+		return nil, fmt.Errorf("failed to list the cluster configurations: %w", err)
+	}
+	res := make([]string, 0, len(confs.Items)+len(clusterConfs.Items))
+	for _, conf := range confs.Items {
+		res = append(res, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+	}
+	for _, conf := range clusterConfs.Items {
+		res = append(res, conf.Spec.Filename)
+	}
+	return res, nil
+}
+
+// configurationsForPolicy returns the requests to reconcile all the Configurations affected by the given policy.
+func (r *ConfigurationReconciler) configurationsForPolicy(ctx context.Context, obj client.Object) []reconcile.Request {
+	policy, ok := obj.(*workshopv1alpha2.ConfigurationPolicy)
+	if !ok {
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, namespace := range policy.Spec.Namespaces {
+		confs := workshopv1alpha2.ConfigurationList{}
+		if err := r.List(ctx, &confs, client.InNamespace(namespace)); err != nil {
+			logf.FromContext(ctx).Error(err, "Failed to list the configurations affected by the policy", "policy", policy.Name, "namespace", namespace)
+			continue
+		}
+		for _, conf := range confs.Items {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&conf)})
 		}
 	}
-	return ctrl.Result{}, nil
+	return reqs
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConfigurationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&workshopv1alpha1.Configuration{}).
+		For(&workshopv1alpha2.Configuration{}).
+		Watches(&workshopv1alpha2.ConfigurationPolicy{}, handler.EnqueueRequestsFromMapFunc(r.configurationsForPolicy)).
 		Named("configuration").
+		WithOptions(crcontroller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		}).
 		Complete(r)
 }
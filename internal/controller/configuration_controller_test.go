@@ -19,60 +19,76 @@ package controller
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/go-logr/logr/testr"
+
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
 	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/contenthash"
+	"golab.io/kubedredger/internal/nodelabel"
 )
 
 const (
 	confSnippet = "answer=42\n"
 )
 
-func NewFakeConfigurationReconciler() (*ConfigurationReconciler, string, func() error, error) {
-	dir, err := os.MkdirTemp("", "kubedredger-ctrl-test")
-	if err != nil {
-		return nil, "", func() error { return nil }, err
-	}
-	GinkgoLogr.Info("created temporary directory", "path", dir)
-	cleanup := func() error {
-		return os.RemoveAll(dir)
+const (
+	fakeConfigRoot = "/etc/kubedredger-ctrl-test"
+)
+
+func NewFakeConfigurationReconciler() (*ConfigurationReconciler, *configfile.FaultyStorage, error) {
+	storage := configfile.NewFaultyStorage(configfile.NewMemoryStorage())
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: storage,
+	})
+	if err := confMgr.CleanAll(GinkgoLogr); err != nil {
+		return nil, nil, err
 	}
 	rec := ConfigurationReconciler{
 		Client:  k8sClient,
 		Scheme:  scheme.Scheme,
-		ConfMgr: configfile.NewManager(dir),
+		ConfMgr: confMgr,
+		// large enough to never block the reconciler in tests
+		Recorder: record.NewFakeRecorder(100),
 	}
-	return &rec, dir, cleanup, nil
+	return &rec, storage, nil
 }
 
 var _ = Describe("Configuration Controller", func() {
 	var testNamespace *v1.Namespace
 
 	Context("When reconciling a resource", func() {
-		var cleanup func() error
 		var reconciler *ConfigurationReconciler
-		var configRoot string
+		var storage *configfile.FaultyStorage
 
 		BeforeEach(func() {
 			var err error
-			reconciler, configRoot, cleanup, err = NewFakeConfigurationReconciler()
+			reconciler, storage, err = NewFakeConfigurationReconciler()
 			Expect(err).ToNot(HaveOccurred())
 
 			ctx := context.Background()
-			Expect(err).ToNot(HaveOccurred())
 
 			// see: https://book.kubebuilder.io/reference/envtest.html?highlight=envtest#namespace-usage-limitation
 			ns := &v1.Namespace{
@@ -84,21 +100,18 @@ var _ = Describe("Configuration Controller", func() {
 			testNamespace = ns
 		})
 
-		AfterEach(func() {
-			// intentionally not try to delete namespaces.
-			// see: https://book.kubebuilder.io/reference/envtest.html?highlight=envtest#namespace-usage-limitation
-			Expect(cleanup()).To(Succeed())
-		})
+		// intentionally not try to delete namespaces.
+		// see: https://book.kubebuilder.io/reference/envtest.html?highlight=envtest#namespace-usage-limitation
 
 		When("handling the configuration", func() {
 			It("creates the configuration from scratch", func(ctx context.Context) {
 
-				conf := &workshopv1alpha1.Configuration{
+				conf := &workshopv1alpha2.Configuration{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: testNamespace.Name,
 						Name:      "test-create",
 					},
-					Spec: workshopv1alpha1.ConfigurationSpec{
+					Spec: workshopv1alpha2.ConfigurationSpec{
 						Filename: "foo.conf",
 						Content:  "foo=bar\nbaz=42\n",
 						Create:   true,
@@ -113,26 +126,26 @@ var _ = Describe("Configuration Controller", func() {
 				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				configPath := filepath.Join(configRoot, conf.Spec.Filename)
-				_, err = os.Stat(configPath)
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				_, err = storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 
-				data, err := os.ReadFile(configPath)
+				data, err := storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
-				updatedConf := &workshopv1alpha1.Configuration{}
+				updatedConf := &workshopv1alpha2.Configuration{}
 				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
 				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
 			})
 
 			It("updates the configuration once created", func(ctx context.Context) {
-				conf := &workshopv1alpha1.Configuration{
+				conf := &workshopv1alpha2.Configuration{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: testNamespace.Name,
 						Name:      "test-create",
 					},
-					Spec: workshopv1alpha1.ConfigurationSpec{
+					Spec: workshopv1alpha2.ConfigurationSpec{
 						Filename:   "bar2.conf",
 						Content:    "foo=bar\n",
 						Create:     true,
@@ -148,16 +161,16 @@ var _ = Describe("Configuration Controller", func() {
 				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				configPath := filepath.Join(configRoot, conf.Spec.Filename)
-				finfo, err := os.Stat(configPath)
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				finfo, err := storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo.Mode())).To(Equal(uint32(0600)), "error checking permissions, got %o expected %o", finfo.Mode(), 0600)
 
-				data, err := os.ReadFile(configPath)
+				data, err := storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
-				updatedConf := &workshopv1alpha1.Configuration{}
+				updatedConf := &workshopv1alpha2.Configuration{}
 				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
 				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
 
@@ -169,11 +182,11 @@ var _ = Describe("Configuration Controller", func() {
 				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				finfo2, err := os.Stat(configPath)
+				finfo2, err := storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo2.Mode())).To(Equal(uint32(0644)), "error checking permissions, got %o expected %o", finfo2.Mode(), 0644)
 
-				data, err = os.ReadFile(configPath)
+				data, err = storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
@@ -183,12 +196,12 @@ var _ = Describe("Configuration Controller", func() {
 
 			It("does not create the same configuration file twice", func(ctx context.Context) {
 				origContent := "foo=bar\n"
-				conf := &workshopv1alpha1.Configuration{
+				conf := &workshopv1alpha2.Configuration{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: testNamespace.Name,
 						Name:      "test-create",
 					},
-					Spec: workshopv1alpha1.ConfigurationSpec{
+					Spec: workshopv1alpha2.ConfigurationSpec{
 						Filename:   "foo5.conf",
 						Content:    origContent,
 						Create:     true,
@@ -204,16 +217,16 @@ var _ = Describe("Configuration Controller", func() {
 				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				configPath := filepath.Join(configRoot, conf.Spec.Filename)
-				finfo, err := os.Stat(configPath)
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				finfo, err := storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo.Mode())).To(Equal(uint32(0600)), "error checking permissions, got %o expected %o", finfo.Mode(), 0600)
 
-				data, err := os.ReadFile(configPath)
+				data, err := storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
-				updatedConf := &workshopv1alpha1.Configuration{}
+				updatedConf := &workshopv1alpha2.Configuration{}
 				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
 				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
 
@@ -223,11 +236,11 @@ var _ = Describe("Configuration Controller", func() {
 				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				finfo2, err := os.Stat(configPath)
+				finfo2, err := storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo2.Mode())).To(Equal(uint32(0600)), "error checking permissions, got %o expected %o", finfo2.Mode(), 0600)
 
-				data, err = os.ReadFile(configPath)
+				data, err = storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(confSnippet), "configuration content doesn't match")
 
@@ -235,12 +248,12 @@ var _ = Describe("Configuration Controller", func() {
 				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
 			})
 			It("updates the configuration once created multiple times", func(ctx context.Context) {
-				conf := &workshopv1alpha1.Configuration{
+				conf := &workshopv1alpha2.Configuration{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: testNamespace.Name,
 						Name:      "test-create",
 					},
-					Spec: workshopv1alpha1.ConfigurationSpec{
+					Spec: workshopv1alpha2.ConfigurationSpec{
 						Filename:   "quux.conf",
 						Content:    "foo=bar\n",
 						Create:     true,
@@ -257,16 +270,16 @@ var _ = Describe("Configuration Controller", func() {
 				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				configPath := filepath.Join(configRoot, conf.Spec.Filename)
-				finfo, err := os.Stat(configPath)
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				finfo, err := storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo.Mode())).To(Equal(uint32(0600)), "error checking permissions, got %o expected %o", finfo.Mode(), 0600)
 
-				data, err := os.ReadFile(configPath)
+				data, err := storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
-				updatedConf := &workshopv1alpha1.Configuration{}
+				updatedConf := &workshopv1alpha2.Configuration{}
 				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
 				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
 
@@ -278,11 +291,11 @@ var _ = Describe("Configuration Controller", func() {
 				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				finfo2, err := os.Stat(configPath)
+				finfo2, err := storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo2.Mode())).To(Equal(uint32(0644)), "error checking permissions, got %o expected %o", finfo2.Mode(), 0644)
 
-				data, err = os.ReadFile(configPath)
+				data, err = storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
@@ -297,22 +310,365 @@ var _ = Describe("Configuration Controller", func() {
 				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
 				Expect(err).NotTo(HaveOccurred())
 
-				finfo2, err = os.Stat(configPath)
+				finfo2, err = storage.Stat(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error Stat()ing configuration file")
 				Expect(uint32(finfo2.Mode())).To(Equal(uint32(0644)), "error checking permissions, got %o expected %o", finfo2.Mode(), 0644)
 
-				data, err = os.ReadFile(configPath)
+				data, err = storage.ReadFile(configPath)
+				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
+				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
+
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
+			})
+
+			It("reports the storage failures as degraded", func(ctx context.Context) {
+				conf := &workshopv1alpha2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: testNamespace.Name,
+						Name:      "test-create",
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: "nospace.conf",
+						Content:  "foo=bar\n",
+						Create:   true,
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				storage.Inject(configfile.OpWriteFileAtomic, configPath, syscall.ENOSPC)
+
+				key := client.ObjectKeyFromObject(conf)
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).To(HaveOccurred(), "storage failure not requeued")
+
+				_, err = storage.Stat(configPath)
+				Expect(err).To(HaveOccurred(), "configuration file created despite the failure")
+
+				updatedConf := &workshopv1alpha2.Configuration{}
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(isConditionEqual(updatedConf.Status.Conditions, ConditionDegraded, metav1.ConditionTrue)).To(BeTrue(),
+					"unexpected status conditions: %#v", updatedConf.Status.Conditions)
+				Expect(isConditionEqual(updatedConf.Status.Conditions, ConditionAvailable, metav1.ConditionFalse)).To(BeTrue(),
+					"unexpected status conditions: %#v", updatedConf.Status.Conditions)
+
+				storage.Reset()
+				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err := storage.ReadFile(configPath)
+				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
+				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
+
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
+			})
+
+			It("does not write the files forbidden by the policies", func(ctx context.Context) {
+				policy := &workshopv1alpha2.ConfigurationPolicy{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "test-policy-",
+					},
+					Spec: workshopv1alpha2.ConfigurationPolicySpec{
+						Namespaces: []string{testNamespace.Name},
+						Paths:      []string{"*.ini"},
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, policy)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), policy)).To(Succeed())
+				})
+
+				conf := &workshopv1alpha2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: testNamespace.Name,
+						Name:      "test-forbidden",
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: "foo.conf",
+						Content:  "foo=bar\n",
+						Create:   true,
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				key := client.ObjectKeyFromObject(conf)
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				_, err = storage.Stat(configPath)
+				Expect(err).To(HaveOccurred(), "forbidden configuration file created")
+
+				updatedConf := &workshopv1alpha2.Configuration{}
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				cond := meta.FindStatusCondition(updatedConf.Status.Conditions, ConditionDegraded)
+				Expect(cond).NotTo(BeNil())
+				Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+				Expect(cond.Reason).To(Equal(ConditionReasonForbidden))
+			})
+
+			It("only reports the changes in dry run mode", func(ctx context.Context) {
+				conf := &workshopv1alpha2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: testNamespace.Name,
+						Name:      "test-dry-run",
+						Annotations: map[string]string{
+							workshopv1alpha2.DryRunAnnotation: "true",
+						},
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: "dry.conf",
+						Content:  "foo=bar\n",
+						Create:   true,
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				key := client.ObjectKeyFromObject(conf)
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				_, err = storage.Stat(configPath)
+				Expect(err).To(HaveOccurred(), "configuration file created in dry run mode")
+
+				updatedConf := &workshopv1alpha2.Configuration{}
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(updatedConf.Status.FileExists).To(BeFalse())
+				Expect(updatedConf.Status.DryRun).NotTo(BeNil())
+				Expect(updatedConf.Status.DryRun.Action).To(Equal(workshopv1alpha2.DryRunActionCreate))
+				Expect(updatedConf.Status.DryRun.Diff).To(ContainSubstring("\n+foo=bar\n"))
+
+				By("leaving the dry run mode")
+				delete(updatedConf.Annotations, workshopv1alpha2.DryRunAnnotation)
+				Expect(reconciler.Client.Update(ctx, updatedConf)).To(Succeed())
+				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err := storage.ReadFile(configPath)
+				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
+				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(updatedConf.Status.DryRun).To(BeNil())
+
+				By("updating the configuration in dry run mode for all the objects")
+				reconciler.DryRun = true
+				updatedConf.Spec.Content = "foo=baz\n"
+				updatedConf.Spec.Permission = ptr.To[uint32](0600)
+				Expect(reconciler.Client.Update(ctx, updatedConf)).To(Succeed())
+				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err = storage.ReadFile(configPath)
+				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
+				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration file updated in dry run mode")
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(updatedConf.Status.DryRun).NotTo(BeNil())
+				Expect(updatedConf.Status.DryRun.Action).To(Equal(workshopv1alpha2.DryRunActionUpdate))
+				Expect(updatedConf.Status.DryRun.Diff).To(ContainSubstring("\n-foo=bar\n+foo=baz\n"))
+				Expect(updatedConf.Status.DryRun.ModeChange).To(Equal("0644 -> 0600"))
+			})
+
+			It("waits for the rollout to allow the node", func(ctx context.Context) {
+				reconciler.NodeName = "node-a"
+				rolloutReconciler := &RolloutReconciler{
+					Client: reconciler.Client,
+					Scheme: reconciler.Scheme,
+				}
+
+				conf := &workshopv1alpha2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: testNamespace.Name,
+						Name:      "test-rollout",
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: "rollout.conf",
+						Content:  "foo=bar\n",
+						Create:   true,
+						Rollout:  &workshopv1alpha2.RolloutStrategy{},
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				key := client.ObjectKeyFromObject(conf)
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				_, err = storage.Stat(configPath)
+				Expect(err).To(HaveOccurred(), "configuration file created before the rollout allowed the node")
+
+				updatedConf := &workshopv1alpha2.Configuration{}
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				cond := findCondition(updatedConf.Status.Conditions, ConditionProgressing)
+				Expect(cond).NotTo(BeNil())
+				Expect(cond.Reason).To(Equal(ConditionReasonRolloutPending))
+
+				By("allowing the node")
+				// envtest runs no nodes, so the rollout is planned as if node-a were the only one
+				revision := configfile.SpecRevision(updatedConf.Spec)
+				rollout, _ := planRollout(time.Now(), revision, *updatedConf.Spec.Rollout, nil, []string{"node-a"}, updatedConf.Status.Nodes, nil)
+				updatedConf.Status.Rollout = rollout
+				Expect(reconciler.Client.Status().Update(ctx, updatedConf)).To(Succeed())
+				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err := storage.ReadFile(configPath)
+				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
+				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
+				Expect(updatedConf.Status.Nodes).To(HaveLen(1))
+				Expect(updatedConf.Status.Nodes[0].Revision).To(Equal(revision))
+
+				By("clearing the rollout status once the strategy is removed")
+				updatedConf.Spec.Rollout = nil
+				Expect(reconciler.Client.Update(ctx, updatedConf)).To(Succeed())
+				_, err = rolloutReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(updatedConf.Status.Rollout).To(BeNil())
+			})
+
+			It("waits for the maintenance window", func(ctx context.Context) {
+				conf := &workshopv1alpha2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: testNamespace.Name,
+						Name:      "test-schedule",
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: "schedule.conf",
+						Content:  "foo=bar\n",
+						Create:   true,
+						Schedule: &workshopv1alpha2.MaintenanceSchedule{
+							// a window closed but for the first minute of the year
+							Windows: []workshopv1alpha2.MaintenanceWindow{
+								{Start: "0 0 1 1 *", Duration: metav1.Duration{Duration: time.Minute}},
+							},
+						},
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				key := client.ObjectKeyFromObject(conf)
+				res, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.RequeueAfter).To(BeNumerically(">", 0))
+
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				_, err = storage.Stat(configPath)
+				Expect(err).To(HaveOccurred(), "configuration file created outside the maintenance window")
+
+				updatedConf := &workshopv1alpha2.Configuration{}
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				cond := findCondition(updatedConf.Status.Conditions, ConditionPending)
+				Expect(cond).NotTo(BeNil())
+				Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+				Expect(cond.Reason).To(Equal(ConditionReasonOutsideWindow))
+
+				By("opening the window")
+				updatedConf.Spec.Schedule.Windows[0] = workshopv1alpha2.MaintenanceWindow{Start: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}}
+				Expect(reconciler.Client.Update(ctx, updatedConf)).To(Succeed())
+				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err := storage.ReadFile(configPath)
+				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
+				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
+				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
+				Expect(findCondition(updatedConf.Status.Conditions, ConditionPending)).To(BeNil())
+				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
+			})
+
+			It("creates the cluster configuration in the root", func(ctx context.Context) {
+				clusterReconciler := &ClusterConfigurationReconciler{
+					ConfigurationReconciler: *reconciler,
+				}
+
+				conf := &workshopv1alpha2.ClusterConfiguration{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "test-cluster-create-",
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: "cluster.conf",
+						Content:  "foo=bar\n",
+						Create:   true,
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				key := client.ObjectKeyFromObject(conf)
+				_, err := clusterReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+
+				configPath := filepath.Join(fakeConfigRoot, conf.Spec.Filename)
+				data, err := storage.ReadFile(configPath)
 				Expect(err).NotTo(HaveOccurred(), "error reading configuration file content")
 				Expect(string(data)).To(Equal(conf.Spec.Content), "configuration content doesn't match")
 
+				updatedConf := &workshopv1alpha2.ClusterConfiguration{}
 				Expect(reconciler.Client.Get(ctx, key, updatedConf)).To(Succeed())
 				Expect(verifyAvailableStatus(&updatedConf.Status)).To(Succeed())
 			})
+
+			It("rejects cluster configurations in the namespaced area", func(ctx context.Context) {
+				clusterReconciler := &ClusterConfigurationReconciler{
+					ConfigurationReconciler: *reconciler,
+				}
+
+				conf := &workshopv1alpha2.ClusterConfiguration{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "test-cluster-reserved-",
+					},
+					Spec: workshopv1alpha2.ConfigurationSpec{
+						Filename: configfile.NamespacedFilename(testNamespace.Name, "foo.conf"),
+						Content:  "foo=bar\n",
+						Create:   true,
+					},
+				}
+				Expect(reconciler.Client.Create(ctx, conf)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(reconciler.Client.Delete(context.Background(), conf)).To(Succeed())
+				})
+
+				key := client.ObjectKeyFromObject(conf)
+				_, err := clusterReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).ToNot(HaveOccurred(), "invalid specs are reported in the status, not retried")
+
+				Expect(reconciler.Client.Get(ctx, key, conf)).To(Succeed())
+				degraded := meta.FindStatusCondition(conf.Status.Conditions, ConditionDegraded)
+				Expect(degraded).ToNot(BeNil())
+				Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+				Expect(degraded.Reason).To(Equal(ConditionReasonInvalidSpec))
+
+				_, err = storage.Stat(filepath.Join(fakeConfigRoot, conf.Spec.Filename))
+				Expect(err).To(HaveOccurred(), "configuration file created in the namespaced area")
+			})
 		})
 	})
 })
 
-func verifyAvailableStatus(confStatus *workshopv1alpha1.ConfigurationStatus) error {
+func verifyAvailableStatus(confStatus *workshopv1alpha2.ConfigurationStatus) error {
 	if !confStatus.FileExists {
 		return fmt.Errorf("cannot be available without file created")
 	}
@@ -332,3 +688,614 @@ func isConditionEqual(conds []metav1.Condition, condType string, condStatus meta
 	}
 	return false
 }
+
+func TestWindowPending(t *testing.T) {
+	lh := testr.New(t)
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	if err := confMgr.CleanAll(lh); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	rec := ConfigurationReconciler{ConfMgr: confMgr}
+	upToDate := configfile.ConfigRequest{Filename: "uptodate.conf", Content: "foo=1\n", Create: true}
+	if _, err := confMgr.HandleSync(lh, upToDate); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+	// a wednesday
+	now := time.Date(2025, 7, 2, 10, 0, 0, 0, time.UTC)
+	weekends := &workshopv1alpha2.MaintenanceSchedule{
+		Windows: []workshopv1alpha2.MaintenanceWindow{
+			{Start: "0 2 * * sat,sun", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+		},
+	}
+
+	type testCase struct {
+		name            string
+		schedule        *workshopv1alpha2.MaintenanceSchedule
+		request         configfile.ConfigRequest
+		expectedPending bool
+		expectedNext    time.Time
+		expectedErr     bool
+	}
+
+	testCases := []testCase{
+		{
+			name:    "no schedule",
+			request: configfile.ConfigRequest{Filename: "new.conf", Content: "foo=1\n", Create: true},
+		},
+		{
+			name: "within the window",
+			schedule: &workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{
+					{Start: "0 9 * * wed", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+				},
+			},
+			request: configfile.ConfigRequest{Filename: "new.conf", Content: "foo=1\n", Create: true},
+		},
+		{
+			name:            "outside the window",
+			schedule:        weekends,
+			request:         configfile.ConfigRequest{Filename: "new.conf", Content: "foo=1\n", Create: true},
+			expectedPending: true,
+			expectedNext:    time.Date(2025, 7, 5, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "outside the window, up to date",
+			schedule: weekends,
+			request:  upToDate,
+		},
+		{
+			name: "invalid schedule",
+			schedule: &workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{
+					{Start: "sometimes", Duration: metav1.Duration{Duration: time.Hour}},
+				},
+			},
+			request:         configfile.ConfigRequest{Filename: "new.conf", Content: "foo=1\n", Create: true},
+			expectedPending: true,
+			expectedErr:     true,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			conf := &workshopv1alpha2.Configuration{
+				Spec: workshopv1alpha2.ConfigurationSpec{Schedule: tcase.schedule},
+			}
+			pending, next, err := rec.windowPending(conf, tcase.request, now)
+			if pending != tcase.expectedPending {
+				t.Fatalf("unexpected pending got=%v expected=%v", pending, tcase.expectedPending)
+			}
+			if !next.Equal(tcase.expectedNext) {
+				t.Fatalf("unexpected next window got=%v expected=%v", next, tcase.expectedNext)
+			}
+			if (err != nil) != tcase.expectedErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigurationContentHashLabel(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+	}
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename: "app.conf",
+			Content:  confSnippet,
+			Create:   true,
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&v1.Node{}, &workshopv1alpha2.Configuration{}).
+		WithObjects(node, conf).
+		Build()
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	if err := confMgr.CleanAll(testr.New(t)); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	rec := ConfigurationReconciler{
+		Client:   cli,
+		Scheme:   testScheme,
+		ConfMgr:  confMgr,
+		NodeName: node.Name,
+		Nodes:    nodelabel.NewManager(node.Name, cli),
+	}
+	// the file name is not a valid label name, so it is encoded and annotated
+	fileName := configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename)
+	key := nodelabel.MakeContentHashLabel(fileName)
+	if key != "contenthashv1.workshop.golab.io/namespaces_ns_app.conf-qz3jb22pju" {
+		t.Fatalf("unexpected label key %q, the README example must be updated", key)
+	}
+
+	reconcileNode := func() *v1.Node {
+		t.Helper()
+		if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(conf)}); err != nil {
+			t.Fatalf("unexpected reconcile error: %v", err)
+		}
+		updatedNode := &v1.Node{}
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(node), updatedNode); err != nil {
+			t.Fatalf("cannot get updated node: %v", err)
+		}
+		return updatedNode
+	}
+
+	updatedNode := reconcileNode()
+	if got, expected := updatedNode.Labels[key], contenthash.Sum([]byte(confSnippet)); got != expected {
+		t.Fatalf("unexpected content hash label %q got=%q expected=%q", key, got, expected)
+	}
+	if got, ok := nodelabel.ContentHashFileName(updatedNode, key); !ok || got != fileName {
+		t.Fatalf("unexpected file name got=%q ok=%v expected=%q", got, ok, fileName)
+	}
+
+	if err := cli.Delete(ctx, conf); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	updatedNode = reconcileNode()
+	if _, ok := updatedNode.Labels[key]; ok {
+		t.Fatalf("unexpected content hash label left after delete: %v", updatedNode.Labels)
+	}
+	if _, ok := updatedNode.Annotations[key]; ok {
+		t.Fatalf("unexpected file name annotation left after delete: %v", updatedNode.Annotations)
+	}
+}
+
+func TestConfigurationInvalidSpec(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	// created before the maximum permission was enforced
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app", Generation: 2, Finalizers: []string{Finalizer}},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename:   "app.conf",
+			Content:    confSnippet,
+			Create:     true,
+			Permission: ptr.To(uint32(0666)),
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+		WithObjects(conf).
+		Build()
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	if err := confMgr.CleanAll(testr.New(t)); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	rec := ConfigurationReconciler{
+		Client:   cli,
+		Scheme:   testScheme,
+		ConfMgr:  confMgr,
+		Recorder: recorder,
+	}
+	key := client.ObjectKeyFromObject(conf)
+
+	res, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil || !res.IsZero() {
+		t.Fatalf("unexpected reconcile result=%+v err=%v", res, err)
+	}
+	updated := &workshopv1alpha2.Configuration{}
+	if err := cli.Get(ctx, key, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	degraded := meta.FindStatusCondition(updated.Status.Conditions, ConditionDegraded)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != ConditionReasonPermissionBroad {
+		t.Fatalf("unexpected degraded condition: %+v", degraded)
+	}
+	if updated.Status.ObservedGeneration != updated.Generation {
+		t.Fatalf("unexpected observed generation got=%d expected=%d", updated.Status.ObservedGeneration, updated.Generation)
+	}
+	if st := confMgr.Status(configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename)); st.FileExists {
+		t.Fatalf("unexpected file written with an invalid spec: %+v", st)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, EventReasonInvalidSpec) {
+			t.Fatalf("unexpected event %q", event)
+		}
+	default:
+		t.Fatalf("missing event")
+	}
+
+	// the object can still go away
+	if err := cli.Delete(ctx, updated); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if err := cli.Get(ctx, key, updated); !apierrors.IsNotFound(err) {
+		t.Fatalf("unexpected object left after delete: %v", err)
+	}
+}
+
+func TestConfigurationResync(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename: "app.conf",
+			Content:  confSnippet,
+			Create:   true,
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+		WithObjects(conf).
+		Build()
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	if err := confMgr.CleanAll(testr.New(t)); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	rec := ConfigurationReconciler{
+		Client:   cli,
+		Scheme:   testScheme,
+		ConfMgr:  confMgr,
+		Recorder: recorder,
+		NodeName: "node-a",
+	}
+	key := client.ObjectKeyFromObject(conf)
+
+	type testCase struct {
+		name           string
+		resync         string
+		expectedReason string
+	}
+
+	// the steps build on each other, so the order matters
+	testCases := []testCase{
+		{
+			name:           "create",
+			expectedReason: EventReasonCreated,
+		},
+		{
+			name: "up to date",
+		},
+		{
+			name:           "resync requested",
+			resync:         "2025-10-01T10:00:00Z",
+			expectedReason: EventReasonUpdated,
+		},
+		{
+			name:   "resync already honored",
+			resync: "2025-10-01T10:00:00Z",
+		},
+		{
+			name:           "resync requested again",
+			resync:         "2025-10-01T11:00:00Z",
+			expectedReason: EventReasonUpdated,
+		},
+	}
+
+	for _, tcase := range testCases {
+		updated := &workshopv1alpha2.Configuration{}
+		if err := cli.Get(ctx, key, updated); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tcase.name, err)
+		}
+		if tcase.resync != "" {
+			updated.Annotations = map[string]string{workshopv1alpha2.ResyncAnnotation: tcase.resync}
+			if err := cli.Update(ctx, updated); err != nil {
+				t.Fatalf("%s: unexpected update error: %v", tcase.name, err)
+			}
+		}
+		if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("%s: unexpected reconcile error: %v", tcase.name, err)
+		}
+
+		select {
+		case event := <-recorder.Events:
+			if tcase.expectedReason == "" || !strings.Contains(event, tcase.expectedReason) {
+				t.Fatalf("%s: unexpected event %q", tcase.name, event)
+			}
+		default:
+			if tcase.expectedReason != "" {
+				t.Fatalf("%s: missing event %q", tcase.name, tcase.expectedReason)
+			}
+		}
+
+		if err := cli.Get(ctx, key, updated); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tcase.name, err)
+		}
+		node, ok := findNodeStatus(updated.Status.Nodes, rec.NodeName)
+		if !ok || node.ResyncRequestedAt != tcase.resync {
+			t.Fatalf("%s: unexpected node status: %+v", tcase.name, updated.Status.Nodes)
+		}
+	}
+}
+
+func TestConfigurationDryRunEvents(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "app",
+			Annotations: map[string]string{workshopv1alpha2.DryRunAnnotation: "true"},
+		},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename: "app.conf",
+			Content:  confSnippet,
+			Create:   true,
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+		WithObjects(conf).
+		Build()
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	if err := confMgr.CleanAll(testr.New(t)); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	rec := ConfigurationReconciler{
+		Client:   cli,
+		Scheme:   testScheme,
+		ConfMgr:  confMgr,
+		Recorder: recorder,
+		NodeName: "node-a",
+	}
+	key := client.ObjectKeyFromObject(conf)
+
+	type testCase struct {
+		name          string
+		content       string
+		expectedEvent bool
+	}
+
+	// the steps build on each other, so the order matters
+	testCases := []testCase{
+		{name: "first report", expectedEvent: true},
+		{name: "same changes"},
+		{name: "new content", content: "[main]\nfoo=changed\n", expectedEvent: true},
+		{name: "same new content"},
+	}
+
+	for _, tcase := range testCases {
+		if tcase.content != "" {
+			updated := &workshopv1alpha2.Configuration{}
+			if err := cli.Get(ctx, key, updated); err != nil {
+				t.Fatalf("%s: unexpected error: %v", tcase.name, err)
+			}
+			updated.Spec.Content = tcase.content
+			if err := cli.Update(ctx, updated); err != nil {
+				t.Fatalf("%s: unexpected update error: %v", tcase.name, err)
+			}
+		}
+		if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("%s: unexpected reconcile error: %v", tcase.name, err)
+		}
+
+		select {
+		case event := <-recorder.Events:
+			if !tcase.expectedEvent || !strings.Contains(event, EventReasonDryRun) {
+				t.Fatalf("%s: unexpected event %q", tcase.name, event)
+			}
+		default:
+			if tcase.expectedEvent {
+				t.Fatalf("%s: missing event", tcase.name)
+			}
+		}
+	}
+
+	// the changes are reported by each node
+	updated := &workshopv1alpha2.Configuration{}
+	if err := cli.Get(ctx, key, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node, _ := findNodeStatus(updated.Status.Nodes, rec.NodeName)
+	if updated.Status.DryRun != nil || node.DryRun == nil || node.DryRun.Action != workshopv1alpha2.DryRunActionCreate {
+		t.Fatalf("unexpected dry run status: %+v %+v", updated.Status.DryRun, node.DryRun)
+	}
+}
+
+func TestConfigurationRevertNoHistory(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Filename: "app.conf",
+		Content:  confSnippet,
+		Create:   true,
+		Rollout:  &workshopv1alpha2.RolloutStrategy{},
+	}
+	revision := configfile.SpecRevision(spec)
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app", Finalizers: []string{Finalizer}},
+		Spec:       spec,
+		Status: workshopv1alpha2.ConfigurationStatus{
+			Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: revision}},
+			Rollout: &workshopv1alpha2.RolloutStatus{
+				Revision: revision,
+				Phase:    workshopv1alpha2.RolloutPhaseAborted,
+				Message:  "health gate failed",
+			},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+		WithObjects(conf).
+		Build()
+	// a fresh manager, like after a restart of the agent: the history is gone
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	if err := confMgr.CleanAll(testr.New(t)); err != nil {
+		t.Fatalf("unexpected clean error: %v", err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	rec := ConfigurationReconciler{
+		Client:   cli,
+		Scheme:   testScheme,
+		ConfMgr:  confMgr,
+		Recorder: recorder,
+		NodeName: "node-a",
+	}
+	key := client.ObjectKeyFromObject(conf)
+
+	if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, EventReasonRevertFailed) {
+			t.Fatalf("unexpected event %q", event)
+		}
+	default:
+		t.Fatalf("missing event")
+	}
+	updated := &workshopv1alpha2.Configuration{}
+	if err := cli.Get(ctx, key, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node, _ := findNodeStatus(updated.Status.Nodes, rec.NodeName)
+	if !strings.Contains(node.Error, configfile.ErrNoHistory.Error()) {
+		t.Fatalf("unexpected node status: %+v", node)
+	}
+
+	// the history can't come back, so the revert is not attempted again
+	if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Fatalf("unexpected event %q", event)
+	default:
+	}
+}
+
+func TestConfigurationSyncErrors(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+
+	type testCase struct {
+		name           string
+		options        configfile.Options
+		capacity       uint64
+		injected       error
+		expectedReason string
+		expectedErr    bool
+	}
+
+	testCases := []testCase{
+		{
+			name:           "insufficient space",
+			options:        configfile.Options{MinFreeSpace: 16},
+			capacity:       16,
+			expectedReason: ConditionReasonNoSpace,
+			expectedErr:    true,
+		},
+		{
+			name:           "write error",
+			injected:       syscall.EIO,
+			expectedReason: ConditionReasonWriteError,
+			expectedErr:    true,
+		},
+		{
+			name:           "quota exceeded",
+			options:        configfile.Options{MaxFileSize: 4},
+			expectedReason: ConditionReasonQuotaExceeded,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			conf := &workshopv1alpha2.Configuration{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app", Finalizers: []string{Finalizer}},
+				Spec: workshopv1alpha2.ConfigurationSpec{
+					Filename: "app.conf",
+					Content:  confSnippet,
+					Create:   true,
+				},
+			}
+			cli := fake.NewClientBuilder().WithScheme(testScheme).
+				WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+				WithObjects(conf).
+				Build()
+			memStorage := configfile.NewMemoryStorage()
+			if tcase.capacity > 0 {
+				memStorage.SetCapacity(tcase.capacity)
+			}
+			storage := configfile.NewFaultyStorage(memStorage)
+			opts := tcase.options
+			opts.Storage = storage
+			confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, opts)
+			if err := confMgr.CleanAll(testr.New(t)); err != nil {
+				t.Fatalf("unexpected clean error: %v", err)
+			}
+			if tcase.injected != nil {
+				configPath := filepath.Join(fakeConfigRoot, configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename))
+				storage.Inject(configfile.OpWriteFileAtomic, configPath, tcase.injected)
+			}
+			rec := ConfigurationReconciler{
+				Client:   cli,
+				Scheme:   testScheme,
+				ConfMgr:  confMgr,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			key := client.ObjectKeyFromObject(conf)
+
+			_, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if (err != nil) != tcase.expectedErr {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			updated := &workshopv1alpha2.Configuration{}
+			if err := cli.Get(ctx, key, updated); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cond := meta.FindStatusCondition(updated.Status.Conditions, ConditionDegraded)
+			if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != tcase.expectedReason {
+				t.Fatalf("unexpected degraded condition: %+v", cond)
+			}
+		})
+	}
+}
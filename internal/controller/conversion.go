@@ -1,63 +1,94 @@
 package controller
 
 import (
-	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
 	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
 )
 
 const (
-	ConditionAvailable   = "Available"
-	ConditionProgressing = "Progressing"
-	ConditionDegraded    = "Degraded"
+	ConditionAvailable       = workshopv1alpha2.ConditionAvailable
+	ConditionProgressing     = workshopv1alpha2.ConditionProgressing
+	ConditionDegraded        = workshopv1alpha2.ConditionDegraded
+	ConditionPolicyViolation = workshopv1alpha2.ConditionPolicyViolation
+	ConditionPending         = workshopv1alpha2.ConditionPending
 )
 
 const (
 	ConditionReasonAsExpected      = "AsExpected"
 	ConditionReasonUpToDate        = "UpToDate"
 	ConditionReasonWriteError      = "WriteError"
+	ConditionReasonSyncFailed      = "SyncFailed"
+	ConditionReasonLockTimeout     = "LockTimeout"
+	ConditionReasonQuotaExceeded   = "QuotaExceeded"
+	ConditionReasonNoSpace         = "InsufficientSpace"
 	ConditionReasonUpdatingContent = "UpdatingContent"
 	ConditionReasonUpdatingLabels  = "UpdatingLabels"
+	ConditionReasonForbidden       = "Forbidden"
+	ConditionReasonPolicyViolation = "PolicyViolation"
+	ConditionReasonPermission      = "PermissionNotAllowed"
+	ConditionReasonMaxSize         = "MaxSizeExceeded"
+	ConditionReasonFormat          = "FormatNotAllowed"
+	ConditionReasonRolloutPending  = "RolloutPending"
+	ConditionReasonRolloutAborted  = "RolloutAborted"
+	ConditionReasonOutsideWindow   = "OutsideMaintenanceWindow"
+	ConditionReasonInvalidSchedule = "InvalidSchedule"
+	ConditionReasonInvalidSpec     = "InvalidSpec"
+	ConditionReasonPermissionBroad = "PermissionTooBroad"
+	ConditionReasonSpecialBits     = "SpecialPermissionNotAllowed"
 )
 
-func configurationRequestFromSpec(desired workshopv1alpha1.ConfigurationSpec) configfile.ConfigRequest {
-	res := configfile.ConfigRequest{
-		Filename: desired.Filename,
-		Content:  desired.Content,
-		Create:   desired.Create,
+// dryRunDiffMaxSize is the maximum size in bytes of the diff reported in the status in dry run mode
+const dryRunDiffMaxSize = 8 * 1024
+
+// statusFromConfStatus computes the new status from the current one and the state of the file on disk.
+// The conditions are updated in place, so their LastTransitionTime changes only when their status flips.
+func statusFromConfStatus(current *workshopv1alpha2.ConfigurationStatus, generation int64, desired workshopv1alpha2.ConfigurationSpec, confStatus configfile.ConfigurationStatus, labelErr error) workshopv1alpha2.ConfigurationStatus {
+	res := workshopv1alpha2.ConfigurationStatus{
+		ObservedGeneration: generation,
+		FileExists:         confStatus.FileExists,
+		LastUpdated:        metav1.NewTime(confStatus.FileUpdated),
+		ContentHash:        confStatus.ContentHash,
+		Size:               confStatus.Size,
+		Owner:              confStatus.Owner,
 	}
-	if desired.Permission != nil {
-		res.Permission = ptr.To(*desired.Permission)
+	if confStatus.FileExists {
+		res.Mode = modeToOctal(confStatus.Mode)
 	}
-	return res
-}
-
-func statusFromConfStatus(desired workshopv1alpha1.ConfigurationSpec, confStatus configfile.ConfigurationStatus, labelErr error) workshopv1alpha1.ConfigurationStatus {
-	updateTime := metav1.NewTime(confStatus.FileUpdated)
-
-	res := workshopv1alpha1.ConfigurationStatus{
-		FileExists:  confStatus.FileExists,
-		LastUpdated: updateTime,
-		Content:     confStatus.Content,
+	if current != nil {
+		res.Conditions = slices.Clone(current.Conditions)
+		// owned by the agents of the other nodes and by the rollout controller
+		res.Nodes = slices.Clone(current.Nodes)
+		res.Rollout = current.Rollout.DeepCopy()
 	}
 
 	degraded := metav1.Condition{
 		Type:               ConditionDegraded,
 		Status:             metav1.ConditionFalse,
-		LastTransitionTime: updateTime,
+		ObservedGeneration: generation,
 		Reason:             ConditionReasonAsExpected,
 	}
 	if confStatus.LastWriteError != "" {
 		degraded.Status = metav1.ConditionTrue
-		degraded.Reason = ConditionReasonWriteError
+		degraded.Reason = degradedReason(confStatus)
 		degraded.Message = confStatus.LastWriteError
 	}
 
 	progressing := metav1.Condition{
 		Type:               ConditionProgressing,
 		Status:             metav1.ConditionFalse,
-		LastTransitionTime: updateTime,
+		ObservedGeneration: generation,
 		Reason:             ConditionReasonAsExpected,
 	}
 	if desired.Content != confStatus.Content && confStatus.LastWriteError != "" {
@@ -72,20 +103,414 @@ func statusFromConfStatus(desired workshopv1alpha1.ConfigurationSpec, confStatus
 	available := metav1.Condition{
 		Type:               ConditionAvailable,
 		Status:             metav1.ConditionFalse,
-		LastTransitionTime: updateTime,
+		ObservedGeneration: generation,
 		Reason:             ConditionReasonAsExpected,
 	}
-	if confStatus.LastWriteError == "" && res.Content == desired.Content && labelErr == nil {
+	if confStatus.LastWriteError == "" && confStatus.Content == desired.Content && labelErr == nil {
 		available.Status = metav1.ConditionTrue
 		available.Reason = ConditionReasonUpToDate
 		available.Message = "file up to date"
 	}
-	res.Conditions = []metav1.Condition{degraded, progressing, available}
+
+	// the zero LastTransitionTime is replaced with the current time on actual transitions
+	meta.RemoveStatusCondition(&res.Conditions, ConditionPolicyViolation)
+	meta.RemoveStatusCondition(&res.Conditions, ConditionPending)
+	meta.SetStatusCondition(&res.Conditions, degraded)
+	meta.SetStatusCondition(&res.Conditions, progressing)
+	meta.SetStatusCondition(&res.Conditions, available)
+	return res
+}
+
+// setForbiddenConditions marks the status as degraded, because the namespace is not allowed to write the file.
+func setForbiddenConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64, err error) {
+	setRejectedConditions(status, generation, ConditionReasonForbidden, err)
+}
+
+// setPolicyViolationConditions marks the status as degraded, because the spec violates the configuration policies.
+func setPolicyViolationConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64, err error) {
+	setRejectedConditions(status, generation, ConditionReasonPolicyViolation, err)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionPolicyViolation,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             policyViolationReason(err),
+		Message:            err.Error(),
+	})
+}
+
+// setInvalidSpecConditions marks the status as degraded, because the spec is not valid, like
+// when it requests a permission broader than the agent allows. The file is left untouched.
+func setInvalidSpecConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64, err error) {
+	setRejectedConditions(status, generation, invalidSpecReason(err), err)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonAsExpected,
+	})
+}
+
+// resetNodeConditions clears the conditions of an object synced by the agents running on the nodes
+// which describe the sync of the file, before the object is rejected: the file is left untouched.
+func resetNodeConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64) {
+	status.ObservedGeneration = generation
+	meta.RemoveStatusCondition(&status.Conditions, ConditionPolicyViolation)
+	meta.RemoveStatusCondition(&status.Conditions, ConditionPending)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonAsExpected,
+	})
+}
+
+func setRejectedConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64, reason string, err error) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionDegraded,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            err.Error(),
+	})
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionAvailable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            err.Error(),
+	})
+}
+
+// setWindowPendingCondition marks the status as pending, because the changes to the file
+// wait for a maintenance window.
+func setWindowPendingCondition(status *workshopv1alpha2.ConfigurationStatus, generation int64, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionPending,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setRolloutAbortedConditions marks the status as degraded, because the rollout of the spec was aborted:
+// the node keeps, or reverts to, the previous version of the file until the spec changes.
+func setRolloutAbortedConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64, reason string) {
+	message := "rollout aborted"
+	if reason != "" {
+		message = fmt.Sprintf("rollout aborted: %s", reason)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionDegraded,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonRolloutAborted,
+		Message:            message,
+	})
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonRolloutAborted,
+		Message:            message,
+	})
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionAvailable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonRolloutAborted,
+		Message:            message,
+	})
+}
+
+// findNodeStatus returns the state reported by the given node, if any.
+func findNodeStatus(nodes []workshopv1alpha2.NodeStatus, name string) (workshopv1alpha2.NodeStatus, bool) {
+	idx := slices.IndexFunc(nodes, func(node workshopv1alpha2.NodeStatus) bool {
+		return node.Name == name
+	})
+	if idx < 0 {
+		return workshopv1alpha2.NodeStatus{}, false
+	}
+	return nodes[idx], true
+}
+
+// setNodeStatus adds or replaces the state reported by the node, keeping the entries sorted by name.
+func setNodeStatus(status *workshopv1alpha2.ConfigurationStatus, node workshopv1alpha2.NodeStatus) {
+	idx, found := slices.BinarySearchFunc(status.Nodes, node.Name, func(cur workshopv1alpha2.NodeStatus, name string) int {
+		return strings.Compare(cur.Name, name)
+	})
+	if found {
+		status.Nodes[idx] = node
+		return
+	}
+	status.Nodes = slices.Insert(status.Nodes, idx, node)
+}
+
+// nodeStatusAfterSync computes the state of the node after syncing the given revision of the spec.
+// If the sync failed, the node keeps reporting the revision it last synced.
+func nodeStatusAfterSync(previous workshopv1alpha2.NodeStatus, generation int64, revision string, confStatus configfile.ConfigurationStatus, err error) workshopv1alpha2.NodeStatus {
+	res := nodeStatusOfFile(previous, confStatus)
+	res.Pending = ""
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.ObservedGeneration = generation
+	res.Revision = revision
+	res.Error = ""
+	return res
+}
+
+// nodeStatusOfFile returns the given state of the node, updated with the state of the file on it.
+func nodeStatusOfFile(node workshopv1alpha2.NodeStatus, confStatus configfile.ConfigurationStatus) workshopv1alpha2.NodeStatus {
+	node.ContentHash = confStatus.ContentHash
+	node.Mode = ""
+	if confStatus.FileExists {
+		node.Mode = modeToOctal(confStatus.Mode)
+	}
+	node.DryRun = nil
+	return node
+}
+
+// aggregateNodeStatus computes the status of an object synced by the agents running on the nodes from the
+// entries they report, so that it is the same whichever agent computes it, and does not flip as they take turns
+// updating it. The file is reported, as it is in the given state, only while it has the same content on all
+// the nodes. The per-node values which may still differ, like the owner, are reported only in the entries.
+func aggregateNodeStatus(status *workshopv1alpha2.ConfigurationStatus, generation int64, revision string, confStatus configfile.ConfigurationStatus, previewSize int) {
+	*status = workshopv1alpha2.ConfigurationStatus{
+		ObservedGeneration: generation,
+		TargetNodes:        status.TargetNodes,
+		Nodes:              status.Nodes,
+		Rollout:            status.Rollout,
+		Conditions:         status.Conditions,
+	}
+	if hash := agreedValue(status.Nodes, func(node workshopv1alpha2.NodeStatus) string { return node.ContentHash }); confStatus.FileExists && hash == confStatus.ContentHash {
+		status.FileExists = true
+		status.ContentHash = confStatus.ContentHash
+		status.Size = confStatus.Size
+		status.ContentPreview = contentPreview(confStatus.Content, previewSize)
+		status.Mode = agreedValue(status.Nodes, func(node workshopv1alpha2.NodeStatus) string { return node.Mode })
+	}
+	setNodeConditions(status, generation, revision)
+}
+
+// agreedValue returns the value all the given nodes report, or empty if they don't agree.
+func agreedValue(nodes []workshopv1alpha2.NodeStatus, value func(workshopv1alpha2.NodeStatus) string) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	res := value(nodes[0])
+	for _, node := range nodes[1:] {
+		if value(node) != res {
+			return ""
+		}
+	}
 	return res
 }
 
-func statusesAreEqual(a, b *workshopv1alpha1.ConfigurationStatus) bool {
-	if a.FileExists != b.FileExists || a.Content != b.Content {
+// setNodeConditions sets the conditions of an object synced by the agents running on the nodes, counting
+// the nodes in each state, never naming them: the nodes are listed in the status with their own state.
+func setNodeConditions(status *workshopv1alpha2.ConfigurationStatus, generation int64, revision string) {
+	meta.RemoveStatusCondition(&status.Conditions, ConditionPolicyViolation)
+	if rollout := status.Rollout; rollout != nil && rollout.Revision == revision && rollout.Phase == workshopv1alpha2.RolloutPhaseAborted {
+		meta.RemoveStatusCondition(&status.Conditions, ConditionPending)
+		setRolloutAbortedConditions(status, generation, rollout.Message)
+		return
+	}
+
+	var updated, failed, rolloutPending, windowPending int
+	windowReason := ConditionReasonOutsideWindow
+	for _, node := range status.Nodes {
+		switch {
+		case node.Error != "":
+			failed++
+		case node.Pending == ConditionReasonRolloutPending || node.Pending == ConditionReasonRolloutAborted:
+			// aborted for a previous revision: the rollout of the current one is not planned yet
+			rolloutPending++
+		case node.Pending != "":
+			windowPending++
+			if node.Pending == ConditionReasonInvalidSchedule {
+				windowReason = ConditionReasonInvalidSchedule
+			}
+		case node.DryRun != nil:
+			if node.DryRun.Action == workshopv1alpha2.DryRunActionNone && node.DryRun.Error == "" {
+				updated++
+			}
+		case node.Revision == revision:
+			updated++
+		}
+	}
+	total := max(len(status.Nodes), int(targetNodeCount(status)))
+
+	degraded := metav1.Condition{
+		Type:               ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonAsExpected,
+	}
+	if failed > 0 {
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = ConditionReasonSyncFailed
+		degraded.Message = fmt.Sprintf("%d of %d nodes failed to sync the file", failed, total)
+	}
+
+	progressing := metav1.Condition{
+		Type:               ConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonAsExpected,
+	}
+	if rolloutPending > 0 {
+		progressing.Status = metav1.ConditionTrue
+		progressing.Reason = ConditionReasonRolloutPending
+		progressing.Message = fmt.Sprintf("%d of %d nodes waiting for the rollout", rolloutPending, total)
+	}
+
+	available := metav1.Condition{
+		Type:               ConditionAvailable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ConditionReasonAsExpected,
+		Message:            fmt.Sprintf("%d of %d nodes up to date", updated, total),
+	}
+	switch {
+	case total > 0 && updated == total:
+		available.Status = metav1.ConditionTrue
+		available.Reason = ConditionReasonUpToDate
+		available.Message = "file up to date on all the nodes"
+	case failed > 0:
+		available.Reason = ConditionReasonSyncFailed
+	case rolloutPending > 0:
+		available.Reason = ConditionReasonRolloutPending
+	case windowPending > 0:
+		available.Reason = windowReason
+	}
+
+	if windowPending > 0 {
+		setWindowPendingCondition(status, generation, windowReason, fmt.Sprintf("%d of %d nodes waiting for a maintenance window", windowPending, total))
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, ConditionPending)
+	}
+	meta.SetStatusCondition(&status.Conditions, degraded)
+	meta.SetStatusCondition(&status.Conditions, progressing)
+	meta.SetStatusCondition(&status.Conditions, available)
+}
+
+func policyViolationReason(err error) string {
+	switch {
+	case errors.Is(err, validate.ErrPolicyPermission):
+		return ConditionReasonPermission
+	case errors.Is(err, validate.ErrPolicyMaxSize):
+		return ConditionReasonMaxSize
+	case errors.Is(err, validate.ErrPolicyFormat):
+		return ConditionReasonFormat
+	default:
+		return ConditionReasonPolicyViolation
+	}
+}
+
+func invalidSpecReason(err error) string {
+	switch {
+	case errors.Is(err, validate.ErrPermissionTooBroad):
+		return ConditionReasonPermissionBroad
+	case errors.Is(err, validate.ErrSpecialPermission):
+		return ConditionReasonSpecialBits
+	default:
+		return ConditionReasonInvalidSpec
+	}
+}
+
+// dryRunStatus reports the changes the sync would make, as computed by configfile.Manager.DryRunSync.
+func dryRunStatus(generation int64, res configfile.DryRunResult, err error) *workshopv1alpha2.DryRunStatus {
+	status := &workshopv1alpha2.DryRunStatus{
+		ObservedGeneration: generation,
+		Action:             workshopv1alpha2.DryRunActionNone,
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Action = dryRunAction(res.Outcome)
+	status.Diff = diffPreview(res.Diff, dryRunDiffMaxSize)
+	if res.ModeChanged() {
+		status.ModeChange = fmt.Sprintf("%s -> %s", modeToOctal(res.Mode), modeToOctal(res.DesiredMode))
+	}
+	return status
+}
+
+func dryRunAction(outcome configfile.SyncOutcome) workshopv1alpha2.DryRunAction {
+	switch outcome {
+	case configfile.SyncCreated:
+		return workshopv1alpha2.DryRunActionCreate
+	case configfile.SyncUpdated, configfile.SyncDriftCorrected:
+		return workshopv1alpha2.DryRunActionUpdate
+	case configfile.SyncDeleted:
+		return workshopv1alpha2.DryRunActionDelete
+	default:
+		return workshopv1alpha2.DryRunActionNone
+	}
+}
+
+// diffPreview returns at most maxSize bytes of the beginning of the diff, cut at the end of a line,
+// followed by a note if the diff was truncated.
+func diffPreview(diff string, maxSize int) string {
+	if len(diff) <= maxSize {
+		return diff
+	}
+	preview := contentPreview(diff, maxSize)
+	if idx := strings.LastIndexByte(preview, '\n'); idx >= 0 {
+		preview = preview[:idx+1]
+	}
+	return preview + diffTruncatedNote
+}
+
+const diffTruncatedNote = "[diff truncated]\n"
+
+// modeToOctal renders the permission bits of the given mode in the usual octal notation, like chmod(1) does.
+func modeToOctal(mode fs.FileMode) string {
+	return fmt.Sprintf("%04o", configfile.UnixFromFileMode(mode))
+}
+
+// contentPreview returns at most maxSize bytes of the beginning of the content,
+// never splitting a multibyte character. Returns empty if maxSize is not positive.
+func contentPreview(content string, maxSize int) string {
+	if maxSize <= 0 {
+		return ""
+	}
+	if len(content) <= maxSize {
+		return content
+	}
+	preview := content[:maxSize]
+	for len(preview) > 0 && !utf8.ValidString(preview) {
+		preview = preview[:len(preview)-1]
+	}
+	return preview
+}
+
+func degradedReason(confStatus configfile.ConfigurationStatus) string {
+	switch confStatus.LastWriteErrorReason {
+	case configfile.ErrorReasonLockTimeout:
+		return ConditionReasonLockTimeout
+	case configfile.ErrorReasonQuotaExceeded:
+		return ConditionReasonQuotaExceeded
+	case configfile.ErrorReasonInsufficientSpace:
+		return ConditionReasonNoSpace
+	default:
+		return ConditionReasonWriteError
+	}
+}
+
+func statusesAreEqual(a, b *workshopv1alpha2.ConfigurationStatus) bool {
+	if a.ObservedGeneration != b.ObservedGeneration || a.FileExists != b.FileExists || a.TargetNodes != b.TargetNodes {
+		return false
+	}
+	if a.ContentHash != b.ContentHash || a.Size != b.Size || a.Mode != b.Mode || a.Owner != b.Owner || a.ContentPreview != b.ContentPreview {
+		return false
+	}
+	if !ptr.Equal(a.DryRun, b.DryRun) {
+		return false
+	}
+	if !apiequality.Semantic.DeepEqual(a.Nodes, b.Nodes) || !apiequality.Semantic.DeepEqual(a.Rollout, b.Rollout) {
 		return false
 	}
 
@@ -97,6 +522,7 @@ func statusesAreEqual(a, b *workshopv1alpha1.ConfigurationStatus) bool {
 		condB := b.Conditions[i]
 		if condA.Type != condB.Type ||
 			condA.Status != condB.Status ||
+			condA.ObservedGeneration != condB.ObservedGeneration ||
 			condA.Reason != condB.Reason ||
 			condA.Message != condB.Message {
 			return false
@@ -17,11 +17,18 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
-	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
 	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,7 +37,9 @@ func TestConversionDegraded(t *testing.T) {
 	fakeErrText := "fake error for testing"
 	var labelErr error // no error
 	st := statusFromConfStatus(
-		workshopv1alpha1.ConfigurationSpec{},
+		nil,
+		1,
+		workshopv1alpha2.ConfigurationSpec{},
 		configfile.ConfigurationStatus{
 			LastWriteError: fakeErrText,
 			FileUpdated:    fakeTs,
@@ -52,11 +61,54 @@ func TestConversionDegraded(t *testing.T) {
 	}
 }
 
+func TestConversionDegradedReasons(t *testing.T) {
+	type testCase struct {
+		errorReason    string
+		expectedReason string
+	}
+
+	testCases := []testCase{
+		{errorReason: "", expectedReason: ConditionReasonWriteError},
+		{errorReason: configfile.ErrorReasonLockTimeout, expectedReason: ConditionReasonLockTimeout},
+		{errorReason: configfile.ErrorReasonQuotaExceeded, expectedReason: ConditionReasonQuotaExceeded},
+		{errorReason: configfile.ErrorReasonInsufficientSpace, expectedReason: ConditionReasonNoSpace},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.expectedReason, func(t *testing.T) {
+			var labelErr error // no error
+			st := statusFromConfStatus(
+				nil,
+				1,
+				workshopv1alpha2.ConfigurationSpec{},
+				configfile.ConfigurationStatus{
+					LastWriteError:       "fake error for testing",
+					LastWriteErrorReason: tcase.errorReason,
+					FileUpdated:          time.Now(),
+				},
+				labelErr)
+
+			cond := findCondition(st.Conditions, ConditionDegraded)
+			if cond == nil {
+				t.Fatalf("missing degraded condition")
+			}
+			if cond.Status != metav1.ConditionTrue {
+				t.Fatalf("condition not set")
+			}
+			if cond.Reason != tcase.expectedReason {
+				t.Fatalf("wrong reason: %q", cond.Reason)
+			}
+		})
+	}
+}
+
 func TestConversionProgressing(t *testing.T) {
 	fakeTs := time.Now()
 	var labelErr error // no error
 	st := statusFromConfStatus(
-		workshopv1alpha1.ConfigurationSpec{
+		nil,
+		1,
+		workshopv1alpha2.ConfigurationSpec{
 			Content: "foo=1\n",
 			Create:  true,
 		},
@@ -83,6 +135,517 @@ func TestConversionProgressing(t *testing.T) {
 	}
 }
 
+func TestConversionObservedGeneration(t *testing.T) {
+	var labelErr error // no error
+	st := statusFromConfStatus(
+		nil,
+		7,
+		workshopv1alpha2.ConfigurationSpec{
+			Content: "foo=1\n",
+		},
+		configfile.ConfigurationStatus{
+			Content:     "foo=1\n",
+			FileExists:  true,
+			FileUpdated: time.Now(),
+		},
+		labelErr)
+
+	if st.ObservedGeneration != 7 {
+		t.Fatalf("unexpected observed generation: %d", st.ObservedGeneration)
+	}
+	for _, cond := range st.Conditions {
+		if cond.ObservedGeneration != 7 {
+			t.Fatalf("condition %q: unexpected observed generation: %d", cond.Type, cond.ObservedGeneration)
+		}
+	}
+
+	// a new generation with the very same outcome must still be reported
+	next := statusFromConfStatus(
+		nil,
+		8,
+		workshopv1alpha2.ConfigurationSpec{
+			Content: "foo=1\n",
+		},
+		configfile.ConfigurationStatus{
+			Content:     "foo=1\n",
+			FileExists:  true,
+			FileUpdated: time.Now(),
+		},
+		labelErr)
+	if statusesAreEqual(&st, &next) {
+		t.Fatalf("statuses of different generations reported equal")
+	}
+}
+
+func TestConversionTransitionTime(t *testing.T) {
+	oldTs := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Content: "foo=1\n",
+	}
+	upToDate := configfile.ConfigurationStatus{
+		Content:     "foo=1\n",
+		FileExists:  true,
+		FileUpdated: oldTs.Time,
+	}
+	writeFailed := configfile.ConfigurationStatus{
+		LastWriteError: "fake error for testing",
+		Content:        "foo=0\n",
+		FileExists:     true,
+		FileUpdated:    oldTs.Time,
+	}
+	quotaExceeded := configfile.ConfigurationStatus{
+		LastWriteError:       "fake quota error for testing",
+		LastWriteErrorReason: configfile.ErrorReasonQuotaExceeded,
+		Content:              "foo=0\n",
+		FileExists:           true,
+		FileUpdated:          oldTs.Time,
+	}
+
+	type testCase struct {
+		name string
+		// previous is the file state the current status is computed from, if any
+		previous   *configfile.ConfigurationStatus
+		confStatus configfile.ConfigurationStatus
+		// transitioned lists the conditions expected to have a new LastTransitionTime
+		transitioned []string
+	}
+
+	testCases := []testCase{
+		{
+			name:         "first status",
+			confStatus:   upToDate,
+			transitioned: []string{ConditionDegraded, ConditionProgressing, ConditionAvailable},
+		},
+		{
+			name:       "same outcome",
+			previous:   &upToDate,
+			confStatus: upToDate,
+		},
+		{
+			name:         "write failed",
+			previous:     &upToDate,
+			confStatus:   writeFailed,
+			transitioned: []string{ConditionDegraded, ConditionProgressing, ConditionAvailable},
+		},
+		{
+			name:       "degraded reason changed",
+			previous:   &writeFailed,
+			confStatus: quotaExceeded,
+		},
+		{
+			name:         "recovered",
+			previous:     &writeFailed,
+			confStatus:   upToDate,
+			transitioned: []string{ConditionDegraded, ConditionProgressing, ConditionAvailable},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			var labelErr error // no error
+			var current *workshopv1alpha2.ConfigurationStatus
+			if tcase.previous != nil {
+				prev := statusFromConfStatus(nil, 1, spec, *tcase.previous, labelErr)
+				for idx := range prev.Conditions {
+					prev.Conditions[idx].LastTransitionTime = oldTs
+				}
+				current = &prev
+			}
+
+			before := time.Now().Truncate(time.Second)
+			st := statusFromConfStatus(current, 1, spec, tcase.confStatus, labelErr)
+			if len(st.Conditions) != 3 {
+				t.Fatalf("unexpected conditions: %+v", st.Conditions)
+			}
+			for _, cond := range st.Conditions {
+				if slices.Contains(tcase.transitioned, cond.Type) {
+					if cond.LastTransitionTime.Time.Before(before) {
+						t.Errorf("condition %q: transition time not updated: %v", cond.Type, cond.LastTransitionTime)
+					}
+					continue
+				}
+				if !cond.LastTransitionTime.Equal(&oldTs) {
+					t.Errorf("condition %q: transition time changed: got=%v expected=%v", cond.Type, cond.LastTransitionTime, oldTs)
+				}
+			}
+		})
+	}
+}
+
+func TestConversionDoesNotMutateCurrent(t *testing.T) {
+	var labelErr error // no error
+	current := statusFromConfStatus(nil, 1, workshopv1alpha2.ConfigurationSpec{}, configfile.ConfigurationStatus{}, labelErr)
+	saved := current.DeepCopy()
+	_ = statusFromConfStatus(&current, 2, workshopv1alpha2.ConfigurationSpec{}, configfile.ConfigurationStatus{
+		LastWriteError: "fake error for testing",
+	}, labelErr)
+	if diff := cmp.Diff(saved, &current); diff != "" {
+		t.Fatalf("current status mutated: %v", diff)
+	}
+}
+
+func TestConversionFileDetails(t *testing.T) {
+	var labelErr error // no error
+	st := statusFromConfStatus(
+		nil,
+		1,
+		workshopv1alpha2.ConfigurationSpec{
+			Content: "foo=1\n",
+		},
+		configfile.ConfigurationStatus{
+			Content:     "foo=1\n",
+			ContentHash: "fakehash",
+			Size:        6,
+			Mode:        0640 | fs.ModeSetgid,
+			Owner:       "0:0",
+			FileExists:  true,
+			FileUpdated: time.Now(),
+		},
+		labelErr)
+
+	if st.ContentHash != "fakehash" || st.Size != 6 || st.Owner != "0:0" {
+		t.Fatalf("unexpected file details: %+v", st)
+	}
+	if st.Mode != "2640" {
+		t.Fatalf("unexpected mode: %q", st.Mode)
+	}
+}
+
+func TestConversionForbidden(t *testing.T) {
+	var labelErr error // no error
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Content: "foo=1\n",
+	}
+	// the file was written before the policy changed
+	st := statusFromConfStatus(
+		nil,
+		2,
+		spec,
+		configfile.ConfigurationStatus{
+			Content:     "foo=1\n",
+			FileExists:  true,
+			FileUpdated: time.Now(),
+		},
+		labelErr)
+	setForbiddenConditions(&st, 2, validate.ErrForbiddenPath)
+
+	for _, condType := range []string{ConditionDegraded, ConditionAvailable} {
+		cond := findCondition(st.Conditions, condType)
+		if cond == nil {
+			t.Fatalf("missing condition %q", condType)
+		}
+		if cond.Reason != ConditionReasonForbidden || cond.Message != validate.ErrForbiddenPath.Error() || cond.ObservedGeneration != 2 {
+			t.Fatalf("unexpected condition: %+v", cond)
+		}
+	}
+	if !isConditionEqual(st.Conditions, ConditionDegraded, metav1.ConditionTrue) ||
+		!isConditionEqual(st.Conditions, ConditionAvailable, metav1.ConditionFalse) {
+		t.Fatalf("unexpected status conditions: %#v", st.Conditions)
+	}
+	if !st.FileExists {
+		t.Fatalf("existing file not reported")
+	}
+}
+
+func TestConversionPolicyViolation(t *testing.T) {
+	var labelErr error // no error
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Content: "foo=1\n",
+	}
+	confStatus := configfile.ConfigurationStatus{
+		Content:     "foo=1\n",
+		FileExists:  true,
+		FileUpdated: time.Now(),
+	}
+	violationErr := fmt.Errorf("%w: fake violation for testing", validate.ErrPolicyFormat)
+
+	st := statusFromConfStatus(nil, 1, spec, confStatus, labelErr)
+	setPolicyViolationConditions(&st, 1, violationErr)
+
+	cond := findCondition(st.Conditions, ConditionPolicyViolation)
+	if cond == nil {
+		t.Fatalf("missing policy violation condition")
+	}
+	if cond.Status != metav1.ConditionTrue || cond.Reason != ConditionReasonFormat || cond.Message != violationErr.Error() {
+		t.Fatalf("unexpected condition: %+v", cond)
+	}
+	cond = findCondition(st.Conditions, ConditionDegraded)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != ConditionReasonPolicyViolation {
+		t.Fatalf("unexpected degraded condition: %+v", cond)
+	}
+
+	// the spec is fixed
+	st = statusFromConfStatus(&st, 2, spec, confStatus, labelErr)
+	if cond := findCondition(st.Conditions, ConditionPolicyViolation); cond != nil {
+		t.Fatalf("stale policy violation condition: %+v", cond)
+	}
+	if !isConditionEqual(st.Conditions, ConditionAvailable, metav1.ConditionTrue) {
+		t.Fatalf("unexpected status conditions: %#v", st.Conditions)
+	}
+}
+
+func TestContentPreview(t *testing.T) {
+	type testCase struct {
+		name     string
+		content  string
+		maxSize  int
+		expected string
+	}
+
+	testCases := []testCase{
+		{name: "disabled", content: "foo=1\n", maxSize: 0, expected: ""},
+		{name: "short content", content: "foo=1\n", maxSize: 16, expected: "foo=1\n"},
+		{name: "exact size", content: "foo=1\n", maxSize: 6, expected: "foo=1\n"},
+		{name: "truncated", content: "foo=1\nbar=2\n", maxSize: 6, expected: "foo=1\n"},
+		{name: "multibyte boundary", content: "foo=è\n", maxSize: 5, expected: "foo="},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got := contentPreview(tcase.content, tcase.maxSize)
+			if got != tcase.expected {
+				t.Fatalf("unexpected preview got=%q expected=%q", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestConversionDryRun(t *testing.T) {
+	type testCase struct {
+		name     string
+		res      configfile.DryRunResult
+		err      error
+		expected workshopv1alpha2.DryRunStatus
+	}
+
+	testCases := []testCase{
+		{
+			name: "create",
+			res:  configfile.DryRunResult{Outcome: configfile.SyncCreated, Diff: "+foo=1\n", DesiredMode: 0644},
+			expected: workshopv1alpha2.DryRunStatus{
+				ObservedGeneration: 3,
+				Action:             workshopv1alpha2.DryRunActionCreate,
+				Diff:               "+foo=1\n",
+			},
+		},
+		{
+			name: "update with mode change",
+			res:  configfile.DryRunResult{Outcome: configfile.SyncUpdated, Mode: 0644, DesiredMode: 0600},
+			expected: workshopv1alpha2.DryRunStatus{
+				ObservedGeneration: 3,
+				Action:             workshopv1alpha2.DryRunActionUpdate,
+				ModeChange:         "0644 -> 0600",
+			},
+		},
+		{
+			name: "drift",
+			res:  configfile.DryRunResult{Outcome: configfile.SyncDriftCorrected, Diff: "-foo=2\n+foo=1\n", Mode: 0644, DesiredMode: 0644},
+			expected: workshopv1alpha2.DryRunStatus{
+				ObservedGeneration: 3,
+				Action:             workshopv1alpha2.DryRunActionUpdate,
+				Diff:               "-foo=2\n+foo=1\n",
+			},
+		},
+		{
+			name: "unchanged",
+			res:  configfile.DryRunResult{Outcome: configfile.SyncUnchanged, Mode: 0644, DesiredMode: 0644},
+			expected: workshopv1alpha2.DryRunStatus{
+				ObservedGeneration: 3,
+				Action:             workshopv1alpha2.DryRunActionNone,
+			},
+		},
+		{
+			name: "error",
+			err:  configfile.ErrQuotaExceeded,
+			expected: workshopv1alpha2.DryRunStatus{
+				ObservedGeneration: 3,
+				Action:             workshopv1alpha2.DryRunActionNone,
+				Error:              configfile.ErrQuotaExceeded.Error(),
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got := dryRunStatus(3, tcase.res, tcase.err)
+			if diff := cmp.Diff(*got, tcase.expected); diff != "" {
+				t.Fatalf("unexpected dry run status: %s", diff)
+			}
+		})
+	}
+}
+
+func TestConversionDryRunCleared(t *testing.T) {
+	current := workshopv1alpha2.ConfigurationStatus{
+		DryRun: &workshopv1alpha2.DryRunStatus{Action: workshopv1alpha2.DryRunActionUpdate},
+	}
+	st := statusFromConfStatus(&current, 1, workshopv1alpha2.ConfigurationSpec{}, configfile.ConfigurationStatus{}, nil)
+	if st.DryRun != nil {
+		t.Fatalf("stale dry run status: %+v", st.DryRun)
+	}
+	if statusesAreEqual(&current, &st) {
+		t.Fatalf("unexpected equal statuses")
+	}
+}
+
+func TestAggregateNodeStatus(t *testing.T) {
+	type testCase struct {
+		name              string
+		nodes             []workshopv1alpha2.NodeStatus
+		rollout           *workshopv1alpha2.RolloutStatus
+		expectedHash      string
+		expectedAvailable metav1.ConditionStatus
+		expectedReason    string
+		expectedDegraded  metav1.ConditionStatus
+		expectedPending   bool
+	}
+
+	testCases := []testCase{
+		{
+			name: "all nodes updated",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev2", ContentHash: "hash2", Mode: "0644"},
+				{Name: "node-b", Revision: "rev2", ContentHash: "hash2", Mode: "0644"},
+			},
+			expectedHash:      "hash2",
+			expectedAvailable: metav1.ConditionTrue,
+			expectedReason:    ConditionReasonUpToDate,
+			expectedDegraded:  metav1.ConditionFalse,
+		},
+		{
+			name: "node failing",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev2", ContentHash: "hash2"},
+				{Name: "node-b", Revision: "rev1", ContentHash: "hash1", Error: "no space left"},
+			},
+			expectedAvailable: metav1.ConditionFalse,
+			expectedReason:    ConditionReasonSyncFailed,
+			expectedDegraded:  metav1.ConditionTrue,
+		},
+		{
+			name: "node waiting for the rollout",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev2", ContentHash: "hash2"},
+				{Name: "node-b", Revision: "rev1", ContentHash: "hash1", Pending: ConditionReasonRolloutPending},
+			},
+			rollout:           &workshopv1alpha2.RolloutStatus{Revision: "rev2", Phase: workshopv1alpha2.RolloutPhaseProgressing, TargetNodes: 2},
+			expectedAvailable: metav1.ConditionFalse,
+			expectedReason:    ConditionReasonRolloutPending,
+			expectedDegraded:  metav1.ConditionFalse,
+		},
+		{
+			name: "node waiting for a maintenance window",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev2", ContentHash: "hash2"},
+				{Name: "node-b", Revision: "rev1", ContentHash: "hash1", Pending: ConditionReasonOutsideWindow},
+			},
+			expectedAvailable: metav1.ConditionFalse,
+			expectedReason:    ConditionReasonOutsideWindow,
+			expectedDegraded:  metav1.ConditionFalse,
+			expectedPending:   true,
+		},
+		{
+			name: "rollout aborted",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev1", ContentHash: "hash1", Pending: ConditionReasonRolloutAborted},
+				{Name: "node-b", Revision: "rev1", ContentHash: "hash1", Pending: ConditionReasonRolloutAborted},
+			},
+			rollout:           &workshopv1alpha2.RolloutStatus{Revision: "rev2", Phase: workshopv1alpha2.RolloutPhaseAborted, TargetNodes: 2},
+			expectedHash:      "hash1",
+			expectedAvailable: metav1.ConditionFalse,
+			expectedReason:    ConditionReasonRolloutAborted,
+			expectedDegraded:  metav1.ConditionTrue,
+		},
+		{
+			name: "node not reporting yet",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev2", ContentHash: "hash2"},
+			},
+			rollout:           &workshopv1alpha2.RolloutStatus{Revision: "rev2", Phase: workshopv1alpha2.RolloutPhaseProgressing, TargetNodes: 2},
+			expectedHash:      "hash2",
+			expectedAvailable: metav1.ConditionFalse,
+			expectedReason:    ConditionReasonAsExpected,
+			expectedDegraded:  metav1.ConditionFalse,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			var statuses []workshopv1alpha2.ConfigurationStatus
+			// the status computed by the agent of each node must be the same
+			for _, node := range tcase.nodes {
+				status := workshopv1alpha2.ConfigurationStatus{
+					Nodes:   slices.Clone(tcase.nodes),
+					Rollout: tcase.rollout.DeepCopy(),
+				}
+				confStatus := configfile.ConfigurationStatus{
+					FileExists:  true,
+					ContentHash: node.ContentHash,
+					Content:     node.ContentHash,
+					Size:        int64(len(node.ContentHash)),
+					Owner:       "0:0",
+				}
+				aggregateNodeStatus(&status, 2, "rev2", confStatus, 64)
+				statuses = append(statuses, status)
+			}
+			for _, status := range statuses[1:] {
+				if !statusesAreEqual(&statuses[0], &status) {
+					t.Fatalf("status depends on the node: %+v != %+v", statuses[0], status)
+				}
+			}
+
+			status := statuses[0]
+			if status.ContentHash != tcase.expectedHash || status.FileExists != (tcase.expectedHash != "") || status.Owner != "" {
+				t.Fatalf("unexpected file status: %+v", status)
+			}
+			if status.ObservedGeneration != 2 {
+				t.Fatalf("unexpected observed generation: %d", status.ObservedGeneration)
+			}
+			available := findCondition(status.Conditions, ConditionAvailable)
+			if available == nil || available.Status != tcase.expectedAvailable || available.Reason != tcase.expectedReason {
+				t.Fatalf("unexpected available condition: %+v", available)
+			}
+			for _, cond := range status.Conditions {
+				for _, node := range tcase.nodes {
+					if strings.Contains(cond.Message, node.Name) {
+						t.Fatalf("condition %q names node %q: %q", cond.Type, node.Name, cond.Message)
+					}
+				}
+			}
+			if !isConditionEqual(status.Conditions, ConditionDegraded, tcase.expectedDegraded) {
+				t.Fatalf("unexpected degraded condition: %+v", findCondition(status.Conditions, ConditionDegraded))
+			}
+			if pending := findCondition(status.Conditions, ConditionPending); (pending != nil) != tcase.expectedPending {
+				t.Fatalf("unexpected pending condition: %+v", pending)
+			}
+		})
+	}
+}
+
+func TestDiffPreview(t *testing.T) {
+	type testCase struct {
+		name     string
+		diff     string
+		maxSize  int
+		expected string
+	}
+
+	testCases := []testCase{
+		{name: "short diff", diff: "-foo=1\n+foo=2\n", maxSize: 64, expected: "-foo=1\n+foo=2\n"},
+		{name: "cut at the end of a line", diff: "-foo=1\n+foo=2\n", maxSize: 10, expected: "-foo=1\n" + diffTruncatedNote},
+		{name: "single long line", diff: "+foo=1234567890\n", maxSize: 8, expected: "+foo=123" + diffTruncatedNote},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got := diffPreview(tcase.diff, tcase.maxSize)
+			if got != tcase.expected {
+				t.Fatalf("unexpected preview got=%q expected=%q", got, tcase.expected)
+			}
+		})
+	}
+}
+
 func findCondition(conditions []metav1.Condition, condition string) *metav1.Condition {
 	for idx := 0; idx < len(conditions); idx++ {
 		cond := &conditions[idx]
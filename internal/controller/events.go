@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"golab.io/kubedredger/internal/configfile"
+)
+
+const (
+	EventReasonCreated         = "Created"
+	EventReasonUpdated         = "Updated"
+	EventReasonDeleted         = "Deleted"
+	EventReasonDriftCorrected  = "DriftCorrected"
+	EventReasonWriteFailed     = "WriteFailed"
+	EventReasonNonRecoverable  = "NonRecoverable"
+	EventReasonForbidden       = "Forbidden"
+	EventReasonPolicyViolation = "PolicyViolation"
+	EventReasonInvalidSpec     = "InvalidSpec"
+	EventReasonDryRun          = "DryRun"
+	EventReasonDryRunFailed    = "DryRunFailed"
+	EventReasonReverted        = "Reverted"
+	EventReasonRevertFailed    = "RevertFailed"
+	// EventReasonRollout* and EventReasonCanary* are emitted by the rollout controller
+	EventReasonRolloutBatch     = "RolloutBatch"
+	EventReasonRolloutCompleted = "RolloutCompleted"
+	EventReasonRolloutAborted   = "RolloutAborted"
+	EventReasonCanaryPromoted   = "CanaryPromoted"
+)
+
+// eventFromOutcome returns the reason and the message of the event describing a successful sync.
+func eventFromOutcome(outcome configfile.SyncOutcome, fileName string) (string, string) {
+	switch outcome {
+	case configfile.SyncCreated:
+		return EventReasonCreated, fmt.Sprintf("configuration file %q created", fileName)
+	case configfile.SyncDriftCorrected:
+		return EventReasonDriftCorrected, fmt.Sprintf("configuration file %q was modified externally and has been restored", fileName)
+	default:
+		return EventReasonUpdated, fmt.Sprintf("configuration file %q updated", fileName)
+	}
+}
+
+// recordEvent emits an event about the given object, if events are enabled.
+// The node name is appended to the message, if known.
+func (r *ConfigurationReconciler) recordEvent(obj runtime.Object, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	if r.NodeName != "" {
+		message = fmt.Sprintf("%s on node %q", message, r.NodeName)
+	}
+	r.Recorder.Event(obj, eventType, reason, message)
+}
+
+// recordSyncEvent emits the event describing the result of a sync attempt.
+// Syncs finding the file already up to date emit no event: they happen on every
+// reconcile, including the ones triggered by the status updates of the agents.
+func (r *ConfigurationReconciler) recordSyncEvent(obj runtime.Object, fileName string, outcome configfile.SyncOutcome, err error) {
+	var nrErr configfile.NonRecoverableError
+	switch {
+	case errors.As(err, &nrErr):
+		r.recordEvent(obj, corev1.EventTypeWarning, EventReasonNonRecoverable, fmt.Sprintf("cannot sync configuration file %q: %v", fileName, err))
+	case err != nil:
+		r.recordEvent(obj, corev1.EventTypeWarning, EventReasonWriteFailed, fmt.Sprintf("failed to write configuration file %q: %v", fileName, err))
+	case outcome == configfile.SyncUnchanged:
+		return
+	default:
+		reason, message := eventFromOutcome(outcome, fileName)
+		r.recordEvent(obj, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// recordDryRunEvent emits the event describing the changes a sync or a deletion would make.
+func (r *ConfigurationReconciler) recordDryRunEvent(obj runtime.Object, fileName string, res configfile.DryRunResult, err error) {
+	if err != nil {
+		r.recordEvent(obj, corev1.EventTypeWarning, EventReasonDryRunFailed, fmt.Sprintf("dry run: cannot sync configuration file %q: %v", fileName, err))
+		return
+	}
+	r.recordEvent(obj, corev1.EventTypeNormal, EventReasonDryRun, dryRunMessage(res, fileName))
+}
+
+// dryRunMessage describes the changes a sync or a deletion would make.
+func dryRunMessage(res configfile.DryRunResult, fileName string) string {
+	var message string
+	switch res.Outcome {
+	case configfile.SyncCreated:
+		message = fmt.Sprintf("dry run: configuration file %q would be created with mode %s", fileName, modeToOctal(res.DesiredMode))
+	case configfile.SyncUpdated:
+		message = fmt.Sprintf("dry run: configuration file %q would be updated", fileName)
+	case configfile.SyncDriftCorrected:
+		message = fmt.Sprintf("dry run: configuration file %q was modified externally and would be restored", fileName)
+	case configfile.SyncDeleted:
+		return fmt.Sprintf("dry run: configuration file %q would be deleted", fileName)
+	default:
+		return fmt.Sprintf("dry run: configuration file %q already up to date", fileName)
+	}
+	if res.ModeChanged() {
+		message = fmt.Sprintf("%s, changing mode %s -> %s", message, modeToOctal(res.Mode), modeToOctal(res.DesiredMode))
+	}
+	return message
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"k8s.io/client-go/tools/record"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+)
+
+func TestSyncEvents(t *testing.T) {
+	confMgr := configfile.NewManagerWithOptions(fakeConfigRoot, configfile.Options{
+		Storage: configfile.NewMemoryStorage(),
+	})
+	_, nonRecoverableErr := confMgr.HandleSync(testr.New(t), configfile.ConfigRequest{
+		Filename: "missing.conf",
+	})
+	if !errors.As(nonRecoverableErr, &configfile.NonRecoverableError{}) {
+		t.Fatalf("unexpected sync error: %v", nonRecoverableErr)
+	}
+
+	type testCase struct {
+		name           string
+		nodeName       string
+		outcome        configfile.SyncOutcome
+		err            error
+		expectedPrefix string
+		expectedNode   bool
+	}
+
+	testCases := []testCase{
+		{
+			name:           "created",
+			outcome:        configfile.SyncCreated,
+			expectedPrefix: "Normal Created ",
+		},
+		{
+			name:           "updated",
+			outcome:        configfile.SyncUpdated,
+			expectedPrefix: "Normal Updated ",
+		},
+		{
+			name:    "unchanged",
+			outcome: configfile.SyncUnchanged,
+		},
+		{
+			name:           "drift corrected",
+			outcome:        configfile.SyncDriftCorrected,
+			expectedPrefix: "Normal DriftCorrected ",
+		},
+		{
+			name:           "write failed",
+			err:            errors.New("fake write error"),
+			expectedPrefix: "Warning WriteFailed ",
+		},
+		{
+			name:           "non recoverable",
+			err:            nonRecoverableErr,
+			expectedPrefix: "Warning NonRecoverable ",
+		},
+		{
+			name:           "on node",
+			nodeName:       "worker-0",
+			outcome:        configfile.SyncCreated,
+			expectedPrefix: "Normal Created ",
+			expectedNode:   true,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			rec := ConfigurationReconciler{
+				Recorder: recorder,
+				NodeName: tcase.nodeName,
+			}
+			rec.recordSyncEvent(&workshopv1alpha2.Configuration{}, "test.conf", tcase.outcome, tcase.err)
+
+			if tcase.expectedPrefix == "" {
+				if len(recorder.Events) > 0 {
+					t.Fatalf("unexpected event: %q", <-recorder.Events)
+				}
+				return
+			}
+			got := <-recorder.Events
+			if !strings.HasPrefix(got, tcase.expectedPrefix) {
+				t.Fatalf("unexpected event got=%q expected prefix=%q", got, tcase.expectedPrefix)
+			}
+			if hasNode := strings.Contains(got, `on node "worker-0"`); hasNode != tcase.expectedNode {
+				t.Fatalf("unexpected node in event: %q", got)
+			}
+		})
+	}
+}
+
+func TestDryRunEvents(t *testing.T) {
+	type testCase struct {
+		name     string
+		res      configfile.DryRunResult
+		err      error
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "create",
+			res:      configfile.DryRunResult{Outcome: configfile.SyncCreated, DesiredMode: 0640},
+			expected: `Normal DryRun dry run: configuration file "test.conf" would be created with mode 0640`,
+		},
+		{
+			name:     "update",
+			res:      configfile.DryRunResult{Outcome: configfile.SyncUpdated, Mode: 0644, DesiredMode: 0644},
+			expected: `Normal DryRun dry run: configuration file "test.conf" would be updated`,
+		},
+		{
+			name:     "update with mode change",
+			res:      configfile.DryRunResult{Outcome: configfile.SyncUpdated, Mode: 0644, DesiredMode: 0600},
+			expected: `Normal DryRun dry run: configuration file "test.conf" would be updated, changing mode 0644 -> 0600`,
+		},
+		{
+			name:     "drift",
+			res:      configfile.DryRunResult{Outcome: configfile.SyncDriftCorrected, Mode: 0644, DesiredMode: 0644},
+			expected: `Normal DryRun dry run: configuration file "test.conf" was modified externally and would be restored`,
+		},
+		{
+			name:     "delete",
+			res:      configfile.DryRunResult{Outcome: configfile.SyncDeleted, Mode: 0644},
+			expected: `Normal DryRun dry run: configuration file "test.conf" would be deleted`,
+		},
+		{
+			name:     "unchanged",
+			res:      configfile.DryRunResult{Outcome: configfile.SyncUnchanged, Mode: 0644, DesiredMode: 0644},
+			expected: `Normal DryRun dry run: configuration file "test.conf" already up to date`,
+		},
+		{
+			name:     "failure",
+			err:      errors.New("fake quota error"),
+			expected: `Warning DryRunFailed dry run: cannot sync configuration file "test.conf": fake quota error`,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			rec := ConfigurationReconciler{
+				Recorder: recorder,
+			}
+			rec.recordDryRunEvent(&workshopv1alpha2.Configuration{}, "test.conf", tcase.res, tcase.err)
+
+			got := <-recorder.Events
+			if got != tcase.expected {
+				t.Fatalf("unexpected event got=%q expected=%q", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestNoEventsWithoutRecorder(t *testing.T) {
+	rec := ConfigurationReconciler{}
+	// must not panic
+	rec.recordSyncEvent(&workshopv1alpha2.Configuration{}, "test.conf", configfile.SyncCreated, nil)
+}
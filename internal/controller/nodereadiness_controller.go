@@ -0,0 +1,218 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/nodelabel"
+)
+
+// NodeReadinessMode is how the agent reports on its node whether the configuration files are in place.
+type NodeReadinessMode string
+
+const (
+	// NodeReadinessTaint keeps the ConfigNotReadyTaint on the node until the files are in place
+	NodeReadinessTaint NodeReadinessMode = "taint"
+	// NodeReadinessCondition reports the ConfigurationsReady condition in the status of the node
+	NodeReadinessCondition NodeReadinessMode = "condition"
+)
+
+const (
+	// ConfigNotReadyTaint is the key of the taint kept on the node until the files are in place
+	ConfigNotReadyTaint = "workshop.golab.io/config-not-ready"
+	// NodeConditionConfigurationsReady is the type of the node condition reporting whether the files are in place
+	NodeConditionConfigurationsReady corev1.NodeConditionType = "ConfigurationsReady"
+
+	NodeConditionReasonAllAvailable = "AllAvailable"
+	NodeConditionReasonNotAvailable = "NotAvailable"
+)
+
+// notReadyMessageMaxItems is the maximum number of objects listed in the message of the node condition
+const notReadyMessageMaxItems = 10
+
+// NodeReadinessReconciler gates the node the agent runs on until the files of all the
+// configurations are in place, so the workloads depending on them are not scheduled before.
+// Like the agents, it runs on each node, and it updates only the node it runs on.
+type NodeReadinessReconciler struct {
+	client.Client
+	// NodeName is the name of the node this reconciler runs on
+	NodeName string
+	// Mode is how the readiness is reported on the node
+	Mode NodeReadinessMode
+	// Nodes updates the node this reconciler runs on
+	Nodes *nodelabel.Manager
+}
+
+// ParseNodeReadinessMode parses the given mode. The empty string is valid, and disables the gate.
+func ParseNodeReadinessMode(mode string) (NodeReadinessMode, error) {
+	switch res := NodeReadinessMode(mode); res {
+	case "", NodeReadinessTaint, NodeReadinessCondition:
+		return res, nil
+	default:
+		return "", fmt.Errorf("unknown node readiness mode %q, must be one of: %s, %s", mode, NodeReadinessTaint, NodeReadinessCondition)
+	}
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=get;patch
+
+// Reconcile taints the node, or reports its condition, according to the state of the files
+// of all the configurations on the node.
+func (r *NodeReadinessReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	notReady, err := r.notReadyConfigurations(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	lh := logf.FromContext(ctx)
+	if len(notReady) > 0 {
+		lh.V(1).Info("configurations not ready on the node", "node", r.NodeName, "configurations", notReady)
+	}
+
+	switch r.Mode {
+	case NodeReadinessTaint:
+		if len(notReady) > 0 {
+			err = r.Nodes.SetTaint(ctx, corev1.Taint{Key: ConfigNotReadyTaint, Effect: corev1.TaintEffectNoSchedule})
+		} else {
+			err = r.Nodes.ClearTaint(ctx, ConfigNotReadyTaint)
+		}
+	case NodeReadinessCondition:
+		err = r.Nodes.SetCondition(ctx, nodeReadinessCondition(notReady))
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update the readiness of node %q: %w", r.NodeName, err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// notReadyConfigurations returns the sorted keys of the configurations whose file is not in place on the node.
+func (r *NodeReadinessReconciler) notReadyConfigurations(ctx context.Context) ([]string, error) {
+	confs := workshopv1alpha2.ConfigurationList{}
+	if err := r.List(ctx, &confs); err != nil {
+		return nil, fmt.Errorf("failed to list the configurations: %w", err)
+	}
+	clusterConfs := workshopv1alpha2.ClusterConfigurationList{}
+	if err := r.List(ctx, &clusterConfs); err != nil {
+		return nil, fmt.Errorf("failed to list the cluster configurations: %w", err)
+	}
+	var res []string
+	for idx := range confs.Items {
+		if !configurationReady(&confs.Items[idx], r.NodeName) {
+			res = append(res, client.ObjectKeyFromObject(&confs.Items[idx]).String())
+		}
+	}
+	for idx := range clusterConfs.Items {
+		if !configurationReady(&clusterConfs.Items[idx], r.NodeName) {
+			res = append(res, clusterConfs.Items[idx].Name)
+		}
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// configurationReady tells if the file of the given object is in place on the given node: the node synced
+// a revision of the spec once. A node failing to sync a later revision, or waiting for a maintenance window,
+// keeps the file it has, so it is not gated again. The objects the node is not expected to apply, like the ones
+// being deleted, in dry run mode, rejected by the policies or by the agent, or whose rollout does not allow
+// the node yet, never make the node not ready.
+func configurationReady(conf configurationObject, nodeName string) bool {
+	status := conf.GetStatus()
+	switch {
+	case !conf.GetDeletionTimestamp().IsZero():
+		return true
+	case conf.GetAnnotations()[workshopv1alpha2.DryRunAnnotation] == "true":
+		return true
+	case meta.IsStatusConditionTrue(status.Conditions, ConditionPolicyViolation):
+		return true
+	case !rolloutAllows(*conf.GetSpec(), status.Rollout, nodeName):
+		return true
+	}
+	if cond := meta.FindStatusCondition(status.Conditions, ConditionDegraded); cond != nil && cond.Status == metav1.ConditionTrue && isRejectedReason(cond.Reason) {
+		return true
+	}
+	node, _ := findNodeStatus(status.Nodes, nodeName)
+	return node.Revision != ""
+}
+
+// isRejectedReason tells if the given reason of the Degraded condition reports the spec rejected
+// before writing the file: forbidden to the namespace or not valid for the agent.
+func isRejectedReason(reason string) bool {
+	switch reason {
+	case ConditionReasonForbidden, ConditionReasonInvalidSpec, ConditionReasonPermissionBroad, ConditionReasonSpecialBits:
+		return true
+	default:
+		return false
+	}
+}
+
+// nodeReadinessCondition returns the node condition reporting the given configurations not ready, if any.
+func nodeReadinessCondition(notReady []string) corev1.NodeCondition {
+	if len(notReady) == 0 {
+		return corev1.NodeCondition{
+			Type:    NodeConditionConfigurationsReady,
+			Status:  corev1.ConditionTrue,
+			Reason:  NodeConditionReasonAllAvailable,
+			Message: "all the configuration files are in place",
+		}
+	}
+	listed := notReady[:min(len(notReady), notReadyMessageMaxItems)]
+	message := fmt.Sprintf("configuration files not in place: %s", strings.Join(listed, ", "))
+	if extra := len(notReady) - len(listed); extra > 0 {
+		message = fmt.Sprintf("%s and %d more", message, extra)
+	}
+	return corev1.NodeCondition{
+		Type:    NodeConditionConfigurationsReady,
+		Status:  corev1.ConditionFalse,
+		Reason:  NodeConditionReasonNotAvailable,
+		Message: message,
+	}
+}
+
+// nodeRequest returns the request to reconcile the node this reconciler runs on, whatever object changed.
+func (r *NodeReadinessReconciler) nodeRequest(_ context.Context, _ client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: r.NodeName}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Only the node this reconciler runs on is watched, and any change to the configurations
+// triggers the evaluation of the node again.
+func (r *NodeReadinessReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetName() == r.NodeName
+		}))).
+		Watches(&workshopv1alpha2.Configuration{}, handler.EnqueueRequestsFromMapFunc(r.nodeRequest)).
+		Watches(&workshopv1alpha2.ClusterConfiguration{}, handler.EnqueueRequestsFromMapFunc(r.nodeRequest)).
+		Named("nodereadiness").
+		Complete(r)
+}
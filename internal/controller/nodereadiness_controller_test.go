@@ -0,0 +1,271 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/nodelabel"
+)
+
+func TestConfigurationReady(t *testing.T) {
+	now := metav1.Now()
+
+	type testCase struct {
+		name        string
+		annotations map[string]string
+		deleted     bool
+		spec        workshopv1alpha2.ConfigurationSpec
+		status      workshopv1alpha2.ConfigurationStatus
+		expected    bool
+	}
+
+	testCases := []testCase{
+		{
+			name: "not synced yet",
+		},
+		{
+			name: "synced",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: "rev1"}},
+			},
+			expected: true,
+		},
+		{
+			name: "synced on another node",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-b", Revision: "rev1"}},
+			},
+		},
+		{
+			name: "first sync failed",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Error: "no space left"}},
+			},
+		},
+		{
+			name: "later sync failed",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: "rev1", Error: "no space left"}},
+			},
+			expected: true,
+		},
+		{
+			name:     "rollout not planned yet",
+			spec:     workshopv1alpha2.ConfigurationSpec{Rollout: &workshopv1alpha2.RolloutStrategy{}},
+			expected: true,
+		},
+		{
+			name: "rollout not allowing the node",
+			spec: workshopv1alpha2.ConfigurationSpec{Rollout: &workshopv1alpha2.RolloutStrategy{}},
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Pending: ConditionReasonRolloutPending}},
+				Rollout: &workshopv1alpha2.RolloutStatus{
+					Revision:     configfile.SpecRevision(workshopv1alpha2.ConfigurationSpec{}),
+					Phase:        workshopv1alpha2.RolloutPhaseProgressing,
+					AllowedNodes: []string{"node-b"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "rollout allowing the node",
+			spec: workshopv1alpha2.ConfigurationSpec{Rollout: &workshopv1alpha2.RolloutStrategy{}},
+			status: workshopv1alpha2.ConfigurationStatus{
+				Rollout: &workshopv1alpha2.RolloutStatus{
+					Revision:     configfile.SpecRevision(workshopv1alpha2.ConfigurationSpec{}),
+					Phase:        workshopv1alpha2.RolloutPhaseProgressing,
+					AllowedNodes: []string{"node-a"},
+				},
+			},
+		},
+		{
+			name: "invalid spec",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Conditions: []metav1.Condition{{Type: ConditionDegraded, Status: metav1.ConditionTrue, Reason: ConditionReasonPermissionBroad}},
+			},
+			expected: true,
+		},
+		{
+			name:        "dry run",
+			annotations: map[string]string{workshopv1alpha2.DryRunAnnotation: "true"},
+			expected:    true,
+		},
+		{
+			name:     "being deleted",
+			deleted:  true,
+			expected: true,
+		},
+		{
+			name: "forbidden",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Conditions: []metav1.Condition{{Type: ConditionDegraded, Status: metav1.ConditionTrue, Reason: ConditionReasonForbidden}},
+			},
+			expected: true,
+		},
+		{
+			name: "policy violation",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Conditions: []metav1.Condition{{Type: ConditionPolicyViolation, Status: metav1.ConditionTrue, Reason: ConditionReasonFormat}},
+			},
+			expected: true,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			conf := &workshopv1alpha2.Configuration{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tcase.annotations},
+				Spec:       tcase.spec,
+				Status:     tcase.status,
+			}
+			if tcase.deleted {
+				conf.DeletionTimestamp = &now
+			}
+			if got := configurationReady(conf, "node-a"); got != tcase.expected {
+				t.Fatalf("unexpected result got=%v expected=%v", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestNodeReadinessCondition(t *testing.T) {
+	cond := nodeReadinessCondition(nil)
+	if cond.Status != corev1.ConditionTrue || cond.Reason != NodeConditionReasonAllAvailable {
+		t.Fatalf("unexpected condition: %+v", cond)
+	}
+
+	var notReady []string
+	for _, name := range strings.Split("a b c d e f g h i j k l", " ") {
+		notReady = append(notReady, "ns/"+name)
+	}
+	cond = nodeReadinessCondition(notReady)
+	if cond.Status != corev1.ConditionFalse || cond.Reason != NodeConditionReasonNotAvailable {
+		t.Fatalf("unexpected condition: %+v", cond)
+	}
+	if !strings.HasPrefix(cond.Message, "configuration files not in place: ns/a, ns/b") || !strings.HasSuffix(cond.Message, "ns/j and 2 more") {
+		t.Fatalf("unexpected condition message: %q", cond.Message)
+	}
+}
+
+func TestNodeReadinessReconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+	}
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "foo"},
+		Spec:       workshopv1alpha2.ConfigurationSpec{Filename: "foo.conf"},
+	}
+	clusterConf := &workshopv1alpha2.ClusterConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "bar"},
+		Spec:       workshopv1alpha2.ConfigurationSpec{Filename: "bar.conf"},
+		Status: workshopv1alpha2.ConfigurationStatus{
+			Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: "rev1"}},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&corev1.Node{}, &workshopv1alpha2.Configuration{}, &workshopv1alpha2.ClusterConfiguration{}).
+		WithObjects(node, conf, clusterConf).
+		Build()
+
+	type testCase struct {
+		mode  NodeReadinessMode
+		check func(t *testing.T, node *corev1.Node, ready bool)
+	}
+
+	testCases := []testCase{
+		{
+			mode: NodeReadinessTaint,
+			check: func(t *testing.T, node *corev1.Node, ready bool) {
+				tainted := len(node.Spec.Taints) == 1 && node.Spec.Taints[0].Key == ConfigNotReadyTaint
+				if tainted == ready {
+					t.Fatalf("unexpected taints with ready=%v: %+v", ready, node.Spec.Taints)
+				}
+			},
+		},
+		{
+			mode: NodeReadinessCondition,
+			check: func(t *testing.T, node *corev1.Node, ready bool) {
+				expected := corev1.ConditionFalse
+				if ready {
+					expected = corev1.ConditionTrue
+				}
+				if len(node.Status.Conditions) != 1 || node.Status.Conditions[0].Status != expected {
+					t.Fatalf("unexpected conditions with ready=%v: %+v", ready, node.Status.Conditions)
+				}
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(string(tcase.mode), func(t *testing.T) {
+			ctx := context.Background()
+			rec := NodeReadinessReconciler{
+				Client:   cli,
+				NodeName: node.Name,
+				Mode:     tcase.mode,
+				Nodes:    nodelabel.NewManager(node.Name, cli),
+			}
+			reconcileAndCheck := func(ready bool) {
+				t.Helper()
+				if _, err := rec.Reconcile(ctx, ctrl.Request{}); err != nil {
+					t.Fatalf("unexpected reconcile error: %v", err)
+				}
+				updatedNode := &corev1.Node{}
+				if err := cli.Get(ctx, client.ObjectKeyFromObject(node), updatedNode); err != nil {
+					t.Fatalf("cannot get updated node: %v", err)
+				}
+				tcase.check(t, updatedNode, ready)
+			}
+
+			current := &workshopv1alpha2.Configuration{}
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(conf), current); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			current.Status.Nodes = nil
+			if err := cli.Status().Update(ctx, current); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			reconcileAndCheck(false)
+
+			current.Status.Nodes = []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: "rev1"}}
+			if err := cli.Status().Update(ctx, current); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			reconcileAndCheck(true)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type printColumn struct {
+	Name     string
+	JSONPath string
+	Priority int32
+}
+
+var _ = Describe("Configuration CRD", func() {
+	DescribeTable("exposes the printer columns",
+		func(ctx context.Context, version string, expected []printColumn) {
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "configurations.workshop.golab.io"}, crd)).To(Succeed())
+
+			var got []printColumn
+			for _, ver := range crd.Spec.Versions {
+				if ver.Name != version {
+					continue
+				}
+				for _, col := range ver.AdditionalPrinterColumns {
+					got = append(got, printColumn{Name: col.Name, JSONPath: col.JSONPath, Priority: col.Priority})
+				}
+			}
+			Expect(got).To(Equal(expected))
+		},
+		Entry("v1alpha1", "v1alpha1", []printColumn{
+			{Name: "Filename", JSONPath: ".spec.filename"},
+			{Name: "Exists", JSONPath: ".status.fileExists"},
+			{Name: "Available", JSONPath: `.status.conditions[?(@.type=="Available")].status`},
+			{Name: "LastUpdate", JSONPath: ".status.lastUpdated"},
+			{Name: "Age", JSONPath: ".metadata.creationTimestamp"},
+		}),
+		Entry("v1alpha2", "v1alpha2", []printColumn{
+			{Name: "Filename", JSONPath: ".spec.filename"},
+			{Name: "Available", JSONPath: `.status.conditions[?(@.type=="Available")].status`},
+			{Name: "Degraded", JSONPath: `.status.conditions[?(@.type=="Degraded")].reason`},
+			{Name: "Nodes", JSONPath: ".status.targetNodes"},
+			{Name: "Age", JSONPath: ".metadata.creationTimestamp"},
+			{Name: "Hash", JSONPath: ".status.contentHash", Priority: 1},
+			{Name: "Permission", JSONPath: ".status.mode", Priority: 1},
+		}),
+	)
+})
@@ -0,0 +1,627 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+)
+
+// RolloutReconciler paces the rollout of the Configurations which set a rollout strategy.
+// Unlike the agents, it must run once per cluster: it allows the agents of the target nodes
+// to apply a new revision of the spec in batches, and reports the progress in the status.
+type RolloutReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Recorder emits the events about the progress of the rollouts.
+	// If nil, no events are emitted.
+	Recorder record.EventRecorder
+	// NodeSelector selects the nodes the configurations are rolled out to.
+	// If nil, all the nodes are.
+	NodeSelector labels.Selector
+	// AgentSelector selects the pods of the agents: the configurations are rolled out only
+	// to the nodes running an agent, the only ones which can apply them.
+	// If nil, all the nodes are assumed to run an agent.
+	AgentSelector labels.Selector
+	// AgentNamespace is the namespace of the pods of the agents.
+	// If empty, they are looked up in all the namespaces.
+	AgentNamespace string
+	// HealthChecker evaluates the health gates of the canary rollouts.
+	// If nil, the canaries never pass their health gates.
+	HealthChecker HealthChecker
+}
+
+// HealthChecker evaluates a health gate on a node, returning nil if all its checks pass.
+type HealthChecker interface {
+	Check(ctx context.Context, gate workshopv1alpha2.HealthGate, nodeName string) error
+}
+
+// ClusterRolloutReconciler paces the rollout of the ClusterConfigurations like RolloutReconciler does.
+type ClusterRolloutReconciler struct {
+	RolloutReconciler
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// Reconcile plans the next step of the rollout of the given Configuration.
+// The health gate is evaluated only if it checks what the namespace owns (see validate.HealthGate).
+func (r *RolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	conf := &workshopv1alpha2.Configuration{}
+	if err := r.Get(ctx, req.NamespacedName, conf); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return r.reconcileRollout(ctx, conf, func(ctx context.Context) error {
+		policies := workshopv1alpha2.ConfigurationPolicyList{}
+		if err := r.List(ctx, &policies); err != nil {
+			return fmt.Errorf("failed to list the configuration policies: %w", err)
+		}
+		return validate.HealthGate(conf.Namespace, conf.Spec, policies.Items)
+	})
+}
+
+// Reconcile plans the next step of the rollout of the given ClusterConfiguration.
+func (r *ClusterRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	conf := &workshopv1alpha2.ClusterConfiguration{}
+	if err := r.Get(ctx, req.NamespacedName, conf); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return r.reconcileRollout(ctx, conf, nil)
+}
+
+// reconcileRollout plans the next step of the rollout of the given object. If authorizeGate is not nil,
+// the health gate is evaluated only if it returns nil; otherwise the gate fails with its error.
+func (r *RolloutReconciler) reconcileRollout(ctx context.Context, conf configurationObject, authorizeGate func(ctx context.Context) error) (ctrl.Result, error) {
+	if !conf.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+	spec := conf.GetSpec()
+	status := conf.GetStatus()
+	pruned, err := r.pruneNodeStatus(ctx, status)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if spec.Rollout == nil {
+		if status.Rollout == nil && !pruned {
+			return ctrl.Result{}, nil
+		}
+		// all the nodes are free to apply the spec
+		status.Rollout = nil
+		return ctrl.Result{}, r.updateStatus(ctx, conf)
+	}
+
+	targetNodes, err := r.targetNodes(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	revision := configfile.SpecRevision(*spec)
+	current := status.Rollout
+	if current != nil && current.Revision != revision {
+		// the spec changed: the rollout starts over
+		current = nil
+	}
+	checkHealth := func(nodes []string) error {
+		if authorizeGate != nil {
+			if err := authorizeGate(ctx); err != nil {
+				return err
+			}
+		}
+		return r.checkHealth(ctx, spec.Rollout.Canary, nodes)
+	}
+	rollout, requeueAfter := planRollout(time.Now(), revision, *spec.Rollout, current, targetNodes, status.Nodes, checkHealth)
+	rollout.ObservedGeneration = conf.GetGeneration()
+	if !pruned && apiequality.Semantic.DeepEqual(status.Rollout, rollout) {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	logf.FromContext(ctx).Info("rollout progress", "revision", revision, "phase", rollout.Phase, "allowedNodes", rollout.AllowedNodes, "updatedNodes", rollout.UpdatedNodes, "targetNodes", rollout.TargetNodes)
+	r.recordRolloutEvents(conf, current, rollout)
+	status.Rollout = rollout
+	if err := r.updateStatus(ctx, conf); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// targetNodes returns the sorted names of the nodes the configurations are rolled out to:
+// the ones selected by NodeSelector which run an agent.
+func (r *RolloutReconciler) targetNodes(ctx context.Context) ([]string, error) {
+	nodes := corev1.NodeList{}
+	var opts []client.ListOption
+	if r.NodeSelector != nil {
+		opts = append(opts, client.MatchingLabelsSelector{Selector: r.NodeSelector})
+	}
+	if err := r.List(ctx, &nodes, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list the nodes: %w", err)
+	}
+	agentNodes, err := r.agentNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		if agentNodes != nil && !agentNodes.Has(node.Name) {
+			continue
+		}
+		names = append(names, node.Name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// agentNodes returns the names of the nodes running an agent, or nil if all the nodes are assumed to.
+// The agents not running anymore, or about to stop, can't apply the configurations.
+func (r *RolloutReconciler) agentNodes(ctx context.Context) (sets.Set[string], error) {
+	if r.AgentSelector == nil {
+		return nil, nil
+	}
+	pods := corev1.PodList{}
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: r.AgentSelector}}
+	if r.AgentNamespace != "" {
+		opts = append(opts, client.InNamespace(r.AgentNamespace))
+	}
+	if err := r.List(ctx, &pods, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list the agent pods: %w", err)
+	}
+	names := sets.New[string]()
+	for _, pod := range pods.Items {
+		if !isAgentPod(&pod) {
+			continue
+		}
+		names.Insert(pod.Spec.NodeName)
+	}
+	return names, nil
+}
+
+// isAgentPod tells if the given pod is an agent which runs, or is about to run, on a node.
+func isAgentPod(pod *corev1.Pod) bool {
+	if pod.Spec.NodeName == "" || !pod.DeletionTimestamp.IsZero() {
+		return false
+	}
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// pruneNodeStatus removes from the given status the entries of the nodes which left the cluster,
+// which the agents can't remove. Returns true if any was removed.
+func (r *RolloutReconciler) pruneNodeStatus(ctx context.Context, status *workshopv1alpha2.ConfigurationStatus) (bool, error) {
+	if len(status.Nodes) == 0 {
+		return false, nil
+	}
+	nodes := corev1.NodeList{}
+	if err := r.List(ctx, &nodes); err != nil {
+		return false, fmt.Errorf("failed to list the nodes: %w", err)
+	}
+	names := sets.New[string]()
+	for _, node := range nodes.Items {
+		names.Insert(node.Name)
+	}
+	count := len(status.Nodes)
+	status.Nodes = slices.DeleteFunc(status.Nodes, func(node workshopv1alpha2.NodeStatus) bool {
+		return !names.Has(node.Name)
+	})
+	return len(status.Nodes) != count, nil
+}
+
+// checkHealth evaluates the health gate of the given canary strategy on all the given nodes.
+func (r *RolloutReconciler) checkHealth(ctx context.Context, canary *workshopv1alpha2.CanaryStrategy, nodes []string) error {
+	if r.HealthChecker == nil {
+		return errors.New("no health checker configured")
+	}
+	var errs []error
+	for _, name := range nodes {
+		if err := r.HealthChecker.Check(ctx, canary.HealthGate, name); err != nil {
+			errs = append(errs, fmt.Errorf("node %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// updateStatus updates the status of the given object. On conflicts with the agents, which update
+// the status concurrently, the update is dropped: the rollout is planned again from the updated object,
+// which triggers a new reconcile.
+func (r *RolloutReconciler) updateStatus(ctx context.Context, conf configurationObject) error {
+	status := conf.GetStatus()
+	status.TargetNodes = targetNodeCount(status)
+	err := r.Client.Status().Update(ctx, conf)
+	if apierrors.IsConflict(err) {
+		logf.FromContext(ctx).V(1).Info("rollout status changed meanwhile, planning again", "object", client.ObjectKeyFromObject(conf))
+		return nil
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not update rollout status for object %s: %w", client.ObjectKeyFromObject(conf), err)
+	}
+	return nil
+}
+
+// recordRolloutEvents emits the events describing the step from the current rollout to the planned one.
+// The current rollout is nil if the revision changed.
+func (r *RolloutReconciler) recordRolloutEvents(obj runtime.Object, current, planned *workshopv1alpha2.RolloutStatus) {
+	if r.Recorder == nil {
+		return
+	}
+	var allowed []string
+	var currentPhase workshopv1alpha2.RolloutPhase
+	var promoted bool
+	if current != nil {
+		allowed = current.AllowedNodes
+		currentPhase = current.Phase
+		promoted = current.Canary != nil && current.Canary.Promoted
+	}
+	if planned.Phase == workshopv1alpha2.RolloutPhaseAborted {
+		if currentPhase != workshopv1alpha2.RolloutPhaseAborted {
+			r.Recorder.Event(obj, corev1.EventTypeWarning, EventReasonRolloutAborted, fmt.Sprintf("rollout of revision %s aborted: %s", planned.Revision, planned.Message))
+		}
+		return
+	}
+	if !promoted && planned.Canary != nil && planned.Canary.Promoted {
+		r.Recorder.Event(obj, corev1.EventTypeNormal, EventReasonCanaryPromoted, fmt.Sprintf("canary nodes %s passed the health gate, promoting revision %s", strings.Join(planned.Canary.Nodes, ", "), planned.Revision))
+	}
+	completed := currentPhase == workshopv1alpha2.RolloutPhaseCompleted
+	if planned.Phase == workshopv1alpha2.RolloutPhaseCompleted {
+		if !completed {
+			r.Recorder.Event(obj, corev1.EventTypeNormal, EventReasonRolloutCompleted, fmt.Sprintf("revision %s rolled out to %d nodes", planned.Revision, planned.TargetNodes))
+		}
+		return
+	}
+	var batch []string
+	for _, node := range planned.AllowedNodes {
+		if !slices.Contains(allowed, node) {
+			batch = append(batch, node)
+		}
+	}
+	if len(batch) > 0 {
+		r.Recorder.Event(obj, corev1.EventTypeNormal, EventReasonRolloutBatch, fmt.Sprintf("nodes %s allowed to apply revision %s (%d/%d updated)", strings.Join(batch, ", "), planned.Revision, planned.UpdatedNodes, planned.TargetNodes))
+	}
+}
+
+// configurationsForNode returns the requests to reconcile all the Configurations which set a rollout strategy,
+// or which report the state of the node of the given object, a node or an agent pod, if it left the cluster.
+func (r *RolloutReconciler) configurationsForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	nodeName := nodeNameOf(obj)
+	if nodeName == "" {
+		return nil
+	}
+	confs := workshopv1alpha2.ConfigurationList{}
+	if err := r.List(ctx, &confs); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list the configurations")
+		return nil
+	}
+	gone := r.nodeGone(ctx, nodeName)
+	var reqs []reconcile.Request
+	for _, conf := range confs.Items {
+		if affectedByNode(conf.Spec, conf.Status, nodeName, gone) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&conf)})
+		}
+	}
+	return reqs
+}
+
+// clusterConfigurationsForNode returns the requests to reconcile all the ClusterConfigurations which set a rollout strategy,
+// or which report the state of the node of the given object, a node or an agent pod, if it left the cluster.
+func (r *ClusterRolloutReconciler) clusterConfigurationsForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	nodeName := nodeNameOf(obj)
+	if nodeName == "" {
+		return nil
+	}
+	confs := workshopv1alpha2.ClusterConfigurationList{}
+	if err := r.List(ctx, &confs); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list the cluster configurations")
+		return nil
+	}
+	gone := r.nodeGone(ctx, nodeName)
+	var reqs []reconcile.Request
+	for _, conf := range confs.Items {
+		if affectedByNode(conf.Spec, conf.Status, nodeName, gone) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&conf)})
+		}
+	}
+	return reqs
+}
+
+// nodeNameOf returns the name of the node of the given object, a node or a pod.
+func nodeNameOf(obj client.Object) string {
+	if pod, ok := obj.(*corev1.Pod); ok {
+		return pod.Spec.NodeName
+	}
+	return obj.GetName()
+}
+
+// nodeGone tells if the given node left the cluster.
+func (r *RolloutReconciler) nodeGone(ctx context.Context, nodeName string) bool {
+	err := r.Get(ctx, client.ObjectKey{Name: nodeName}, &corev1.Node{})
+	return apierrors.IsNotFound(err)
+}
+
+// affectedByNode tells if a change of the given node, or of its agent, affects the configuration with the given spec and status:
+// its rollout may progress, or the state of the node, if it left the cluster, must be pruned.
+func affectedByNode(spec workshopv1alpha2.ConfigurationSpec, status workshopv1alpha2.ConfigurationStatus, nodeName string, gone bool) bool {
+	if spec.Rollout != nil {
+		return true
+	}
+	_, reported := findNodeStatus(status.Nodes, nodeName)
+	return gone && reported
+}
+
+// agentPredicate selects the pods of the agents.
+func (r *RolloutReconciler) agentPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		if r.AgentNamespace != "" && obj.GetNamespace() != r.AgentNamespace {
+			return false
+		}
+		return r.AgentSelector.Matches(labels.Set(obj.GetLabels()))
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// The rollouts are planned again when the nodes, or their agents, come and go.
+func (r *RolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&workshopv1alpha2.Configuration{}).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.configurationsForNode))
+	if r.AgentSelector != nil {
+		bldr = bldr.Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.configurationsForNode), builder.WithPredicates(r.agentPredicate()))
+	}
+	return bldr.Named("rollout").Complete(r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterRolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&workshopv1alpha2.ClusterConfiguration{}).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.clusterConfigurationsForNode))
+	if r.AgentSelector != nil {
+		bldr = bldr.Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.clusterConfigurationsForNode), builder.WithPredicates(r.agentPredicate()))
+	}
+	return bldr.Named("clusterrollout").Complete(r)
+}
+
+const (
+	// defaultGatePeriod is the time between two evaluations of a health gate, unless set by the spec
+	defaultGatePeriod = 10 * time.Second
+	// defaultGateTimeout is how long the canaries can take to pass the health gate, unless set by the spec
+	defaultGateTimeout = 5 * time.Minute
+)
+
+// planRollout computes the next step of the rollout of the given revision to the given target nodes,
+// from the current rollout of the same revision (nil if just started) and the state reported by the nodes.
+// A node is updated once it synced the revision without errors; the allowed nodes which are not updated
+// yet are unavailable. If the strategy requires canaries, they are allowed first, and the other nodes only
+// once checkHealth passes on all of them: if it does not pass in time, the rollout is aborted.
+// Then, a new batch of nodes is allowed only when there is room within maxUnavailable and the pause since
+// the last batch elapsed. The returned duration, if not zero, is the time left before the next step.
+// Once all the target nodes are updated the rollout completes, allowing any node, including the ones
+// joining later, to apply the revision.
+func planRollout(now time.Time, revision string, strategy workshopv1alpha2.RolloutStrategy, current *workshopv1alpha2.RolloutStatus, targetNodes []string, nodes []workshopv1alpha2.NodeStatus, checkHealth func(nodes []string) error) (*workshopv1alpha2.RolloutStatus, time.Duration) {
+	res := &workshopv1alpha2.RolloutStatus{
+		Revision:    revision,
+		Phase:       workshopv1alpha2.RolloutPhaseProgressing,
+		TargetNodes: int32(len(targetNodes)),
+	}
+	var currentPhase workshopv1alpha2.RolloutPhase
+	if current != nil {
+		res.AllowedNodes = slices.Clone(current.AllowedNodes)
+		res.LastBatchTime = current.LastBatchTime.DeepCopy()
+		res.Canary = current.Canary.DeepCopy()
+		res.Message = current.Message
+		currentPhase = current.Phase
+	}
+
+	var pending []string
+	for _, name := range targetNodes {
+		switch {
+		case isUpdated(nodes, name, revision):
+			res.UpdatedNodes++
+		case slices.Contains(res.AllowedNodes, name):
+			res.UnavailableNodes++
+		default:
+			pending = append(pending, name)
+		}
+	}
+
+	if currentPhase == workshopv1alpha2.RolloutPhaseAborted {
+		// only a new revision can start over
+		return abortRollout(res, res.Message), 0
+	}
+	if currentPhase == workshopv1alpha2.RolloutPhaseCompleted || res.UpdatedNodes == res.TargetNodes {
+		res.Phase = workshopv1alpha2.RolloutPhaseCompleted
+		res.AllowedNodes = nil
+		res.UnavailableNodes = 0
+		res.Message = ""
+		return res, 0
+	}
+	if strategy.Paused {
+		res.Phase = workshopv1alpha2.RolloutPhasePaused
+		return res, 0
+	}
+	if strategy.Canary != nil && (res.Canary == nil || !res.Canary.Promoted) {
+		if requeueAfter, promoted := planCanary(now, *strategy.Canary, res, pending, nodes, checkHealth); !promoted {
+			return res, requeueAfter
+		}
+		res.Phase = workshopv1alpha2.RolloutPhaseProgressing
+	}
+
+	maxUnavailable := 1
+	if strategy.MaxUnavailable != nil {
+		if val, err := intstr.GetScaledValueFromIntOrPercent(strategy.MaxUnavailable, len(targetNodes), true); err == nil {
+			maxUnavailable = max(val, 1)
+		}
+	}
+	batchSize := 1
+	if strategy.BatchSize != nil {
+		batchSize = max(int(*strategy.BatchSize), 1)
+	}
+	room := min(batchSize, maxUnavailable-int(res.UnavailableNodes), len(pending))
+	if room <= 0 {
+		// the agents updating their state in the status trigger the next step
+		return res, 0
+	}
+	if strategy.PauseBetweenBatches != nil && res.LastBatchTime != nil {
+		if left := res.LastBatchTime.Add(strategy.PauseBetweenBatches.Duration).Sub(now); left > 0 {
+			return res, left
+		}
+	}
+
+	allowNodes(now, res, pending[:room])
+	return res, 0
+}
+
+// planCanary computes the next step of the canary step of the given rollout, updating it in place.
+// Returns true once the canaries are promoted, otherwise the time left before the next step, if known.
+func planCanary(now time.Time, canary workshopv1alpha2.CanaryStrategy, res *workshopv1alpha2.RolloutStatus, pending []string, nodes []workshopv1alpha2.NodeStatus, checkHealth func(nodes []string) error) (time.Duration, bool) {
+	res.Phase = workshopv1alpha2.RolloutPhaseCanary
+	if res.Canary == nil {
+		count := 1
+		if canary.Nodes != nil {
+			if val, err := intstr.GetScaledValueFromIntOrPercent(canary.Nodes, int(res.TargetNodes), true); err == nil {
+				count = max(val, 1)
+			}
+		}
+		canaries := slices.Clone(pending[:min(count, len(pending))])
+		res.Canary = &workshopv1alpha2.CanaryStatus{Nodes: canaries}
+		allowNodes(now, res, canaries)
+		return 0, false
+	}
+
+	gate := canary.HealthGate
+	timeout := durationOrDefault(gate.Timeout, defaultGateTimeout)
+	var deadline time.Time
+	if res.LastBatchTime != nil {
+		deadline = res.LastBatchTime.Add(timeout)
+	}
+	var outdated []string
+	for _, name := range res.Canary.Nodes {
+		if !isUpdated(nodes, name, res.Revision) {
+			outdated = append(outdated, name)
+		}
+	}
+	if len(outdated) > 0 {
+		if !now.Before(deadline) {
+			abortRollout(res, fmt.Sprintf("canary nodes %s did not apply the revision within %v", strings.Join(outdated, ", "), timeout))
+			return 0, false
+		}
+		// the agents updating their state in the status trigger the next step earlier
+		return deadline.Sub(now), false
+	}
+
+	if res.Canary.UpdatedTime == nil {
+		res.Canary.UpdatedTime = ptr.To(metav1.NewTime(now))
+	}
+	if evalTime := res.Canary.UpdatedTime.Add(durationOrDefault(gate.InitialDelay, 0)); now.Before(evalTime) {
+		return evalTime.Sub(now), false
+	}
+	if err := checkHealth(res.Canary.Nodes); err != nil {
+		if !now.Before(deadline) {
+			abortRollout(res, fmt.Sprintf("health gate failed: %v", err))
+			return 0, false
+		}
+		res.Message = fmt.Sprintf("health gate not passed yet: %v", err)
+		return min(durationOrDefault(gate.Period, defaultGatePeriod), deadline.Sub(now)), false
+	}
+	res.Canary.Promoted = true
+	res.Message = ""
+	return 0, true
+}
+
+// allowNodes allows the given nodes to apply the revision of the given rollout, as a new batch.
+func allowNodes(now time.Time, res *workshopv1alpha2.RolloutStatus, names []string) {
+	res.AllowedNodes = append(res.AllowedNodes, names...)
+	slices.Sort(res.AllowedNodes)
+	res.UnavailableNodes += int32(len(names))
+	res.LastBatchTime = ptr.To(metav1.NewTime(now))
+}
+
+// abortRollout marks the given rollout as aborted: no node is allowed to apply the revision anymore,
+// and the nodes which applied it revert the file.
+func abortRollout(res *workshopv1alpha2.RolloutStatus, message string) *workshopv1alpha2.RolloutStatus {
+	res.Phase = workshopv1alpha2.RolloutPhaseAborted
+	res.AllowedNodes = nil
+	res.UnavailableNodes = 0
+	res.Message = message
+	return res
+}
+
+func durationOrDefault(dur *metav1.Duration, def time.Duration) time.Duration {
+	if dur == nil {
+		return def
+	}
+	return dur.Duration
+}
+
+// targetNodeCount returns the number of nodes the configuration with the given status is applied on:
+// the nodes its rollout targets, if paced, otherwise the nodes whose agents report the state of the file.
+func targetNodeCount(status *workshopv1alpha2.ConfigurationStatus) int32 {
+	if status.Rollout != nil {
+		return status.Rollout.TargetNodes
+	}
+	return int32(len(status.Nodes))
+}
+
+// isUpdated tells if the given node synced the given revision without errors.
+func isUpdated(nodes []workshopv1alpha2.NodeStatus, name, revision string) bool {
+	node, ok := findNodeStatus(nodes, name)
+	return ok && node.Revision == revision && node.Error == ""
+}
+
+// rolloutAllows tells if the given node can apply the given spec, according to the given rollout.
+// If the spec sets no rollout strategy, all the nodes can.
+func rolloutAllows(spec workshopv1alpha2.ConfigurationSpec, rollout *workshopv1alpha2.RolloutStatus, nodeName string) bool {
+	if spec.Rollout == nil {
+		return true
+	}
+	if rollout == nil || rollout.Revision != configfile.SpecRevision(spec) {
+		// the rollout controller did not plan the rollout of this revision yet
+		return false
+	}
+	return rollout.Phase == workshopv1alpha2.RolloutPhaseCompleted || slices.Contains(rollout.AllowedNodes, nodeName)
+}
+
+// rolloutReverts tells if the given node must revert the file, because it applied the revision
+// of the given spec, whose rollout was aborted.
+func rolloutReverts(spec workshopv1alpha2.ConfigurationSpec, rollout *workshopv1alpha2.RolloutStatus, nodes []workshopv1alpha2.NodeStatus, nodeName string) bool {
+	if spec.Rollout == nil || rollout == nil || rollout.Phase != workshopv1alpha2.RolloutPhaseAborted {
+		return false
+	}
+	node, ok := findNodeStatus(nodes, nodeName)
+	return ok && node.Revision == rollout.Revision && rollout.Revision == configfile.SpecRevision(spec)
+}
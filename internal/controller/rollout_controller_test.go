@@ -0,0 +1,847 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+)
+
+func TestRolloutPlan(t *testing.T) {
+	const revision = "rev2"
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	lastBatch := metav1.NewTime(now.Add(-30 * time.Second))
+	targetNodes := []string{"node-a", "node-b", "node-c", "node-d"}
+	updated := func(names ...string) []workshopv1alpha2.NodeStatus {
+		var nodes []workshopv1alpha2.NodeStatus
+		for _, name := range names {
+			nodes = append(nodes, workshopv1alpha2.NodeStatus{Name: name, Revision: revision})
+		}
+		return nodes
+	}
+
+	type testCase struct {
+		name                 string
+		strategy             workshopv1alpha2.RolloutStrategy
+		current              *workshopv1alpha2.RolloutStatus
+		nodes                []workshopv1alpha2.NodeStatus
+		expected             *workshopv1alpha2.RolloutStatus
+		expectedRequeueAfter time.Duration
+	}
+
+	testCases := []testCase{
+		{
+			name: "first batch with defaults",
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev1"},
+			},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:     []string{"node-a"},
+				TargetNodes:      4,
+				UnavailableNodes: 1,
+				LastBatchTime:    ptr.To(metav1.NewTime(now)),
+			},
+		},
+		{
+			name: "batch bounded by max unavailable",
+			strategy: workshopv1alpha2.RolloutStrategy{
+				MaxUnavailable: ptr.To(intstr.FromString("50%")),
+				BatchSize:      ptr.To[int32](3),
+			},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:     []string{"node-a", "node-b"},
+				TargetNodes:      4,
+				UnavailableNodes: 2,
+				LastBatchTime:    ptr.To(metav1.NewTime(now)),
+			},
+		},
+		{
+			name: "waiting for the allowed nodes",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lastBatch,
+			},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:     []string{"node-a"},
+				TargetNodes:      4,
+				UnavailableNodes: 1,
+				LastBatchTime:    &lastBatch,
+			},
+		},
+		{
+			name: "failing node blocks the rollout",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lastBatch,
+			},
+			nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: revision, Error: "no space left"},
+			},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:     []string{"node-a"},
+				TargetNodes:      4,
+				UnavailableNodes: 1,
+				LastBatchTime:    &lastBatch,
+			},
+		},
+		{
+			name: "next batch",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lastBatch,
+			},
+			nodes: updated("node-a"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:     []string{"node-a", "node-b"},
+				TargetNodes:      4,
+				UpdatedNodes:     1,
+				UnavailableNodes: 1,
+				LastBatchTime:    ptr.To(metav1.NewTime(now)),
+			},
+		},
+		{
+			name: "pause between batches",
+			strategy: workshopv1alpha2.RolloutStrategy{
+				PauseBetweenBatches: &metav1.Duration{Duration: time.Minute},
+			},
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lastBatch,
+			},
+			nodes: updated("node-a"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-a"},
+				TargetNodes:   4,
+				UpdatedNodes:  1,
+				LastBatchTime: &lastBatch,
+			},
+			expectedRequeueAfter: 30 * time.Second,
+		},
+		{
+			name: "paused",
+			strategy: workshopv1alpha2.RolloutStrategy{
+				Paused: true,
+			},
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lastBatch,
+			},
+			nodes: updated("node-a"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhasePaused,
+				AllowedNodes:  []string{"node-a"},
+				TargetNodes:   4,
+				UpdatedNodes:  1,
+				LastBatchTime: &lastBatch,
+			},
+		},
+		{
+			name: "completed",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:  []string{"node-c", "node-d"},
+				LastBatchTime: &lastBatch,
+			},
+			nodes: updated("node-a", "node-b", "node-c", "node-d"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCompleted,
+				TargetNodes:   4,
+				UpdatedNodes:  4,
+				LastBatchTime: &lastBatch,
+			},
+		},
+		{
+			name: "completed stays completed when nodes join",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCompleted,
+				LastBatchTime: &lastBatch,
+			},
+			nodes: updated("node-a", "node-b", "node-c"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCompleted,
+				TargetNodes:   4,
+				UpdatedNodes:  3,
+				LastBatchTime: &lastBatch,
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got, requeueAfter := planRollout(now, revision, tcase.strategy, tcase.current, targetNodes, tcase.nodes, nil)
+			if diff := cmp.Diff(got, tcase.expected); diff != "" {
+				t.Fatalf("unexpected rollout: %s", diff)
+			}
+			if requeueAfter != tcase.expectedRequeueAfter {
+				t.Fatalf("unexpected requeue got=%v expected=%v", requeueAfter, tcase.expectedRequeueAfter)
+			}
+		})
+	}
+}
+
+func TestRolloutCanary(t *testing.T) {
+	const revision = "rev2"
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	canaryTime := metav1.NewTime(now.Add(-time.Minute))
+	lateCanaryTime := metav1.NewTime(now.Add(-10 * time.Minute))
+	targetNodes := []string{"node-a", "node-b", "node-c", "node-d"}
+	strategy := workshopv1alpha2.RolloutStrategy{
+		MaxUnavailable: ptr.To(intstr.FromInt32(2)),
+		BatchSize:      ptr.To[int32](2),
+		Canary: &workshopv1alpha2.CanaryStrategy{
+			Nodes: ptr.To(intstr.FromString("25%")),
+			HealthGate: workshopv1alpha2.HealthGate{
+				InitialDelay: &metav1.Duration{Duration: time.Minute},
+			},
+		},
+	}
+	canaryUpdated := []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: revision}}
+
+	type testCase struct {
+		name                 string
+		current              *workshopv1alpha2.RolloutStatus
+		nodes                []workshopv1alpha2.NodeStatus
+		healthErr            error
+		expected             *workshopv1alpha2.RolloutStatus
+		expectedRequeueAfter time.Duration
+	}
+
+	testCases := []testCase{
+		{
+			name: "canaries allowed first",
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:     []string{"node-a"},
+				TargetNodes:      4,
+				UnavailableNodes: 1,
+				LastBatchTime:    ptr.To(metav1.NewTime(now)),
+				Canary:           &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+			},
+		},
+		{
+			name: "waiting for the canaries to apply the revision",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &canaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+			},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:     []string{"node-a"},
+				TargetNodes:      4,
+				UnavailableNodes: 1,
+				LastBatchTime:    &canaryTime,
+				Canary:           &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+			},
+			expectedRequeueAfter: 4 * time.Minute,
+		},
+		{
+			name: "canaries updated, waiting for the initial delay",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &canaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+			},
+			nodes: canaryUpdated,
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				TargetNodes:   4,
+				UpdatedNodes:  1,
+				LastBatchTime: &canaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: ptr.To(metav1.NewTime(now))},
+			},
+			expectedRequeueAfter: time.Minute,
+		},
+		{
+			name: "health gate not passed yet",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &canaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &canaryTime},
+			},
+			nodes:     canaryUpdated,
+			healthErr: errors.New("probe returned 503"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				TargetNodes:   4,
+				UpdatedNodes:  1,
+				LastBatchTime: &canaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &canaryTime},
+				Message:       "health gate not passed yet: probe returned 503",
+			},
+			expectedRequeueAfter: 10 * time.Second,
+		},
+		{
+			name: "health gate failed after the timeout",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lateCanaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &lateCanaryTime},
+			},
+			nodes:     canaryUpdated,
+			healthErr: errors.New("probe returned 503"),
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseAborted,
+				TargetNodes:   4,
+				UpdatedNodes:  1,
+				LastBatchTime: &lateCanaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &lateCanaryTime},
+				Message:       "health gate failed: probe returned 503",
+			},
+		},
+		{
+			name: "canaries not updated in time",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &lateCanaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+			},
+			nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: revision, Error: "no space left"}},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseAborted,
+				TargetNodes:   4,
+				LastBatchTime: &lateCanaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+				Message:       "canary nodes node-a did not apply the revision within 5m0s",
+			},
+		},
+		{
+			name: "health gate passed, canaries promoted",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseCanary,
+				AllowedNodes:  []string{"node-a"},
+				LastBatchTime: &canaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &canaryTime},
+				Message:       "health gate not passed yet: probe returned 503",
+			},
+			nodes: canaryUpdated,
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:         revision,
+				Phase:            workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes:     []string{"node-a", "node-b", "node-c"},
+				TargetNodes:      4,
+				UpdatedNodes:     1,
+				UnavailableNodes: 2,
+				LastBatchTime:    ptr.To(metav1.NewTime(now)),
+				Canary:           &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &canaryTime, Promoted: true},
+			},
+		},
+		{
+			name: "aborted stays aborted",
+			current: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseAborted,
+				LastBatchTime: &lateCanaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+				Message:       "health gate failed: probe returned 503",
+			},
+			expected: &workshopv1alpha2.RolloutStatus{
+				Revision:      revision,
+				Phase:         workshopv1alpha2.RolloutPhaseAborted,
+				TargetNodes:   4,
+				LastBatchTime: &lateCanaryTime,
+				Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}},
+				Message:       "health gate failed: probe returned 503",
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			checkHealth := func(nodes []string) error {
+				if diff := cmp.Diff(nodes, []string{"node-a"}); diff != "" {
+					t.Fatalf("unexpected nodes checked: %s", diff)
+				}
+				return tcase.healthErr
+			}
+			got, requeueAfter := planRollout(now, revision, strategy, tcase.current, targetNodes, tcase.nodes, checkHealth)
+			if diff := cmp.Diff(got, tcase.expected); diff != "" {
+				t.Fatalf("unexpected rollout: %s", diff)
+			}
+			if requeueAfter != tcase.expectedRequeueAfter {
+				t.Fatalf("unexpected requeue got=%v expected=%v", requeueAfter, tcase.expectedRequeueAfter)
+			}
+		})
+	}
+}
+
+func TestRolloutAllows(t *testing.T) {
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Filename: "foo.conf",
+		Content:  "foo=1\n",
+		Rollout:  &workshopv1alpha2.RolloutStrategy{},
+	}
+	revision := configfile.SpecRevision(spec)
+
+	type testCase struct {
+		name     string
+		spec     workshopv1alpha2.ConfigurationSpec
+		rollout  *workshopv1alpha2.RolloutStatus
+		expected bool
+	}
+
+	testCases := []testCase{
+		{
+			name:     "no rollout strategy",
+			spec:     workshopv1alpha2.ConfigurationSpec{Filename: "foo.conf"},
+			expected: true,
+		},
+		{
+			name: "rollout not planned yet",
+			spec: spec,
+		},
+		{
+			name: "rollout of a previous revision",
+			spec: spec,
+			rollout: &workshopv1alpha2.RolloutStatus{
+				Revision:     "previous",
+				Phase:        workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes: []string{"node-a"},
+			},
+		},
+		{
+			name: "node not allowed",
+			spec: spec,
+			rollout: &workshopv1alpha2.RolloutStatus{
+				Revision:     revision,
+				Phase:        workshopv1alpha2.RolloutPhaseProgressing,
+				AllowedNodes: []string{"node-b"},
+			},
+		},
+		{
+			name: "node allowed",
+			spec: spec,
+			rollout: &workshopv1alpha2.RolloutStatus{
+				Revision:     revision,
+				Phase:        workshopv1alpha2.RolloutPhasePaused,
+				AllowedNodes: []string{"node-a"},
+			},
+			expected: true,
+		},
+		{
+			name: "rollout completed",
+			spec: spec,
+			rollout: &workshopv1alpha2.RolloutStatus{
+				Revision: revision,
+				Phase:    workshopv1alpha2.RolloutPhaseCompleted,
+			},
+			expected: true,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if got := rolloutAllows(tcase.spec, tcase.rollout, "node-a"); got != tcase.expected {
+				t.Fatalf("unexpected result got=%v expected=%v", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestRolloutNodeStatus(t *testing.T) {
+	status := workshopv1alpha2.ConfigurationStatus{}
+	setNodeStatus(&status, workshopv1alpha2.NodeStatus{Name: "node-b", Revision: "rev1"})
+	setNodeStatus(&status, workshopv1alpha2.NodeStatus{Name: "node-a", Revision: "rev1"})
+
+	previous, ok := findNodeStatus(status.Nodes, "node-b")
+	if !ok {
+		t.Fatalf("missing node status")
+	}
+	// a failed sync keeps reporting the revision last synced
+	setNodeStatus(&status, nodeStatusAfterSync(previous, 2, "rev2", configfile.ConfigurationStatus{ContentHash: "hash1"}, errors.New("no space left")))
+
+	expected := []workshopv1alpha2.NodeStatus{
+		{Name: "node-a", Revision: "rev1"},
+		{Name: "node-b", Revision: "rev1", ContentHash: "hash1", Error: "no space left"},
+	}
+	if diff := cmp.Diff(status.Nodes, expected); diff != "" {
+		t.Fatalf("unexpected nodes: %s", diff)
+	}
+
+	previous, _ = findNodeStatus(status.Nodes, "node-b")
+	setNodeStatus(&status, nodeStatusAfterSync(previous, 2, "rev2", configfile.ConfigurationStatus{ContentHash: "hash2"}, nil))
+	expected[1] = workshopv1alpha2.NodeStatus{Name: "node-b", ObservedGeneration: 2, Revision: "rev2", ContentHash: "hash2"}
+	if diff := cmp.Diff(status.Nodes, expected); diff != "" {
+		t.Fatalf("unexpected nodes: %s", diff)
+	}
+}
+
+func TestRolloutReverts(t *testing.T) {
+	spec := workshopv1alpha2.ConfigurationSpec{
+		Filename: "foo.conf",
+		Content:  "foo=1\n",
+		Rollout:  &workshopv1alpha2.RolloutStrategy{},
+	}
+	revision := configfile.SpecRevision(spec)
+	aborted := &workshopv1alpha2.RolloutStatus{
+		Revision: revision,
+		Phase:    workshopv1alpha2.RolloutPhaseAborted,
+	}
+
+	type testCase struct {
+		name     string
+		rollout  *workshopv1alpha2.RolloutStatus
+		nodes    []workshopv1alpha2.NodeStatus
+		expected bool
+	}
+
+	testCases := []testCase{
+		{
+			name:    "rollout progressing",
+			rollout: &workshopv1alpha2.RolloutStatus{Revision: revision, Phase: workshopv1alpha2.RolloutPhaseCanary},
+			nodes:   []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: revision}},
+		},
+		{
+			name:     "node applied the aborted revision",
+			rollout:  aborted,
+			nodes:    []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: revision}},
+			expected: true,
+		},
+		{
+			name:    "node already reverted",
+			rollout: aborted,
+			nodes:   []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: "previous"}},
+		},
+		{
+			name:    "node never applied the revision",
+			rollout: aborted,
+			nodes:   []workshopv1alpha2.NodeStatus{{Name: "node-b", Revision: revision}},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if got := rolloutReverts(spec, tcase.rollout, tcase.nodes, "node-a"); got != tcase.expected {
+				t.Fatalf("unexpected result got=%v expected=%v", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestRolloutTargetNodes(t *testing.T) {
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	agentLabels := map[string]string{"control-plane": "agent"}
+	agentPod := func(name, nodeName string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: name, Labels: agentLabels},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	otherPod := agentPod("other", "node-c", corev1.PodRunning)
+	otherPod.Labels = map[string]string{"app": "other"}
+	otherNamespace := agentPod("agent-d", "node-d", corev1.PodRunning)
+	otherNamespace.Namespace = "elsewhere"
+	cli := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-c"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-d"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-e"}},
+		agentPod("agent-a", "node-a", corev1.PodRunning),
+		agentPod("agent-b", "node-b", corev1.PodFailed),
+		otherPod,
+		otherNamespace,
+		agentPod("agent-e", "node-e", corev1.PodPending),
+		agentPod("agent-unscheduled", "", corev1.PodPending),
+	).Build()
+
+	type testCase struct {
+		name           string
+		agentSelector  labels.Selector
+		agentNamespace string
+		expected       []string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "no agent selector",
+			expected: []string{"node-a", "node-b", "node-c", "node-d", "node-e"},
+		},
+		{
+			name:           "agents in a namespace",
+			agentSelector:  labels.SelectorFromSet(agentLabels),
+			agentNamespace: "system",
+			expected:       []string{"node-a", "node-e"},
+		},
+		{
+			name:          "agents in all the namespaces",
+			agentSelector: labels.SelectorFromSet(agentLabels),
+			expected:      []string{"node-a", "node-d", "node-e"},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			rec := RolloutReconciler{
+				Client:         cli,
+				AgentSelector:  tcase.agentSelector,
+				AgentNamespace: tcase.agentNamespace,
+			}
+			got, err := rec.targetNodes(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(got, tcase.expected); diff != "" {
+				t.Fatalf("unexpected target nodes: %s", diff)
+			}
+		})
+	}
+}
+
+func TestRolloutPruneNodeStatus(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	conf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "foo"},
+		Spec:       workshopv1alpha2.ConfigurationSpec{Filename: "foo.conf"},
+		Status: workshopv1alpha2.ConfigurationStatus{
+			Nodes: []workshopv1alpha2.NodeStatus{
+				{Name: "node-a", Revision: "rev1"},
+				{Name: "node-gone", Revision: "rev1"},
+			},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+		WithObjects(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}, conf).
+		Build()
+	rec := RolloutReconciler{Client: cli}
+
+	reqs := rec.configurationsForNode(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-gone"}})
+	if len(reqs) != 1 || reqs[0].NamespacedName != client.ObjectKeyFromObject(conf) {
+		t.Fatalf("unexpected requests for the node which left: %v", reqs)
+	}
+	if reqs := rec.configurationsForNode(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}); len(reqs) != 0 {
+		t.Fatalf("unexpected requests for the node still there: %v", reqs)
+	}
+
+	if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(conf)}); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	updated := &workshopv1alpha2.Configuration{}
+	if err := cli.Get(ctx, client.ObjectKeyFromObject(conf), updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: "rev1"}}
+	if diff := cmp.Diff(updated.Status.Nodes, expected); diff != "" {
+		t.Fatalf("unexpected nodes: %s", diff)
+	}
+}
+
+type countingHealthChecker struct {
+	calls int
+}
+
+func (hc *countingHealthChecker) Check(_ context.Context, _ workshopv1alpha2.HealthGate, _ string) error {
+	hc.calls++
+	return nil
+}
+
+func TestRolloutHealthGateNamespace(t *testing.T) {
+	ctx := context.Background()
+	testScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(testScheme); err != nil {
+		t.Fatalf("cannot register to scheme: %v", err)
+	}
+
+	type testCase struct {
+		name          string
+		gate          workshopv1alpha2.HealthGate
+		expectedCalls int
+	}
+
+	testCases := []testCase{
+		{
+			name:          "pods of the namespace",
+			gate:          workshopv1alpha2.HealthGate{PodReadiness: &workshopv1alpha2.PodReadinessGate{Namespace: "ns"}},
+			expectedCalls: 1,
+		},
+		{
+			name: "pods of another namespace",
+			gate: workshopv1alpha2.HealthGate{PodReadiness: &workshopv1alpha2.PodReadinessGate{Namespace: "kube-system"}},
+		},
+		{
+			name: "HTTP not allowed by any policy",
+			gate: workshopv1alpha2.HealthGate{HTTPGet: &workshopv1alpha2.HTTPGetGate{Port: 8080}},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.conf",
+				Rollout: &workshopv1alpha2.RolloutStrategy{
+					Canary: &workshopv1alpha2.CanaryStrategy{HealthGate: tcase.gate},
+				},
+			}
+			revision := configfile.SpecRevision(spec)
+			startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+			conf := &workshopv1alpha2.Configuration{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "foo"},
+				Spec:       spec,
+				Status: workshopv1alpha2.ConfigurationStatus{
+					Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a", Revision: revision}},
+					Rollout: &workshopv1alpha2.RolloutStatus{
+						Revision:      revision,
+						Phase:         workshopv1alpha2.RolloutPhaseCanary,
+						AllowedNodes:  []string{"node-a"},
+						LastBatchTime: &startTime,
+						Canary:        &workshopv1alpha2.CanaryStatus{Nodes: []string{"node-a"}, UpdatedTime: &startTime},
+					},
+				},
+			}
+			cli := fake.NewClientBuilder().WithScheme(testScheme).
+				WithStatusSubresource(&workshopv1alpha2.Configuration{}).
+				WithObjects(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}}, conf).
+				Build()
+			checker := &countingHealthChecker{}
+			rec := RolloutReconciler{Client: cli, HealthChecker: checker}
+
+			if _, err := rec.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(conf)}); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			if checker.calls != tcase.expectedCalls {
+				t.Fatalf("unexpected health checks got=%d expected=%d", checker.calls, tcase.expectedCalls)
+			}
+			if tcase.expectedCalls > 0 {
+				return
+			}
+			updated := &workshopv1alpha2.Configuration{}
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(conf), updated); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg := updated.Status.Rollout.Message; !strings.Contains(msg, validate.ErrForbiddenHealthGate.Error()) {
+				t.Fatalf("unexpected rollout message %q", msg)
+			}
+		})
+	}
+}
+
+func TestTargetNodeCount(t *testing.T) {
+	type testCase struct {
+		name     string
+		status   workshopv1alpha2.ConfigurationStatus
+		expected int32
+	}
+
+	testCases := []testCase{
+		{
+			name: "no node reported yet",
+		},
+		{
+			name: "nodes reporting",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes: []workshopv1alpha2.NodeStatus{{Name: "node-a"}, {Name: "node-b"}},
+			},
+			expected: 2,
+		},
+		{
+			name: "rollout targets",
+			status: workshopv1alpha2.ConfigurationStatus{
+				Nodes:   []workshopv1alpha2.NodeStatus{{Name: "node-a"}},
+				Rollout: &workshopv1alpha2.RolloutStatus{TargetNodes: 3},
+			},
+			expected: 3,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if got := targetNodeCount(&tcase.status); got != tcase.expected {
+				t.Fatalf("unexpected target nodes got=%d expected=%d", got, tcase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package healthgate evaluates the health gates of the canary rollouts on the nodes.
+// It is meant to be used by the rollout controller, which runs once per cluster.
+package healthgate
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+)
+
+// DefaultProbeTimeout is how long an HTTP probe can take, unless configured otherwise
+const DefaultProbeTimeout = 5 * time.Second
+
+var (
+	// ErrUnhealthy is returned, possibly wrapped, when a check of the gate fails.
+	ErrUnhealthy = errors.New("unhealthy")
+	// ErrNoAddress is returned when the node has no address to probe.
+	ErrNoAddress = errors.New("node has no address")
+)
+
+// Checker evaluates the health gates. It is safe for concurrent use.
+type Checker struct {
+	client     client.Reader
+	httpClient *http.Client
+}
+
+// New creates a Checker reading the nodes and the pods using the given client.
+// If httpClient is nil, the probes use a client which gives up after DefaultProbeTimeout
+// and, like the kubelet probes, does not verify the certificates.
+func New(cli client.Reader, httpClient *http.Client) *Checker {
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: DefaultProbeTimeout,
+			Transport: &http.Transport{
+				// the endpoints are node-local, often with self-signed certificates
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+			},
+			// the redirects are followed only within the node, like the kubelet does
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Hostname() != via[0].URL.Hostname() {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		}
+	}
+	return &Checker{
+		client:     cli,
+		httpClient: httpClient,
+	}
+}
+
+// Check evaluates the given gate on the given node. Returns nil if all its checks pass,
+// otherwise an error wrapping ErrUnhealthy, or the error met evaluating them.
+func (c *Checker) Check(ctx context.Context, gate workshopv1alpha2.HealthGate, nodeName string) error {
+	if gate.HTTPGet != nil {
+		if err := c.checkHTTPGet(ctx, *gate.HTTPGet, nodeName); err != nil {
+			return err
+		}
+	}
+	if gate.PodReadiness != nil {
+		if err := c.checkPodReadiness(ctx, *gate.PodReadiness, nodeName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Checker) checkHTTPGet(ctx context.Context, gate workshopv1alpha2.HTTPGetGate, nodeName string) error {
+	node := corev1.Node{}
+	if err := c.client.Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
+		return fmt.Errorf("failed to get node %q: %w", nodeName, err)
+	}
+	address, ok := nodeAddress(&node)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNoAddress, nodeName)
+	}
+	url := ProbeURL(gate, address)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: probe %s failed: %w", ErrUnhealthy, url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: probe %s returned %d", ErrUnhealthy, url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Checker) checkPodReadiness(ctx context.Context, gate workshopv1alpha2.PodReadinessGate, nodeName string) error {
+	selector, err := metav1.LabelSelectorAsSelector(&gate.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid pod selector: %w", err)
+	}
+	pods := corev1.PodList{}
+	if err := c.client.List(ctx, &pods, client.InNamespace(gate.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return fmt.Errorf("failed to list the pods: %w", err)
+	}
+	selected := 0
+	var notReady []string
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != nodeName || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		selected++
+		if !isPodReady(&pod) {
+			notReady = append(notReady, pod.Name)
+		}
+	}
+	if selected == 0 {
+		return fmt.Errorf("%w: no pods selected in namespace %q", ErrUnhealthy, gate.Namespace)
+	}
+	if len(notReady) > 0 {
+		return fmt.Errorf("%w: pods not ready in namespace %q: %s", ErrUnhealthy, gate.Namespace, strings.Join(notReady, ", "))
+	}
+	return nil
+}
+
+// ProbeURL returns the URL the given gate probes on the node with the given address.
+func ProbeURL(gate workshopv1alpha2.HTTPGetGate, address string) string {
+	scheme := "http"
+	if gate.Scheme != "" {
+		scheme = strings.ToLower(gate.Scheme)
+	}
+	path := gate.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(address, strconv.Itoa(int(gate.Port))), path)
+}
+
+// nodeAddress returns the address to probe the node: its internal IP, if known, otherwise its external IP.
+func nodeAddress(node *corev1.Node) (string, bool) {
+	for _, addrType := range []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeExternalIP} {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == addrType && addr.Address != "" {
+				return addr.Address, true
+			}
+		}
+	}
+	return "", false
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
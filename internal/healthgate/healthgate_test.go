@@ -0,0 +1,191 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthgate
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+)
+
+func TestCheckHTTPGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/healthz", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type testCase struct {
+		name        string
+		addresses   []v1.NodeAddress
+		path        string
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:      "healthy",
+			addresses: []v1.NodeAddress{{Type: v1.NodeHostName, Address: "node-a"}, {Type: v1.NodeInternalIP, Address: host}},
+			path:      "/healthz",
+		},
+		{
+			name:      "redirected",
+			addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: host}},
+			path:      "moved",
+		},
+		{
+			name:        "unhealthy",
+			addresses:   []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: host}},
+			path:        "/readyz",
+			expectedErr: ErrUnhealthy,
+		},
+		{
+			name:        "no address",
+			addresses:   []v1.NodeAddress{{Type: v1.NodeHostName, Address: "node-a"}},
+			path:        "/healthz",
+			expectedErr: ErrNoAddress,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+				Status:     v1.NodeStatus{Addresses: tcase.addresses},
+			}
+			checker := New(fake.NewClientBuilder().WithObjects(node).Build(), nil)
+			gate := workshopv1alpha2.HealthGate{
+				HTTPGet: &workshopv1alpha2.HTTPGetGate{Path: tcase.path, Port: int32(port)},
+			}
+			err := checker.Check(context.Background(), gate, "node-a")
+			if !errors.Is(err, tcase.expectedErr) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestCheckPodReadiness(t *testing.T) {
+	makePod := func(name, nodeName string, ready v1.ConditionStatus) client.Object {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "app",
+				Name:      name,
+				Labels:    map[string]string{"app": "web"},
+			},
+			Spec: v1.PodSpec{NodeName: nodeName},
+			Status: v1.PodStatus{
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: ready}},
+			},
+		}
+	}
+
+	type testCase struct {
+		name        string
+		pods        []client.Object
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name: "ready",
+			pods: []client.Object{
+				makePod("web-1", "node-a", v1.ConditionTrue),
+				// on other nodes, not considered
+				makePod("web-2", "node-b", v1.ConditionFalse),
+			},
+		},
+		{
+			name: "not ready",
+			pods: []client.Object{
+				makePod("web-1", "node-a", v1.ConditionTrue),
+				makePod("web-2", "node-a", v1.ConditionFalse),
+			},
+			expectedErr: ErrUnhealthy,
+		},
+		{
+			name: "no pods on the node",
+			pods: []client.Object{
+				makePod("web-2", "node-b", v1.ConditionTrue),
+			},
+			expectedErr: ErrUnhealthy,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			checker := New(fake.NewClientBuilder().WithObjects(tcase.pods...).Build(), nil)
+			gate := workshopv1alpha2.HealthGate{
+				PodReadiness: &workshopv1alpha2.PodReadinessGate{
+					Namespace: "app",
+					Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				},
+			}
+			err := checker.Check(context.Background(), gate, "node-a")
+			if !errors.Is(err, tcase.expectedErr) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestProbeURL(t *testing.T) {
+	type testCase struct {
+		gate     workshopv1alpha2.HTTPGetGate
+		address  string
+		expected string
+	}
+
+	testCases := []testCase{
+		{gate: workshopv1alpha2.HTTPGetGate{Port: 8080}, address: "10.0.0.1", expected: "http://10.0.0.1:8080/"},
+		{gate: workshopv1alpha2.HTTPGetGate{Path: "healthz", Port: 8443, Scheme: "HTTPS"}, address: "10.0.0.1", expected: "https://10.0.0.1:8443/healthz"},
+		{gate: workshopv1alpha2.HTTPGetGate{Path: "/healthz", Port: 80}, address: "fd00::1", expected: "http://[fd00::1]:80/healthz"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.expected, func(t *testing.T) {
+			if got := ProbeURL(tcase.gate, tcase.address); got != tcase.expected {
+				t.Fatalf("unexpected URL got=%q expected=%q", got, tcase.expected)
+			}
+		})
+	}
+}
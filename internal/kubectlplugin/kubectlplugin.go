@@ -0,0 +1,214 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kubectlplugin implements the commands of the kubectl-dredger plugin.
+// The commands only render the objects they are given, so they can be tested
+// without an API server; fetching the objects is up to the caller.
+package kubectlplugin
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/contenthash"
+	"golab.io/kubedredger/internal/textdiff"
+)
+
+const (
+	// NodeStateUpToDate is reported when the node has the desired content
+	NodeStateUpToDate = "UpToDate"
+	// NodeStateStale is reported when the node has a different content
+	NodeStateStale = "Stale"
+	// NodeStateUnknown is reported when the node does not report the content
+	NodeStateUnknown = "Unknown"
+)
+
+const noneValue = "<none>"
+
+// FileName returns the name of the file of the given Configuration relative to the root, like the agents compute it.
+func FileName(conf *workshopv1alpha2.Configuration) string {
+	return configfile.NamespacedFilename(conf.Namespace, conf.Spec.Filename)
+}
+
+// NodeState returns the state of the file of the given Configuration on the given node,
+// according to the content hash the agent on the node reports in the status.
+func NodeState(conf *workshopv1alpha2.Configuration, nodeName string) string {
+	node, ok := findNodeStatus(conf, nodeName)
+	if !ok || node.ContentHash == "" {
+		return NodeStateUnknown
+	}
+	if node.ContentHash != contenthash.Sum([]byte(conf.Spec.Content)) {
+		return NodeStateStale
+	}
+	return NodeStateUpToDate
+}
+
+// WriteList writes the table of the given Configurations, with their state on each of the given nodes.
+func WriteList(w io.Writer, confs []workshopv1alpha2.Configuration, nodes []corev1.Node) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tFILENAME\tAVAILABLE\tNODE\tSTATE")
+	for idx := range confs {
+		conf := &confs[idx]
+		available := conditionStatus(conf, workshopv1alpha2.ConditionAvailable)
+		if len(nodes) == 0 {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", conf.Namespace, conf.Name, conf.Spec.Filename, available, noneValue, NodeStateUnknown)
+			continue
+		}
+		for nidx := range nodes {
+			node := &nodes[nidx]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", conf.Namespace, conf.Name, conf.Spec.Filename, available, node.Name, NodeState(conf, node.Name))
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteDiff writes the differences between the desired content of the given Configuration and the content
+// reported in its status, followed by the nodes not reporting the desired content.
+// Writes nothing if the file is up to date everywhere.
+func WriteDiff(w io.Writer, conf *workshopv1alpha2.Configuration, nodes []corev1.Node) error {
+	desired := conf.Spec.Content
+	if conf.Status.ContentHash != contenthash.Sum([]byte(desired)) {
+		if _, err := io.WriteString(w, statusDiff(conf)); err != nil {
+			return err
+		}
+	}
+	for idx := range nodes {
+		node := &nodes[idx]
+		if state := NodeState(conf, node.Name); state != NodeStateUpToDate {
+			if _, err := fmt.Fprintf(w, "node %s: %s\n", node.Name, state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func statusDiff(conf *workshopv1alpha2.Configuration) string {
+	fileName := FileName(conf)
+	if !conf.Status.FileExists && slices.ContainsFunc(conf.Status.Nodes, func(node workshopv1alpha2.NodeStatus) bool {
+		return node.ContentHash != ""
+	}) {
+		// the status reports the file only while it is the same on all the nodes
+		return fmt.Sprintf("%s: content differs across the nodes\n", fileName)
+	}
+	if !conf.Status.FileExists {
+		return textdiff.Unified(fileName+" (missing)", fileName+" (desired)", "", conf.Spec.Content)
+	}
+	reported := conf.Status.ContentPreview
+	if reported == "" && conf.Status.Size > 0 {
+		return fmt.Sprintf("%s: content differs from the desired one, but the status reports no preview of it\n", fileName)
+	}
+	desired := conf.Spec.Content
+	fromName, toName := fileName+" (reported)", fileName+" (desired)"
+	if int64(len(reported)) < conf.Status.Size {
+		// the status reports only the beginning of the file, so we can only compare that
+		desired = desired[:min(len(desired), len(reported))]
+		fromName = fmt.Sprintf("%s (reported, first %d of %d bytes)", fileName, len(reported), conf.Status.Size)
+		toName = fmt.Sprintf("%s (desired, first %d bytes)", fileName, len(desired))
+	}
+	diff := textdiff.Unified(fromName, toName, reported, desired)
+	if diff == "" {
+		return fmt.Sprintf("%s: content differs from the desired one after the first %d bytes\n", fileName, len(reported))
+	}
+	return diff
+}
+
+// WriteHistory writes the history of the given Configuration: its generations, the revision
+// each node reports in the status and the given events about it, oldest first, which tell what
+// each agent did to the file. The agents keep the previous versions of the file only in memory,
+// so the revisions the nodes went through before the current one are known only from the events.
+func WriteHistory(w io.Writer, conf *workshopv1alpha2.Configuration, events []corev1.Event) error {
+	fmt.Fprintf(w, "generation %d, observed generation %d\n", conf.Generation, conf.Status.ObservedGeneration)
+	if rollout := conf.Status.Rollout; rollout != nil {
+		fmt.Fprintf(w, "rollout revision %s, phase %s\n", rollout.Revision, rollout.Phase)
+	}
+	if err := writeNodeRevisions(w, conf.Status.Nodes); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	sorted := slices.Clone(events)
+	slices.SortStableFunc(sorted, func(a, b corev1.Event) int {
+		return cmp.Compare(eventTime(&a).UnixNano(), eventTime(&b).UnixNano())
+	})
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTYPE\tREASON\tCOUNT\tMESSAGE")
+	for idx := range sorted {
+		ev := &sorted[idx]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", eventTime(ev).Format(time.RFC3339), ev.Type, ev.Reason, max(ev.Count, 1), ev.Message)
+	}
+	return tw.Flush()
+}
+
+func writeNodeRevisions(w io.Writer, nodes []workshopv1alpha2.NodeStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tREVISION\tGENERATION\tCONTENT HASH\tERROR")
+	for idx := range nodes {
+		node := &nodes[idx]
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", node.Name, cmp.Or(node.Revision, noneValue), node.ObservedGeneration, cmp.Or(node.ContentHash, noneValue), cmp.Or(node.Error, noneValue))
+	}
+	return tw.Flush()
+}
+
+// EventsSelector returns the field selector matching the events about the object with the given UID.
+func EventsSelector(uid types.UID) client.MatchingFields {
+	return client.MatchingFields{"involvedObject.uid": string(uid)}
+}
+
+// ResyncPatch returns the patch requesting the agents to sync the file again, at the given time.
+func ResyncPatch(now time.Time) client.Patch {
+	data := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, workshopv1alpha2.ResyncAnnotation, now.UTC().Format(time.RFC3339))
+	return client.RawPatch(types.MergePatchType, []byte(data))
+}
+
+func eventTime(ev *corev1.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	default:
+		return ev.FirstTimestamp.Time
+	}
+}
+
+func findNodeStatus(conf *workshopv1alpha2.Configuration, nodeName string) (workshopv1alpha2.NodeStatus, bool) {
+	idx := slices.IndexFunc(conf.Status.Nodes, func(node workshopv1alpha2.NodeStatus) bool {
+		return node.Name == nodeName
+	})
+	if idx < 0 {
+		return workshopv1alpha2.NodeStatus{}, false
+	}
+	return conf.Status.Nodes[idx], true
+}
+
+func conditionStatus(conf *workshopv1alpha2.Configuration, condType string) string {
+	if cond := meta.FindStatusCondition(conf.Status.Conditions, condType); cond != nil {
+		return string(cond.Status)
+	}
+	return string(metav1.ConditionUnknown)
+}
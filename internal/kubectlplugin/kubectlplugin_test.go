@@ -0,0 +1,237 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectlplugin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/contenthash"
+)
+
+const (
+	desiredContent = "foo=1\nbar=2\n"
+)
+
+func TestNodeState(t *testing.T) {
+	type testCase struct {
+		name     string
+		nodes    []workshopv1alpha2.NodeStatus
+		expected string
+	}
+
+	testCases := []testCase{
+		{name: "not reported", expected: NodeStateUnknown},
+		{name: "other node", nodes: []workshopv1alpha2.NodeStatus{{Name: "node-2", ContentHash: contenthash.Sum([]byte(desiredContent))}}, expected: NodeStateUnknown},
+		{name: "no file", nodes: []workshopv1alpha2.NodeStatus{{Name: "node-1", Error: "failed"}}, expected: NodeStateUnknown},
+		{name: "up to date", nodes: []workshopv1alpha2.NodeStatus{{Name: "node-1", ContentHash: contenthash.Sum([]byte(desiredContent))}}, expected: NodeStateUpToDate},
+		{name: "stale", nodes: []workshopv1alpha2.NodeStatus{{Name: "node-1", ContentHash: contenthash.Sum([]byte("foo=0\n"))}}, expected: NodeStateStale},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			conf := makeConfiguration(desiredContent)
+			conf.Status.Nodes = tcase.nodes
+			if got := NodeState(conf, "node-1"); got != tcase.expected {
+				t.Fatalf("unexpected state got=%q expected=%q", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	conf := makeConfiguration(desiredContent)
+	conf.Status.Conditions = []metav1.Condition{{Type: "Available", Status: metav1.ConditionTrue}}
+	conf.Status.Nodes = []workshopv1alpha2.NodeStatus{{Name: "node-1", ContentHash: contenthash.Sum([]byte(desiredContent))}}
+	nodes := []corev1.Node{makeNode("node-1"), makeNode("node-2")}
+
+	var buf bytes.Buffer
+	if err := WriteList(&buf, []workshopv1alpha2.Configuration{*conf}, nodes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `NAMESPACE  NAME   FILENAME    AVAILABLE  NODE    STATE
+team-a     golab  golab.conf  True       node-1  UpToDate
+team-a     golab  golab.conf  True       node-2  Unknown
+`
+	if diff := cmp.Diff(buf.String(), expected); diff != "" {
+		t.Fatalf("unexpected output (-got +expected):\n%s", diff)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	type testCase struct {
+		name     string
+		status   workshopv1alpha2.ConfigurationStatus
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			name: "up to date",
+			status: workshopv1alpha2.ConfigurationStatus{
+				FileExists:     true,
+				ContentHash:    contenthash.Sum([]byte(desiredContent)),
+				Size:           int64(len(desiredContent)),
+				ContentPreview: desiredContent,
+			},
+		},
+		{
+			name: "missing",
+			expected: "--- namespaces/team-a/golab.conf (missing)\n+++ namespaces/team-a/golab.conf (desired)\n" +
+				"@@ -0,0 +1,2 @@\n+foo=1\n+bar=2\n",
+		},
+		{
+			name: "changed",
+			status: workshopv1alpha2.ConfigurationStatus{
+				FileExists:     true,
+				ContentHash:    contenthash.Sum([]byte("foo=1\nbar=3\n")),
+				Size:           12,
+				ContentPreview: "foo=1\nbar=3\n",
+			},
+			expected: "--- namespaces/team-a/golab.conf (reported)\n+++ namespaces/team-a/golab.conf (desired)\n" +
+				"@@ -1,2 +1,2 @@\n foo=1\n-bar=3\n+bar=2\n",
+		},
+		{
+			name: "changed after the preview",
+			status: workshopv1alpha2.ConfigurationStatus{
+				FileExists:     true,
+				ContentHash:    contenthash.Sum([]byte("foo=1\nbar=3\n")),
+				Size:           12,
+				ContentPreview: "foo=1\n",
+			},
+			expected: "namespaces/team-a/golab.conf: content differs from the desired one after the first 6 bytes\n",
+		},
+		{
+			name: "no preview",
+			status: workshopv1alpha2.ConfigurationStatus{
+				FileExists:  true,
+				ContentHash: contenthash.Sum([]byte("foo=1\nbar=3\n")),
+				Size:        12,
+			},
+			expected: "namespaces/team-a/golab.conf: content differs from the desired one, but the status reports no preview of it\n",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			conf := makeConfiguration(desiredContent)
+			conf.Status = tcase.status
+			var buf bytes.Buffer
+			if err := WriteDiff(&buf, conf, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(buf.String(), tcase.expected); diff != "" {
+				t.Fatalf("unexpected output (-got +expected):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteDiffNodes(t *testing.T) {
+	conf := makeConfiguration(desiredContent)
+	conf.Status.Nodes = []workshopv1alpha2.NodeStatus{
+		{Name: "node-1", ContentHash: contenthash.Sum([]byte(desiredContent))},
+		{Name: "node-2", ContentHash: contenthash.Sum([]byte("foo=0\n"))},
+	}
+	nodes := []corev1.Node{makeNode("node-1"), makeNode("node-2")}
+
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, conf, nodes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); got != "namespaces/team-a/golab.conf: content differs across the nodes\nnode node-2: Stale\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	conf := makeConfiguration(desiredContent)
+	conf.Generation = 3
+	conf.Status.ObservedGeneration = 2
+	conf.Status.Rollout = &workshopv1alpha2.RolloutStatus{Revision: "rev-3", Phase: workshopv1alpha2.RolloutPhaseProgressing}
+	conf.Status.Nodes = []workshopv1alpha2.NodeStatus{
+		{Name: "node-1", ObservedGeneration: 2, Revision: "rev-2", ContentHash: "hash-2", Error: "disk full"},
+		{Name: "node-2"},
+	}
+	ts := time.Date(2025, time.October, 4, 10, 0, 0, 0, time.UTC)
+	events := []corev1.Event{
+		{Type: corev1.EventTypeNormal, Reason: "Updated", Message: "updated", Count: 2, LastTimestamp: metav1.NewTime(ts.Add(time.Minute))},
+		{Type: corev1.EventTypeNormal, Reason: "Created", Message: "created", FirstTimestamp: metav1.NewTime(ts)},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHistory(&buf, conf, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `generation 3, observed generation 2
+rollout revision rev-3, phase Progressing
+NODE    REVISION  GENERATION  CONTENT HASH  ERROR
+node-1  rev-2     2           hash-2        disk full
+node-2  <none>    0           <none>        <none>
+
+TIME                  TYPE    REASON   COUNT  MESSAGE
+2025-10-04T10:00:00Z  Normal  Created  1      created
+2025-10-04T10:01:00Z  Normal  Updated  2      updated
+`
+	if diff := cmp.Diff(buf.String(), expected); diff != "" {
+		t.Fatalf("unexpected output (-got +expected):\n%s", diff)
+	}
+}
+
+func TestResyncPatch(t *testing.T) {
+	patch := ResyncPatch(time.Date(2025, time.October, 4, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+	data, err := patch.Data(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"metadata":{"annotations":{"workshop.golab.io/resync-requested-at":"2025-10-04T10:00:00Z"}}}`
+	if string(data) != expected {
+		t.Fatalf("unexpected patch: %s", data)
+	}
+	if !strings.Contains(string(patch.Type()), "merge-patch") {
+		t.Fatalf("unexpected patch type: %s", patch.Type())
+	}
+}
+
+func makeConfiguration(content string) *workshopv1alpha2.Configuration {
+	return &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Name:      "golab",
+		},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename: "golab.conf",
+			Content:  content,
+			Create:   true,
+		},
+	}
+}
+
+func makeNode(name string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+}
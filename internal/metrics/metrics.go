@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the prometheus metrics exposed by kubedredger.
+// The metrics are registered in the controller-runtime registry, so they
+// are served by the manager metrics endpoint alongside the builtin ones.
+// All the metric names share the Namespace prefix.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// Namespace is the common prefix of all the kubedredger metrics
+	Namespace = "kubedredger"
+)
+
+const (
+	ResultSuccess        = "success"
+	ResultError          = "error"
+	ResultNonRecoverable = "nonrecoverable"
+)
+
+var (
+	// SyncTotal counts the sync attempts done by the reconciler, by result
+	SyncTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "sync_total",
+		Help:      "Number of configuration sync attempts, by result.",
+	}, []string{"result"})
+
+	// SyncDuration observes how long the sync attempts done by the reconciler take, by result
+	SyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "sync_duration_seconds",
+		Help:      "Duration of the configuration sync attempts, by result.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"result"})
+
+	// WriteDuration observes how long writing a file on storage takes
+	WriteDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "file_write_duration_seconds",
+		Help:      "Duration of the configuration file writes, including locking.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	// ManagedFiles is the number of configuration files currently managed
+	ManagedFiles = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "managed_files",
+		Help:      "Number of configuration files currently managed.",
+	})
+
+	// DriftCorrectionsTotal counts the times a file was found modified behind our back and restored
+	DriftCorrectionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "drift_corrections_total",
+		Help:      "Number of configuration files restored after being modified externally.",
+	})
+
+	// LastSuccessfulSync is the unix timestamp of the last successful sync, by file
+	LastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful sync of a configuration file.",
+	}, []string{"filename"})
+
+	// BytesWrittenTotal counts the bytes written on storage
+	BytesWrittenTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "bytes_written_total",
+		Help:      "Number of bytes of configuration content written.",
+	})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		SyncTotal,
+		SyncDuration,
+		WriteDuration,
+		ManagedFiles,
+		DriftCorrectionsTotal,
+		LastSuccessfulSync,
+		BytesWrittenTotal,
+	)
+}
+
+// ObserveSync records the outcome of a sync attempt started at the given time.
+func ObserveSync(result string, started time.Time) {
+	SyncTotal.WithLabelValues(result).Inc()
+	SyncDuration.WithLabelValues(result).Observe(time.Since(started).Seconds())
+}
+
+// ObserveWrite records a successful write of the given amount of bytes started at the given time.
+func ObserveWrite(fileName string, size int, started time.Time) {
+	WriteDuration.Observe(time.Since(started).Seconds())
+	BytesWrittenTotal.Add(float64(size))
+	MarkSynced(fileName)
+}
+
+// MarkSynced records the given file is in sync, whether or not it was written.
+func MarkSynced(fileName string) {
+	LastSuccessfulSync.WithLabelValues(fileName).SetToCurrentTime()
+}
+
+// ForgetFile drops the per-file metrics of a file which is no longer managed.
+func ForgetFile(fileName string) {
+	LastSuccessfulSync.DeleteLabelValues(fileName)
+}
@@ -19,16 +19,34 @@ package nodelabel
 import (
 	"context"
 	"errors"
+	"slices"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"golab.io/kubedredger/internal/contenthash"
 )
 
 const (
 	// ContentHashV1 represent the hash value of the configuration value
 	// You can only compare this value for equality.
 	ContentHashV1 = "contenthashv1.workshop.golab.io"
+
+	// FieldManager is the field manager of the changes the Manager makes to the node
+	FieldManager = "kubedredger-nodelabel"
+)
+
+const (
+	// labelNameMaxLength is the maximum length of the name part of a label key
+	labelNameMaxLength = 63
+	// hashSuffixLength is the length of the suffix added to the file names which
+	// are not valid label names, enough to make collisions very unlikely.
+	hashSuffixLength = 10
 )
 
 var (
@@ -36,14 +54,64 @@ var (
 	ErrUnknownKey = errors.New("unsupported key")
 )
 
-// Manager handles the configuration labels on Kubernetes node objects
+// Manager handles the configuration labels, and the readiness taints and conditions,
+// on Kubernetes node objects. All the changes are sent as patches owned by FieldManager.
 type Manager struct {
 	nodeName string
 	cli      client.Client
+	reader   client.Reader
 }
 
+// MakeContentHashLabel returns the key of the content hash label of the given file.
+// The file names which are valid label names are used as they are. The other ones,
+// like the ones including directories or longer than 63 characters, are encoded
+// deterministically: the characters not allowed are replaced by underscores, and
+// the last characters are kept, followed by a hash of the whole name. The Manager
+// stores the file names encoded in the node annotation with the same key as the label.
 func MakeContentHashLabel(fileName string) string {
-	return ContentHashV1 + "/" + fileName
+	return ContentHashV1 + "/" + labelName(fileName)
+}
+
+// ContentHashFileName returns the name of the file the given content hash label key of the
+// given node refers to, and true; or false if the key is not a content hash label key.
+// Without the annotation, the file name is the name in the key, as for the file names
+// which are valid label names.
+func ContentHashFileName(node *v1.Node, key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, ContentHashV1+"/")
+	if !ok || name == "" {
+		return "", false
+	}
+	if fileName, ok := node.Annotations[key]; ok {
+		return fileName, true
+	}
+	return name, true
+}
+
+// labelName returns the given file name if it is a valid label name, or its encoding otherwise.
+func labelName(fileName string) string {
+	if !strings.Contains(fileName, "/") && len(validation.IsQualifiedName(fileName)) == 0 {
+		return fileName
+	}
+	suffix := contenthash.Sum([]byte(fileName))[:hashSuffixLength]
+	sanitized := []rune(strings.Map(func(r rune) rune {
+		if isAlphanumeric(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, fileName))
+	// keep the end of the name, the most specific part of the path
+	sanitized = sanitized[max(0, len(sanitized)-(labelNameMaxLength-hashSuffixLength-1)):]
+	prefix := strings.TrimLeftFunc(string(sanitized), func(r rune) bool {
+		return !isAlphanumeric(r)
+	})
+	if prefix == "" {
+		return suffix
+	}
+	return prefix + "-" + suffix
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
 }
 
 // IsValidKey returns true if the given key can be handled by the Manager, false otherwise
@@ -53,28 +121,88 @@ func IsValidKey(key string) bool {
 
 // NewManager creates a new manager instance for the given node.
 func NewManager(nodeName string, cli client.Client) *Manager {
+	return NewManagerWithReader(nodeName, cli, cli)
+}
+
+// NewManagerWithReader creates a new manager instance for the given node, which reads
+// the node with the given reader. Reading the node from the API server, instead of
+// from the cache, avoids the conflicts patching a stale resource version.
+func NewManagerWithReader(nodeName string, cli client.Client, reader client.Reader) *Manager {
 	return &Manager{
 		nodeName: nodeName,
 		cli:      cli,
+		reader:   reader,
 	}
 }
 
+// ContentHashLabels returns the content hash label of the given file with the given hash,
+// computed with the contenthash package, and the annotation with the file name if it is
+// encoded in the label key; both ready to be passed to Apply.
+func ContentHashLabels(fileName, contentHash string) (map[string]string, map[string]string) {
+	key := MakeContentHashLabel(fileName)
+	labels := map[string]string{key: contentHash}
+	if labelName(fileName) == fileName {
+		return labels, nil
+	}
+	return labels, map[string]string{key: fileName}
+}
+
 // Set adds the given key with the given label to the labels of the node
 // handled by this Manager.
 func (mgr *Manager) Set(ctx context.Context, key, value string) error {
-	if !IsValidKey(key) {
-		return ErrUnknownKey
+	return mgr.Apply(ctx, map[string]string{key: value}, nil, nil)
+}
+
+// Apply changes the labels of the node handled by this Manager in a single patch:
+// the keys in toClear are removed, then the ones in toSet are added or replaced,
+// along with the annotations describing them, keyed like the labels.
+// All the keys in toSet and annotations must be valid, otherwise the node is not changed at all.
+// The node is not patched if its labels and annotations already match.
+func (mgr *Manager) Apply(ctx context.Context, toSet, annotations map[string]string, toClear []string) error {
+	for key := range toSet {
+		if !IsValidKey(key) {
+			return ErrUnknownKey
+		}
 	}
-	node := v1.Node{}
-	err := mgr.cli.Get(ctx, client.ObjectKey{Name: mgr.nodeName}, &node)
-	if err != nil {
-		return err
+	for key := range annotations {
+		if !IsValidKey(key) {
+			return ErrUnknownKey
+		}
 	}
-	if node.Labels == nil {
-		node.Labels = make(map[string]string)
+	return mgr.patch(ctx, func(node *v1.Node) bool {
+		changed := false
+		for _, key := range toClear {
+			if _, ok := node.Labels[key]; ok {
+				delete(node.Labels, key)
+				changed = true
+			}
+			// the file name the label refers to, if encoded
+			if _, ok := node.Annotations[key]; ok && IsValidKey(key) {
+				delete(node.Annotations, key)
+				changed = true
+			}
+		}
+		for key, value := range toSet {
+			changed = setMapValue(&node.Labels, key, value) || changed
+		}
+		for key, value := range annotations {
+			changed = setMapValue(&node.Annotations, key, value) || changed
+		}
+		return changed
+	})
+}
+
+// setMapValue sets the given key of the given map, creating the map if needed.
+// Returns false if the map already had the value.
+func setMapValue(values *map[string]string, key, value string) bool {
+	if cur, ok := (*values)[key]; ok && cur == value {
+		return false
+	}
+	if *values == nil {
+		*values = make(map[string]string)
 	}
-	node.Labels[key] = value
-	return mgr.cli.Update(ctx, &node)
+	(*values)[key] = value
+	return true
 }
 
 // Get retrieves the value for the given key among the labels of the node
@@ -84,7 +212,7 @@ func (mgr *Manager) Set(ctx context.Context, key, value string) error {
 // and all the other returned values have no meaning.
 func (mgr *Manager) Get(ctx context.Context, key string) (string, bool, error) {
 	node := v1.Node{}
-	err := mgr.cli.Get(ctx, client.ObjectKey{Name: mgr.nodeName}, &node)
+	err := mgr.reader.Get(ctx, client.ObjectKey{Name: mgr.nodeName}, &node)
 	if err != nil {
 		return "", false, err
 	}
@@ -95,15 +223,104 @@ func (mgr *Manager) Get(ctx context.Context, key string) (string, bool, error) {
 	return value, ok, nil
 }
 
+// Clear removes the given key from the labels of the node handled by this Manager.
 func (mgr *Manager) Clear(ctx context.Context, key string) error {
-	node := v1.Node{}
-	err := mgr.cli.Get(ctx, client.ObjectKey{Name: mgr.nodeName}, &node)
-	if err != nil {
-		return err
-	}
-	if node.Labels == nil {
-		return nil // nothing to do
-	}
-	delete(node.Labels, key)
-	return mgr.cli.Update(ctx, &node)
+	return mgr.Apply(ctx, nil, nil, []string{key})
+}
+
+// SetTaint adds the given taint to the node handled by this Manager, replacing the taint
+// with the same key and effect, if any. The node is not updated if the taint is already set.
+func (mgr *Manager) SetTaint(ctx context.Context, taint v1.Taint) error {
+	return mgr.patch(ctx, func(node *v1.Node) bool {
+		idx := slices.IndexFunc(node.Spec.Taints, func(cur v1.Taint) bool {
+			return cur.MatchTaint(&taint)
+		})
+		if idx >= 0 {
+			if node.Spec.Taints[idx].Value == taint.Value {
+				return false
+			}
+			node.Spec.Taints[idx].Value = taint.Value
+			return true
+		}
+		added := taint
+		if added.Effect == v1.TaintEffectNoExecute && added.TimeAdded == nil {
+			added.TimeAdded = ptr.To(metav1.Now())
+		}
+		node.Spec.Taints = append(node.Spec.Taints, added)
+		return true
+	})
+}
+
+// ClearTaint removes the taints with the given key, regardless of their effect,
+// from the node handled by this Manager.
+func (mgr *Manager) ClearTaint(ctx context.Context, key string) error {
+	return mgr.patch(ctx, func(node *v1.Node) bool {
+		taints := slices.DeleteFunc(slices.Clone(node.Spec.Taints), func(taint v1.Taint) bool {
+			return taint.Key == key
+		})
+		if len(taints) == len(node.Spec.Taints) {
+			return false
+		}
+		node.Spec.Taints = taints
+		return true
+	})
+}
+
+// SetCondition adds or replaces the given condition in the status of the node handled by this Manager.
+// The transition time changes only when the status of the condition does, and the node is not updated
+// if the condition is already set.
+func (mgr *Manager) SetCondition(ctx context.Context, cond v1.NodeCondition) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node := v1.Node{}
+		err := mgr.reader.Get(ctx, client.ObjectKey{Name: mgr.nodeName}, &node)
+		if err != nil {
+			return err
+		}
+		orig := node.DeepCopy()
+		now := metav1.Now()
+		updated := cond
+		updated.LastHeartbeatTime = now
+		updated.LastTransitionTime = now
+		idx := slices.IndexFunc(node.Status.Conditions, func(cur v1.NodeCondition) bool {
+			return cur.Type == cond.Type
+		})
+		if idx < 0 {
+			node.Status.Conditions = append(node.Status.Conditions, updated)
+		} else {
+			cur := node.Status.Conditions[idx]
+			if cur.Status == cond.Status && cur.Reason == cond.Reason && cur.Message == cond.Message {
+				return nil // nothing to do
+			}
+			if cur.Status == cond.Status {
+				updated.LastTransitionTime = cur.LastTransitionTime
+			}
+			node.Status.Conditions[idx] = updated
+		}
+		return mgr.cli.Status().Patch(ctx, &node, mergeFrom(orig), client.FieldOwner(FieldManager))
+	})
+}
+
+// patch changes the node handled by this Manager with the given mutation, which returns false
+// if the node needs no change. The change is sent as a merge patch, so only the changed fields
+// are sent, guarded by the resource version of the node it was computed from: if the node changed
+// in the meantime, like on kubelet heartbeats, the node is read again and the mutation applied again.
+func (mgr *Manager) patch(ctx context.Context, mutate func(node *v1.Node) bool) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node := v1.Node{}
+		err := mgr.reader.Get(ctx, client.ObjectKey{Name: mgr.nodeName}, &node)
+		if err != nil {
+			return err
+		}
+		orig := node.DeepCopy()
+		if !mutate(&node) {
+			return nil // nothing to do
+		}
+		return mgr.cli.Patch(ctx, &node, mergeFrom(orig), client.FieldOwner(FieldManager))
+	})
+}
+
+// mergeFrom returns a merge patch from the given node which fails with a conflict
+// if the node changed after it was read.
+func mergeFrom(orig *v1.Node) client.Patch {
+	return client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})
 }
@@ -18,19 +18,79 @@ package nodelabel
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"maps"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	"golab.io/kubedredger/internal/contenthash"
 )
 
+func TestMakeContentHashLabel(t *testing.T) {
+	type testCase struct {
+		fileName      string
+		expectedName  string
+		expectEncoded bool
+	}
+
+	testCases := []testCase{
+		{fileName: "workshop.conf", expectedName: "workshop.conf"},
+		{fileName: "10-net_v2.conf", expectedName: "10-net_v2.conf"},
+		{fileName: strings.Repeat("a", 63), expectedName: strings.Repeat("a", 63)},
+		{fileName: "app/conf.d/10-net.conf", expectEncoded: true},
+		{fileName: "ns/workshop.conf", expectEncoded: true},
+		{fileName: strings.Repeat("a", 64), expectEncoded: true},
+		{fileName: "conf.d/" + strings.Repeat("long-name-", 20) + ".conf", expectEncoded: true},
+		{fileName: ".hidden", expectEncoded: true},
+		{fileName: "trailing.", expectEncoded: true},
+		{fileName: "with spaces and ünicode.conf", expectEncoded: true},
+		{fileName: "/.../___", expectEncoded: true},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.fileName, func(t *testing.T) {
+			key := MakeContentHashLabel(tcase.fileName)
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				t.Fatalf("invalid label key %q: %v", key, errs)
+			}
+			if !IsValidKey(key) {
+				t.Fatalf("unsupported label key %q", key)
+			}
+			if again := MakeContentHashLabel(tcase.fileName); again != key {
+				t.Fatalf("label key not deterministic: %q then %q", key, again)
+			}
+			name := strings.TrimPrefix(key, ContentHashV1+"/")
+			if tcase.expectEncoded {
+				suffix := contenthash.Sum([]byte(tcase.fileName))[:hashSuffixLength]
+				if !strings.HasSuffix(name, suffix) {
+					t.Fatalf("label name %q missing the hash suffix %q", name, suffix)
+				}
+			} else if name != tcase.expectedName {
+				t.Fatalf("unexpected label name got=%q expected=%q", name, tcase.expectedName)
+			}
+		})
+	}
+
+	// the same file name, sanitized, must not collide
+	if MakeContentHashLabel("a/b.conf") == MakeContentHashLabel("a_b.conf") {
+		t.Fatalf("colliding label keys for sanitized file names")
+	}
+	if MakeContentHashLabel("x/"+strings.Repeat("a", 80)) == MakeContentHashLabel("y/"+strings.Repeat("a", 80)) {
+		t.Fatalf("colliding label keys for truncated file names")
+	}
+}
+
 func TestManagerGet(t *testing.T) {
 	type testCase struct {
 		name            string
@@ -228,6 +288,82 @@ func TestManagerSet(t *testing.T) {
 	}
 }
 
+func TestManagerContentHashLabels(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+		},
+	}
+	content := []byte("[main]\nfoo=bar\n")
+
+	cli := newFakeClient(node)
+	mgr := NewManager(node.Name, cli)
+	labels, annotations := ContentHashLabels("workshop.conf", contenthash.Sum(content))
+	err := mgr.Apply(context.TODO(), labels, annotations, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, ok, err := mgr.Get(context.TODO(), MakeContentHashLabel("workshop.conf"))
+	if err != nil || !ok {
+		t.Fatalf("cannot get the content hash label: ok=%v err=%v", ok, err)
+	}
+	if expected := contenthash.Sum(content); value != expected {
+		t.Fatalf("content hash mismatch: expected %q got %q", expected, value)
+	}
+}
+
+func TestManagerContentHashFileName(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+		},
+	}
+
+	cli := newFakeClient(node)
+	mgr := NewManager(node.Name, cli)
+	fileNames := []string{"workshop.conf", "app/conf.d/10-net.conf"}
+	for _, fileName := range fileNames {
+		labels, annotations := ContentHashLabels(fileName, contenthash.Sum([]byte("foo=bar\n")))
+		if err := mgr.Apply(context.TODO(), labels, annotations, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var updatedNode v1.Node
+	if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+		t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+	}
+	for _, fileName := range fileNames {
+		key := MakeContentHashLabel(fileName)
+		if _, ok := updatedNode.Labels[key]; !ok {
+			t.Fatalf("missing label %q", key)
+		}
+		got, ok := ContentHashFileName(&updatedNode, key)
+		if !ok || got != fileName {
+			t.Fatalf("unexpected file name for %q got=%q ok=%v expected=%q", key, got, ok, fileName)
+		}
+	}
+	if len(updatedNode.Annotations) != 1 {
+		t.Fatalf("unexpected annotations, expected only the encoded file name: %v", updatedNode.Annotations)
+	}
+	if _, ok := ContentHashFileName(&updatedNode, "foo"); ok {
+		t.Fatalf("unexpected file name for a foreign key")
+	}
+
+	encoded := MakeContentHashLabel(fileNames[1])
+	if err := mgr.Clear(context.TODO(), encoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+		t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+	}
+	if _, ok := updatedNode.Labels[encoded]; ok {
+		t.Fatalf("label %q not removed", encoded)
+	}
+	if _, ok := updatedNode.Annotations[encoded]; ok {
+		t.Fatalf("annotation %q not removed", encoded)
+	}
+}
+
 func TestManagerClear(t *testing.T) {
 	type testCase struct {
 		name       string
@@ -330,6 +466,251 @@ func TestManagerClear(t *testing.T) {
 	}
 }
 
+func TestManagerApply(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+			Labels: map[string]string{
+				MakeContentHashLabel("old.conf"): "old-hash",
+				"foo":                            "quux",
+			},
+		},
+		Spec: v1.NodeSpec{
+			PodCIDR: "10.0.0.0/24",
+		},
+	}
+
+	var patches []map[string]any
+	var owners []string
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(node).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, cli client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			data, err := patch.Data(obj)
+			if err != nil {
+				return err
+			}
+			var patchMap map[string]any
+			if err := json.Unmarshal(data, &patchMap); err != nil {
+				return err
+			}
+			patches = append(patches, patchMap)
+			patchOpts := client.PatchOptions{}
+			patchOpts.ApplyOptions(opts)
+			owners = append(owners, patchOpts.FieldManager)
+			return cli.Patch(ctx, obj, patch, opts...)
+		},
+	}).Build()
+	mgr := NewManager(node.Name, cli)
+
+	toSet := map[string]string{
+		MakeContentHashLabel("a.conf"): "hash-a",
+		MakeContentHashLabel("b.conf"): "hash-b",
+	}
+	toClear := []string{MakeContentHashLabel("old.conf"), MakeContentHashLabel("missing.conf")}
+	for range 2 {
+		// applying twice is idempotent
+		if err := mgr.Apply(context.TODO(), toSet, nil, toClear); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(patches) != 1 {
+		t.Fatalf("unexpected patches: %v", patches)
+	}
+	if _, ok := patches[0]["spec"]; ok {
+		t.Fatalf("unexpected spec in patch: %v", patches[0])
+	}
+	metadata, _ := patches[0]["metadata"].(map[string]any)
+	if _, ok := metadata["resourceVersion"]; !ok {
+		t.Fatalf("missing resource version in patch: %v", patches[0])
+	}
+	if owners[0] != FieldManager {
+		t.Fatalf("unexpected field manager got=%q expected=%q", owners[0], FieldManager)
+	}
+
+	var updatedNode v1.Node
+	if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+		t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+	}
+	expected := map[string]string{
+		MakeContentHashLabel("a.conf"): "hash-a",
+		MakeContentHashLabel("b.conf"): "hash-b",
+		"foo":                          "quux",
+	}
+	if !maps.Equal(updatedNode.Labels, expected) {
+		t.Fatalf("unexpected labels got=%v expected=%v", updatedNode.Labels, expected)
+	}
+
+	toSet["myCustomKey"] = "VAL"
+	if err := mgr.Apply(context.TODO(), toSet, nil, toClear); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unexpected error got=%v expected=%v", err, ErrUnknownKey)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("unexpected patch with an unknown key: %v", patches[1:])
+	}
+}
+
+func TestManagerReader(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+		},
+	}
+
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(node).Build()
+	// the cache is never read
+	cli := interceptor.NewClient(reader, interceptor.Funcs{
+		Get: func(ctx context.Context, cli client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			return errors.New("unexpected read from the cache")
+		},
+	})
+	mgr := NewManagerWithReader(node.Name, cli, reader)
+	if err := mgr.Set(context.TODO(), MakeContentHashLabel("workshop.conf"), "hash"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, ok, err := mgr.Get(context.TODO(), MakeContentHashLabel("workshop.conf"))
+	if err != nil || !ok || value != "hash" {
+		t.Fatalf("unexpected label value=%q ok=%v err=%v", value, ok, err)
+	}
+}
+
+func TestManagerConflictRetry(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+		},
+	}
+
+	attempts := 0
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(node).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, cli client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			attempts++
+			if attempts == 1 {
+				// another controller changes the node after the manager read it
+				current := v1.Node{}
+				if err := cli.Get(ctx, client.ObjectKeyFromObject(obj), &current); err != nil {
+					return err
+				}
+				current.Labels = map[string]string{"foo": "quux"}
+				if err := cli.Update(ctx, &current); err != nil {
+					return err
+				}
+			}
+			return cli.Patch(ctx, obj, patch, opts...)
+		},
+	}).Build()
+	mgr := NewManager(node.Name, cli)
+
+	if err := mgr.Set(context.TODO(), MakeContentHashLabel("workshop.conf"), "hash"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("unexpected patch attempts got=%d expected=2", attempts)
+	}
+	var updatedNode v1.Node
+	if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+		t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+	}
+	if updatedNode.Labels["foo"] != "quux" || updatedNode.Labels[MakeContentHashLabel("workshop.conf")] == "" {
+		t.Fatalf("unexpected labels: %v", updatedNode.Labels)
+	}
+}
+
+func TestManagerTaint(t *testing.T) {
+	const taintKey = "workshop.golab.io/config-not-ready"
+	other := v1.Taint{Key: "node.kubernetes.io/unschedulable", Effect: v1.TaintEffectNoSchedule}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+		},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{other},
+		},
+	}
+
+	cli := newFakeClient(node)
+	mgr := NewManager(node.Name, cli)
+	taint := v1.Taint{Key: taintKey, Effect: v1.TaintEffectNoSchedule}
+	for range 2 {
+		// setting twice is idempotent
+		if err := mgr.SetTaint(context.TODO(), taint); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var updatedNode v1.Node
+	if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+		t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+	}
+	if len(updatedNode.Spec.Taints) != 2 || !updatedNode.Spec.Taints[1].MatchTaint(&taint) {
+		t.Fatalf("unexpected taints: %+v", updatedNode.Spec.Taints)
+	}
+
+	if err := mgr.ClearTaint(context.TODO(), taintKey); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+		t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+	}
+	if len(updatedNode.Spec.Taints) != 1 || !updatedNode.Spec.Taints[0].MatchTaint(&other) {
+		t.Fatalf("unexpected taints: %+v", updatedNode.Spec.Taints)
+	}
+
+	if err := NewManager("unknown-unexpected-node", cli).SetTaint(context.TODO(), taint); err == nil {
+		t.Fatalf("unexpected success tainting an unknown node")
+	}
+}
+
+func TestManagerSetCondition(t *testing.T) {
+	const condType = "ConfigurationsReady"
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+		},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+
+	cli := newFakeClient(node)
+	mgr := NewManager(node.Name, cli)
+	getCondition := func() v1.NodeCondition {
+		t.Helper()
+		var updatedNode v1.Node
+		if err := cli.Get(context.TODO(), client.ObjectKeyFromObject(node), &updatedNode); err != nil {
+			t.Fatalf("cannot get updated node %q: %v", node.Name, err)
+		}
+		if len(updatedNode.Status.Conditions) != 2 {
+			t.Fatalf("unexpected conditions: %+v", updatedNode.Status.Conditions)
+		}
+		return updatedNode.Status.Conditions[1]
+	}
+
+	err := mgr.SetCondition(context.TODO(), v1.NodeCondition{Type: condType, Status: v1.ConditionFalse, Reason: "NotAvailable", Message: "ns/foo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := getCondition()
+	if first.Status != v1.ConditionFalse || first.Reason != "NotAvailable" || first.LastTransitionTime.IsZero() {
+		t.Fatalf("unexpected condition: %+v", first)
+	}
+
+	// same status, the transition time is kept
+	err = mgr.SetCondition(context.TODO(), v1.NodeCondition{Type: condType, Status: v1.ConditionFalse, Reason: "NotAvailable", Message: "ns/bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second := getCondition()
+	if second.Message != "ns/bar" || !second.LastTransitionTime.Equal(&first.LastTransitionTime) {
+		t.Fatalf("unexpected condition: %+v", second)
+	}
+
+	err = mgr.SetCondition(context.TODO(), v1.NodeCondition{Type: condType, Status: v1.ConditionTrue, Reason: "AllAvailable"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getCondition(); got.Status != v1.ConditionTrue || got.Reason != "AllAvailable" {
+		t.Fatalf("unexpected condition: %+v", got)
+	}
+}
+
 func newFakeClient(initObjects ...runtime.Object) client.Client {
-	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(&workshopv1alpha1.Configuration{}).WithRuntimeObjects(initObjects...).Build()
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(&workshopv1alpha1.Configuration{}, &v1.Node{}).WithRuntimeObjects(initObjects...).Build()
 }
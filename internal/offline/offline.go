@@ -0,0 +1,285 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package offline applies the configurations described by manifests on disk,
+// without connecting to the API server. It is meant to bootstrap the nodes
+// and to serve the nodes which can't reach the API server.
+package offline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+)
+
+const (
+	// DefaultNamespace is the namespace of the Configurations which don't set it, like kubectl does
+	DefaultNamespace = "default"
+)
+
+const (
+	// ResultFailed is reported for the items which could not be applied
+	ResultFailed = "Failed"
+)
+
+var (
+	// ErrUnsupportedKind is returned when a manifest describes an object which is not a configuration
+	ErrUnsupportedKind = errors.New("unsupported kind")
+	// ErrConflict is reported when more items describe the same file
+	ErrConflict = errors.New("file already described by another item")
+)
+
+var decoder runtime.Decoder
+
+func init() {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(workshopv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(workshopv1alpha2.AddToScheme(scheme))
+	decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+}
+
+// Item is a configuration file described by a manifest
+type Item struct {
+	// Source is the file the manifest was read from
+	Source string
+	// Kind is either Configuration or ClusterConfiguration
+	Kind string
+	// Namespace is empty for the ClusterConfigurations
+	Namespace string
+	Name      string
+	Spec      workshopv1alpha2.ConfigurationSpec
+}
+
+// FileName returns the name of the file of the item relative to the root, like the agents compute it.
+func (it Item) FileName() string {
+	if it.Kind == "ClusterConfiguration" {
+		return it.Spec.Filename
+	}
+	return configfile.NamespacedFilename(it.Namespace, it.Spec.Filename)
+}
+
+// Manifests are the objects decoded from a directory
+type Manifests struct {
+	Items    []Item
+	Policies []workshopv1alpha2.ConfigurationPolicy
+}
+
+// LoadDir decodes all the manifests in the YAML or JSON files within the given directory
+// and its subdirectories, in lexical order. A file can hold more manifests, separated by "---".
+// Besides the Configurations (both v1alpha1 and v1alpha2) and the ClusterConfigurations,
+// the directory can hold ConfigurationPolicies, which constrain the Configurations like
+// they do in the cluster. Any other kind is rejected. The kustomization files are ignored.
+func LoadDir(dir string) (Manifests, error) {
+	var res Manifests
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isManifest(path) {
+			return nil
+		}
+		return res.loadFile(path)
+	})
+	return res, err
+}
+
+func isManifest(path string) bool {
+	switch filepath.Base(path) {
+	case "kustomization.yaml", "kustomization.yml", "Kustomization":
+		// the directory can also be a kustomize base
+		return false
+	}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+func (mf *Manifests) loadFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(src))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", path, err)
+		}
+		if err := mf.decode(path, doc); err != nil {
+			return fmt.Errorf("failed to decode %q: %w", path, err)
+		}
+	}
+}
+
+func (mf *Manifests) decode(path string, doc []byte) error {
+	if isEmpty(doc) {
+		return nil
+	}
+	obj, gvk, err := decoder.Decode(doc, nil, nil)
+	if err != nil {
+		return err
+	}
+	switch obj := obj.(type) {
+	case *workshopv1alpha1.Configuration:
+		hub := workshopv1alpha2.Configuration{}
+		if err := obj.ConvertTo(&hub); err != nil {
+			return err
+		}
+		mf.addConfiguration(path, &hub)
+	case *workshopv1alpha2.Configuration:
+		mf.addConfiguration(path, obj)
+	case *workshopv1alpha2.ClusterConfiguration:
+		mf.Items = append(mf.Items, Item{
+			Source: path,
+			Kind:   "ClusterConfiguration",
+			Name:   obj.Name,
+			Spec:   obj.Spec,
+		})
+	case *workshopv1alpha2.ConfigurationPolicy:
+		mf.Policies = append(mf.Policies, *obj)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedKind, gvk)
+	}
+	return nil
+}
+
+func (mf *Manifests) addConfiguration(path string, conf *workshopv1alpha2.Configuration) {
+	namespace := conf.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	mf.Items = append(mf.Items, Item{
+		Source:    path,
+		Kind:      "Configuration",
+		Namespace: namespace,
+		Name:      conf.Name,
+		Spec:      conf.Spec,
+	})
+}
+
+// isEmpty tells if the document holds only comments or whitespace
+func isEmpty(doc []byte) bool {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return true
+	}
+	var obj map[string]any
+	return yaml.Unmarshal(doc, &obj) == nil && len(obj) == 0
+}
+
+// Options tunes how the items are applied. The zero value is valid.
+type Options struct {
+	// Validation tunes the validation of the spec
+	Validation validate.Options
+	// AllowedNamespaces are the only namespaces whose Configurations can write files.
+	// If empty, all namespaces can.
+	AllowedNamespaces []string
+}
+
+// Result is the outcome of applying an item
+type Result struct {
+	Item
+	Outcome configfile.SyncOutcome
+	Err     error
+}
+
+// Apply validates and writes all the given items using the given Manager, like the agent does,
+// and returns the result of each of them. A failure does not prevent the other items from
+// being applied. The files of the items not described anymore are not removed.
+func Apply(lh logr.Logger, mgr *configfile.Manager, mf Manifests, opts Options) []Result {
+	results := make([]Result, 0, len(mf.Items))
+	owners := make(map[string]Item)
+	for _, item := range mf.Items {
+		res := Result{Item: item}
+		res.Err = check(item, mf.Policies, opts)
+		if res.Err == nil {
+			if owner, ok := owners[item.FileName()]; ok {
+				res.Err = fmt.Errorf("%w %s %q in %q", ErrConflict, owner.Kind, owner.Name, owner.Source)
+			}
+		}
+		if res.Err == nil {
+			owners[item.FileName()] = item
+			itemLh := lh.WithValues("source", item.Source, "kind", item.Kind, "name", item.Name)
+			res.Outcome, res.Err = mgr.HandleSync(itemLh, configfile.RequestFromSpec(item.FileName(), item.Spec))
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// check validates the given item like the agent does
+func check(item Item, policies []workshopv1alpha2.ConfigurationPolicy, opts Options) error {
+	if item.Kind == "ClusterConfiguration" {
+		return validate.ClusterRequestWithOptions(item.Spec, opts.Validation)
+	}
+	if err := validate.RequestWithOptions(item.Spec, opts.Validation); err != nil {
+		return err
+	}
+	return validate.Namespace(item.Namespace, item.Spec, opts.AllowedNamespaces, policies)
+}
+
+// Failed tells if any of the given results is a failure
+func Failed(results []Result) bool {
+	for _, res := range results {
+		if res.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteResults writes a table with the given results.
+func WriteResults(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tFILENAME\tRESULT\tMESSAGE")
+	for _, res := range results {
+		namespace := res.Namespace
+		if namespace == "" {
+			namespace = "<none>"
+		}
+		outcome, message := string(res.Outcome), ""
+		if res.Err != nil {
+			outcome, message = ResultFailed, res.Err.Error()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", res.Kind, namespace, res.Name, res.FileName(), outcome, message)
+	}
+	return tw.Flush()
+}
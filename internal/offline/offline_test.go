@@ -0,0 +1,269 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offline
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+)
+
+const (
+	configurations = `# the Configurations of the team
+apiVersion: workshop.golab.io/v1alpha2
+kind: Configuration
+metadata:
+  name: app
+  namespace: team-a
+spec:
+  filename: app.conf
+  content: "foo=1\n"
+  create: true
+  permission: 0640
+---
+---
+apiVersion: workshop.golab.io/v1alpha1
+kind: Configuration
+metadata:
+  name: legacy
+spec:
+  filename: legacy.conf
+  content: "bar=2\n"
+  create: true
+`
+	clusterConfiguration = `{
+  "apiVersion": "workshop.golab.io/v1alpha2",
+  "kind": "ClusterConfiguration",
+  "metadata": {"name": "node"},
+  "spec": {"filename": "node.conf", "content": "baz=3\n", "create": true}
+}
+`
+	policy = `apiVersion: workshop.golab.io/v1alpha2
+kind: ConfigurationPolicy
+metadata:
+  name: team-a
+spec:
+  namespaces:
+  - team-a
+  paths:
+  - "*.conf"
+`
+)
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "configurations.yaml", configurations)
+	writeFile(t, dir, "cluster/node.json", clusterConfiguration)
+	writeFile(t, dir, "policies/team-a.yml", policy)
+	writeFile(t, dir, "kustomization.yaml", "resources:\n- configurations.yaml\n")
+	writeFile(t, dir, "README.md", "not a manifest")
+
+	mf, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Item{
+		{
+			Source: filepath.Join(dir, "cluster/node.json"),
+			Kind:   "ClusterConfiguration",
+			Name:   "node",
+			Spec:   workshopv1alpha2.ConfigurationSpec{Filename: "node.conf", Content: "baz=3\n", Create: true},
+		},
+		{
+			Source:    filepath.Join(dir, "configurations.yaml"),
+			Kind:      "Configuration",
+			Namespace: "team-a",
+			Name:      "app",
+			Spec:      workshopv1alpha2.ConfigurationSpec{Filename: "app.conf", Content: "foo=1\n", Create: true, Permission: ptr.To[uint32](0640)},
+		},
+		{
+			Source:    filepath.Join(dir, "configurations.yaml"),
+			Kind:      "Configuration",
+			Namespace: DefaultNamespace,
+			Name:      "legacy",
+			Spec:      workshopv1alpha2.ConfigurationSpec{Filename: "legacy.conf", Content: "bar=2\n", Create: true},
+		},
+	}
+	if diff := cmp.Diff(mf.Items, expected); diff != "" {
+		t.Fatalf("unexpected items: %s", diff)
+	}
+	if len(mf.Policies) != 1 || mf.Policies[0].Name != "team-a" {
+		t.Fatalf("unexpected policies: %v", mf.Policies)
+	}
+}
+
+func TestLoadDirUnsupportedKind(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")
+
+	_, err := LoadDir(dir)
+	if err == nil {
+		t.Fatalf("unexpected success loading an unsupported kind")
+	}
+}
+
+func TestApply(t *testing.T) {
+	const root = "/etc/config.d"
+	storage := configfile.NewMemoryStorage()
+	if err := storage.MkdirAll(root, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mgr := configfile.NewManagerWithOptions(root, configfile.Options{Storage: storage})
+
+	mf := Manifests{
+		Items: []Item{
+			makeItem("team-a", "app", workshopv1alpha2.ConfigurationSpec{Filename: "app.conf", Content: "foo=1\n", Create: true}),
+			makeItem("team-a", "invalid", workshopv1alpha2.ConfigurationSpec{Filename: "../app.conf", Content: "foo=2\n", Create: true}),
+			makeItem("team-a", "duplicate", workshopv1alpha2.ConfigurationSpec{Filename: "app.conf", Content: "foo=3\n", Create: true}),
+			makeItem("team-a", "not-allowed-path", workshopv1alpha2.ConfigurationSpec{Filename: "app.ini", Content: "foo=4\n", Create: true}),
+			makeItem("team-b", "forbidden", workshopv1alpha2.ConfigurationSpec{Filename: "app.conf", Content: "foo=5\n", Create: true}),
+			makeItem("", "node", workshopv1alpha2.ConfigurationSpec{Filename: "node.conf", Content: "baz=3\n", Create: true}),
+		},
+		Policies: []workshopv1alpha2.ConfigurationPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+				Spec: workshopv1alpha2.ConfigurationPolicySpec{
+					Namespaces: []string{"team-a"},
+					Paths:      []string{"*.conf"},
+				},
+			},
+		},
+	}
+	opts := Options{
+		AllowedNamespaces: []string{"team-a"},
+	}
+
+	type expectedResult struct {
+		outcome configfile.SyncOutcome
+		err     error
+	}
+
+	check := func(t *testing.T, results []Result, expected []expectedResult) {
+		t.Helper()
+		if len(results) != len(expected) {
+			t.Fatalf("unexpected results got=%d expected=%d", len(results), len(expected))
+		}
+		for idx, res := range results {
+			if res.Outcome != expected[idx].outcome || !errors.Is(res.Err, expected[idx].err) {
+				t.Fatalf("unexpected result for %q got=(%q, %v) expected=(%q, %v)", res.Name, res.Outcome, res.Err, expected[idx].outcome, expected[idx].err)
+			}
+		}
+	}
+
+	results := Apply(testr.New(t), mgr, mf, opts)
+	check(t, results, []expectedResult{
+		{outcome: configfile.SyncCreated},
+		{err: validate.ErrInvalidFilename},
+		{err: ErrConflict},
+		{err: validate.ErrForbiddenPath},
+		{err: validate.ErrForbiddenNamespace},
+		{outcome: configfile.SyncCreated},
+	})
+	if !Failed(results) {
+		t.Fatalf("unexpected success")
+	}
+
+	content, err := storage.ReadFile(filepath.Join(root, "namespaces/team-a/app.conf"))
+	if err != nil || string(content) != "foo=1\n" {
+		t.Fatalf("unexpected content %q err=%v", content, err)
+	}
+	if _, err := storage.ReadFile(filepath.Join(root, "node.conf")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// applying again must not change anything
+	mf.Items = []Item{mf.Items[0], mf.Items[5]}
+	results = Apply(testr.New(t), mgr, mf, opts)
+	check(t, results, []expectedResult{
+		{outcome: configfile.SyncUnchanged},
+		{outcome: configfile.SyncUnchanged},
+	})
+	if Failed(results) {
+		t.Fatalf("unexpected failure")
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	results := []Result{
+		{
+			Item:    makeItem("team-a", "app", workshopv1alpha2.ConfigurationSpec{Filename: "app.conf"}),
+			Outcome: configfile.SyncCreated,
+		},
+		{
+			Item: makeItem("", "node", workshopv1alpha2.ConfigurationSpec{Filename: "node.conf"}),
+			Err:  errors.New("boom"),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := [][]string{
+		{"KIND", "NAMESPACE", "NAME", "FILENAME", "RESULT", "MESSAGE"},
+		{"Configuration", "team-a", "app", "namespaces/team-a/app.conf", "Created"},
+		{"ClusterConfiguration", "<none>", "node", "node.conf", "Failed", "boom"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	for idx, line := range lines {
+		if diff := cmp.Diff(strings.Fields(line), expected[idx]); diff != "" {
+			t.Fatalf("unexpected line %d: %s", idx, diff)
+		}
+	}
+}
+
+// makeItem makes a Configuration, or a ClusterConfiguration if namespace is empty
+func makeItem(namespace, name string, spec workshopv1alpha2.ConfigurationSpec) Item {
+	item := Item{
+		Source:    "test.yaml",
+		Kind:      "Configuration",
+		Namespace: namespace,
+		Name:      name,
+		Spec:      spec,
+	}
+	if namespace == "" {
+		item.Kind = "ClusterConfiguration"
+	}
+	return item
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
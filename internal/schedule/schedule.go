@@ -0,0 +1,257 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schedule evaluates the maintenance windows restricting when the configuration files can change.
+// The windows start at the times matched by cron expressions, evaluated in a time zone.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+)
+
+// searchYears bounds the search of the next start of a window: expressions like "0 0 30 2 *" never match.
+const searchYears = 5
+
+var (
+	// ErrInvalidCron is returned, wrapped, when a cron expression can't be parsed.
+	ErrInvalidCron = errors.New("invalid cron expression")
+	// ErrInvalidWindow is returned, wrapped, when a window or the time zone is not valid.
+	ErrInvalidWindow = errors.New("invalid maintenance window")
+)
+
+// descriptors are the shortcuts for the common expressions, like in most cron implementations
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = [...]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// both 0 and 7 are sunday
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Cron is a parsed cron expression: minute, hour, day of month, month and day of week.
+// The zero value never matches.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// like in cron, if both the day fields are restricted, a day matching either of them matches
+	anyDom, anyDow bool
+}
+
+// ParseCron parses the given standard 5-field cron expression. The fields support
+// lists, ranges, steps and the names of the months and of the days of the week,
+// and the expression can be one of the descriptors like @daily.
+func ParseCron(expr string) (Cron, error) {
+	if desc, ok := descriptors[strings.TrimSpace(expr)]; ok {
+		expr = desc
+	}
+	items := strings.Fields(expr)
+	if len(items) != len(cronFields) {
+		return Cron{}, fmt.Errorf("%w %q: expected %d fields, got %d", ErrInvalidCron, expr, len(cronFields), len(items))
+	}
+	var bits [len(cronFields)]uint64
+	for idx, item := range items {
+		val, err := parseField(item, cronFields[idx])
+		if err != nil {
+			return Cron{}, fmt.Errorf("%w %q: %w", ErrInvalidCron, expr, err)
+		}
+		bits[idx] = val
+	}
+	dow := bits[4]
+	if dow&(1<<7) != 0 {
+		dow = dow&^(1<<7) | 1
+	}
+	return Cron{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    dow,
+		anyDom: strings.HasPrefix(items[2], "*"),
+		anyDow: strings.HasPrefix(items[4], "*"),
+	}, nil
+}
+
+// parseField parses a comma-separated list of values, ranges or steps, returning the bit set of the values matched.
+func parseField(item string, field cronField) (uint64, error) {
+	var res uint64
+	for _, part := range strings.Split(item, ",") {
+		span, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			val, err := strconv.Atoi(stepStr)
+			if err != nil || val < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepStr, field.name)
+			}
+			step = val
+		}
+		var lo, hi int
+		switch loStr, hiStr, isRange := strings.Cut(span, "-"); {
+		case span == "*":
+			lo, hi = field.min, field.max
+		case isRange:
+			var err error
+			if lo, err = parseValue(loStr, field); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(hiStr, field); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = parseValue(span, field); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				// like "5/15": from the value to the end of the range
+				hi = field.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q in %s", span, field.name)
+		}
+		for val := lo; val <= hi; val += step {
+			res |= 1 << val
+		}
+	}
+	return res, nil
+}
+
+func parseValue(str string, field cronField) (int, error) {
+	for idx, name := range field.names {
+		if strings.EqualFold(str, name) {
+			return idx + field.min, nil
+		}
+	}
+	val, err := strconv.Atoi(str)
+	if err != nil || val < field.min || val > field.max {
+		return 0, fmt.Errorf("invalid %s %q, must be within %d and %d", field.name, str, field.min, field.max)
+	}
+	return val, nil
+}
+
+// Next returns the first time matched by the expression strictly after the given time,
+// in the location of the given time. Returns the zero time if nothing matches in the next years.
+func (c Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + searchYears
+	for t.Year() <= limit {
+		switch {
+		case !has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c Cron) dayMatches(t time.Time) bool {
+	domMatches := has(c.dom, t.Day())
+	dowMatches := has(c.dow, int(t.Weekday()))
+	if c.anyDom || c.anyDow {
+		return domMatches && dowMatches
+	}
+	return domMatches || dowMatches
+}
+
+func has(bits uint64, val int) bool {
+	return bits&(1<<val) != 0
+}
+
+// Window is a parsed maintenance window.
+type Window struct {
+	Start    Cron
+	Duration time.Duration
+}
+
+// Schedule is a parsed maintenance schedule.
+type Schedule struct {
+	windows  []Window
+	location *time.Location
+}
+
+// New parses the given maintenance schedule. An empty time zone is UTC.
+func New(spec workshopv1alpha2.MaintenanceSchedule) (*Schedule, error) {
+	if len(spec.Windows) == 0 {
+		return nil, fmt.Errorf("%w: at least one window is required", ErrInvalidWindow)
+	}
+	loc, err := time.LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q: %w", ErrInvalidWindow, spec.TimeZone, err)
+	}
+	res := &Schedule{
+		windows:  make([]Window, 0, len(spec.Windows)),
+		location: loc,
+	}
+	for _, win := range spec.Windows {
+		start, err := ParseCron(win.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidWindow, err)
+		}
+		if win.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("%w: duration must be positive, got %v", ErrInvalidWindow, win.Duration.Duration)
+		}
+		res.windows = append(res.windows, Window{Start: start, Duration: win.Duration.Duration})
+	}
+	return res, nil
+}
+
+// Open tells if the given time is within any of the windows. If not, returns the start
+// of the next window, or the zero time if no window starts again.
+func (s *Schedule) Open(now time.Time) (bool, time.Time) {
+	now = now.In(s.location)
+	var next time.Time
+	for _, win := range s.windows {
+		// the window is open if it started within its duration
+		if start := win.Start.Next(now.Add(-win.Duration)); !start.IsZero() && !start.After(now) {
+			return true, time.Time{}
+		}
+		if start := win.Start.Next(now); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return false, next
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+)
+
+func TestParseCron(t *testing.T) {
+	type testCase struct {
+		expr        string
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{expr: "* * * * *"},
+		{expr: "0 2 * * sat,sun"},
+		{expr: "*/15 9-17 1,15 JAN-jun 1-5"},
+		{expr: "5/10 * * * 7"},
+		{expr: "@daily"},
+		{expr: "", expectedErr: ErrInvalidCron},
+		{expr: "0 2 * *", expectedErr: ErrInvalidCron},
+		{expr: "60 * * * *", expectedErr: ErrInvalidCron},
+		{expr: "0 24 * * *", expectedErr: ErrInvalidCron},
+		{expr: "0 0 0 * *", expectedErr: ErrInvalidCron},
+		{expr: "0 0 * 13 *", expectedErr: ErrInvalidCron},
+		{expr: "0 0 * * 8", expectedErr: ErrInvalidCron},
+		{expr: "0 0 * * fri-mon", expectedErr: ErrInvalidCron},
+		{expr: "*/0 * * * *", expectedErr: ErrInvalidCron},
+		{expr: "0 0 * * funday", expectedErr: ErrInvalidCron},
+		{expr: "@often", expectedErr: ErrInvalidCron},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.expr, func(t *testing.T) {
+			_, err := ParseCron(tcase.expr)
+			if !errors.Is(err, tcase.expectedErr) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	// a wednesday
+	from := time.Date(2025, 7, 2, 10, 30, 20, 0, time.UTC)
+
+	type testCase struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}
+
+	testCases := []testCase{
+		{expr: "* * * * *", from: from, expected: time.Date(2025, 7, 2, 10, 31, 0, 0, time.UTC)},
+		{expr: "30 10 * * *", from: from, expected: time.Date(2025, 7, 3, 10, 30, 0, 0, time.UTC)},
+		{expr: "*/20 * * * *", from: from, expected: time.Date(2025, 7, 2, 10, 40, 0, 0, time.UTC)},
+		{expr: "0 2 * * sat,sun", from: from, expected: time.Date(2025, 7, 5, 2, 0, 0, 0, time.UTC)},
+		{expr: "0 0 1 * *", from: from, expected: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", from: from, expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{expr: "0 0 15 * mon", from: from, expected: time.Date(2025, 7, 7, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 30 2 *", from: from},
+		{expr: "0 3 * * *", from: time.Date(2025, 7, 2, 12, 0, 0, 0, rome), expected: time.Date(2025, 7, 3, 3, 0, 0, 0, rome)},
+		// skipped by the daylight saving time change
+		{expr: "30 2 * * *", from: time.Date(2025, 3, 30, 0, 0, 0, 0, rome), expected: time.Date(2025, 3, 31, 2, 30, 0, 0, rome)},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.expr, func(t *testing.T) {
+			cron, err := ParseCron(tcase.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cron.Next(tcase.from); !got.Equal(tcase.expected) {
+				t.Fatalf("unexpected next time got=%v expected=%v", got, tcase.expected)
+			}
+		})
+	}
+}
+
+func TestScheduleOpen(t *testing.T) {
+	sched, err := New(workshopv1alpha2.MaintenanceSchedule{
+		Windows: []workshopv1alpha2.MaintenanceWindow{
+			{Start: "0 2 * * sat,sun", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+			{Start: "0 22 * * wed", Duration: metav1.Duration{Duration: 30 * time.Minute}},
+		},
+		TimeZone: "Asia/Tokyo",
+	})
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	type testCase struct {
+		name         string
+		now          time.Time
+		expectedOpen bool
+		expectedNext time.Time
+	}
+
+	testCases := []testCase{
+		{
+			name:         "before the windows",
+			now:          time.Date(2025, 7, 2, 10, 0, 0, 0, tokyo),
+			expectedNext: time.Date(2025, 7, 2, 22, 0, 0, 0, tokyo),
+		},
+		{
+			name:         "window start",
+			now:          time.Date(2025, 7, 2, 22, 0, 0, 0, tokyo),
+			expectedOpen: true,
+		},
+		{
+			name:         "within the window, in another time zone",
+			now:          time.Date(2025, 7, 5, 18, 30, 0, 0, time.UTC),
+			expectedOpen: true,
+		},
+		{
+			name:         "window end",
+			now:          time.Date(2025, 7, 2, 22, 30, 0, 0, tokyo),
+			expectedNext: time.Date(2025, 7, 5, 2, 0, 0, 0, tokyo),
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			open, next := sched.Open(tcase.now)
+			if open != tcase.expectedOpen {
+				t.Fatalf("unexpected open got=%v expected=%v", open, tcase.expectedOpen)
+			}
+			if !next.Equal(tcase.expectedNext) {
+				t.Fatalf("unexpected next window got=%v expected=%v", next, tcase.expectedNext)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	type testCase struct {
+		name string
+		spec workshopv1alpha2.MaintenanceSchedule
+	}
+
+	testCases := []testCase{
+		{
+			name: "no windows",
+			spec: workshopv1alpha2.MaintenanceSchedule{},
+		},
+		{
+			name: "unknown time zone",
+			spec: workshopv1alpha2.MaintenanceSchedule{
+				Windows:  []workshopv1alpha2.MaintenanceWindow{{Start: "@daily", Duration: metav1.Duration{Duration: time.Hour}}},
+				TimeZone: "Mars/Olympus_Mons",
+			},
+		},
+		{
+			name: "zero duration",
+			spec: workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{{Start: "@daily"}},
+			},
+		},
+		{
+			name: "bad cron",
+			spec: workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{{Start: "daily", Duration: metav1.Duration{Duration: time.Hour}}},
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if _, err := New(tcase.spec); !errors.Is(err, ErrInvalidWindow) {
+				t.Fatalf("unexpected error got=%v expected=%v", err, ErrInvalidWindow)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package textdiff computes the line oriented differences between two texts,
+// like diff(1) does. It is meant for the configuration files, which are small.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// contextLines is the number of unchanged lines shown around each change
+	contextLines = 3
+	// maxCells bounds the memory used to compute the differences: when the changed
+	// region of the texts is larger, it is reported as entirely replaced.
+	maxCells = 4 * 1024 * 1024
+)
+
+// edit is a line of the edit script: kept (' '), removed ('-') or added ('+')
+type edit struct {
+	kind byte
+	line string
+}
+
+// Unified returns the differences between the from and to texts in the unified format,
+// using the given names in the header. Returns empty if the texts are equal.
+func Unified(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	edits := diffLines(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, hunk := range hunks(edits) {
+		writeHunk(&sb, edits, hunk[0], hunk[1])
+	}
+	return sb.String()
+}
+
+// splitLines splits the text in lines, each one keeping its line terminator, if any
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the edit script turning a into b, through their longest common subsequence
+func diffLines(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{kind: ' ', line: line})
+	}
+	edits = append(edits, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{kind: ' ', line: line})
+	}
+	return edits
+}
+
+func diffMiddle(a, b []string) []edit {
+	var edits []edit
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			edits = append(edits, edit{kind: '-', line: line})
+		}
+		for _, line := range b {
+			edits = append(edits, edit{kind: '+', line: line})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{kind: ' ', line: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{kind: '-', line: a[i]})
+			i++
+		default:
+			edits = append(edits, edit{kind: '+', line: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{kind: '-', line: a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{kind: '+', line: b[j]})
+	}
+	return edits
+}
+
+// hunks returns the [start, end) ranges of the edits to show: the changes with their context,
+// merging the changes whose context overlap.
+func hunks(edits []edit) [][2]int {
+	var res [][2]int
+	for idx, ed := range edits {
+		if ed.kind == ' ' {
+			continue
+		}
+		start := max(0, idx-contextLines)
+		end := min(len(edits), idx+1+contextLines)
+		if len(res) > 0 && start <= res[len(res)-1][1] {
+			res[len(res)-1][1] = end
+			continue
+		}
+		res = append(res, [2]int{start, end})
+	}
+	return res
+}
+
+func writeHunk(sb *strings.Builder, edits []edit, start, end int) {
+	fromStart, toStart := 0, 0
+	for _, ed := range edits[:start] {
+		if ed.kind != '+' {
+			fromStart++
+		}
+		if ed.kind != '-' {
+			toStart++
+		}
+	}
+	fromLen, toLen := 0, 0
+	for _, ed := range edits[start:end] {
+		if ed.kind != '+' {
+			fromLen++
+		}
+		if ed.kind != '-' {
+			toLen++
+		}
+	}
+	// like diff(1), empty ranges start at the line before them
+	if fromLen > 0 {
+		fromStart++
+	}
+	if toLen > 0 {
+		toStart++
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", fromStart, fromLen, toStart, toLen)
+	for _, ed := range edits[start:end] {
+		sb.WriteByte(ed.kind)
+		sb.WriteString(ed.line)
+		if !strings.HasSuffix(ed.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnified(t *testing.T) {
+	type testCase struct {
+		name     string
+		from     string
+		to       string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			name: "equal",
+			from: "foo=1\nbar=2\n",
+			to:   "foo=1\nbar=2\n",
+		},
+		{
+			name:     "from empty",
+			from:     "",
+			to:       "foo=1\n",
+			expected: "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+foo=1\n",
+		},
+		{
+			name:     "to empty",
+			from:     "foo=1\n",
+			to:       "",
+			expected: "--- a\n+++ b\n@@ -1,1 +0,0 @@\n-foo=1\n",
+		},
+		{
+			name:     "changed line",
+			from:     "a\nb\nc\n",
+			to:       "a\nB\nc\n",
+			expected: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:     "missing newline",
+			from:     "a\nb",
+			to:       "a\nb\n",
+			expected: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "distant changes",
+			from: numbered(1, 20),
+			to:   strings.Replace(strings.Replace(numbered(1, 20), "line2\n", "LINE2\n", 1), "line19\n", "", 1),
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n line1\n-line2\n+LINE2\n line3\n line4\n line5\n" +
+				"@@ -16,5 +16,4 @@\n line16\n line17\n line18\n-line19\n line20\n",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got := Unified("a", "b", tcase.from, tcase.to)
+			if diff := cmp.Diff(got, tcase.expected); diff != "" {
+				t.Fatalf("unexpected diff (-got +expected):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnifiedLarge(t *testing.T) {
+	// beyond the limit the changed region is reported as replaced
+	from := numbered(1, 3000)
+	to := numbered(10001, 13000)
+	got := Unified("a", "b", from, to)
+	if !strings.HasPrefix(got, "--- a\n+++ b\n@@ -1,3000 +1,3000 @@\n-line1\n") {
+		t.Fatalf("unexpected diff: %.80q", got)
+	}
+}
+
+func numbered(first, last int) string {
+	var sb strings.Builder
+	for idx := first; idx <= last; idx++ {
+		fmt.Fprintf(&sb, "line%d\n", idx)
+	}
+	return sb.String()
+}
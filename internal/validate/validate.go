@@ -18,33 +18,327 @@ limitations under the License.
 package validate
 
 import (
+	"encoding/json"
 	"errors"
-	"os"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
-	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/schedule"
 )
 
+// DefaultMaxPermission is the UNIX permission octal bit mask the files can have at most,
+// unless configured otherwise: anything but world-writable.
+const DefaultMaxPermission = 0775
+
+// Options tunes the validation. The zero value is valid.
+type Options struct {
+	// AllowSpecialBits allows the files to have the setuid, setgid and sticky bits.
+	AllowSpecialBits bool
+	// MaxPermission is the UNIX permission octal bit mask (example: 0755) the files can have at most.
+	// The special bits are controlled by AllowSpecialBits. If zero, DefaultMaxPermission is used.
+	MaxPermission uint32
+	// LockFiles reserves the names of the sidecar lock files (see configfile.LockSuffix),
+	// because the agents lock the configuration files.
+	LockFiles bool
+}
+
 var (
-	ErrMissingFilename   = errors.New("filename can't be empty")
-	ErrInvalidPermission = errors.New("requested permissions are not a valid UNIX permission set")
+	ErrMissingFilename    = errors.New("filename can't be empty")
+	ErrInvalidPermission  = errors.New("requested permissions are not a valid UNIX permission set")
+	ErrSpecialPermission  = errors.New("requested permissions include setuid, setgid or sticky bits, which are not allowed")
+	ErrPermissionTooBroad = errors.New("requested permissions exceed the maximum allowed")
+	ErrInvalidMaxSize     = errors.New("maximum size can't be negative")
+	ErrInvalidFilename    = errors.New("filename must be a clean path within the root")
+	ErrReservedFilename   = errors.New("filename is reserved to the namespaced configurations")
+	ErrLockFilename       = errors.New("filename is reserved to the sidecar lock files")
+	ErrInvalidRollout     = errors.New("rollout strategy is not valid")
+	ErrInvalidSchedule    = errors.New("maintenance schedule is not valid")
+	// ErrForbiddenNamespace and ErrForbiddenPath are reported when the namespace is not allowed to write the file
+	ErrForbiddenNamespace = errors.New("namespace is not allowed to write configuration files")
+	ErrForbiddenPath      = errors.New("filename is not allowed by the configuration policies of the namespace")
+	// ErrForbiddenHealthGate is reported when the health gate checks what the namespace does not own
+	ErrForbiddenHealthGate = errors.New("health gate is not allowed for the namespace")
+	// ErrPolicy* are reported when the file violates the constraints of the configuration policies
+	ErrPolicyPermission = errors.New("permission not allowed by the configuration policy")
+	ErrPolicyMaxSize    = errors.New("content exceeds the maximum size allowed by the configuration policy")
+	ErrPolicyFormat     = errors.New("content format not allowed by the configuration policy")
 )
 
-// Request ensures a spec is semantically correct. If so returns nil,
-// otherwise a well known Error (validate.Err*)
-func Request(spec workshopv1alpha1.ConfigurationSpec) error {
+// Request ensures a spec is semantically correct using the default Options.
+// If so returns nil, otherwise a well known Error (validate.Err*)
+func Request(spec workshopv1alpha2.ConfigurationSpec) error {
+	return RequestWithOptions(spec, Options{})
+}
+
+// RequestWithOptions ensures a spec is semantically correct like Request, using the given Options.
+func RequestWithOptions(spec workshopv1alpha2.ConfigurationSpec, opts Options) error {
 	if spec.Filename == "" {
 		return ErrMissingFilename
 	}
+	if !filepath.IsLocal(spec.Filename) || filepath.Clean(spec.Filename) != spec.Filename {
+		return ErrInvalidFilename
+	}
+	if opts.LockFiles && configfile.IsLockFile(spec.Filename) {
+		return ErrLockFilename
+	}
+	if spec.MaxSize != nil && *spec.MaxSize < 0 {
+		return ErrInvalidMaxSize
+	}
+	if err := validRollout(spec.Rollout); err != nil {
+		return err
+	}
+	if spec.Schedule != nil {
+		if _, err := schedule.New(*spec.Schedule); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+		}
+	}
+	perm := uint32(configfile.DefaultPermission)
 	if spec.Permission != nil {
-		return validPermission(*spec.Permission)
+		perm = *spec.Permission
+	}
+	return validPermission(perm, opts)
+}
+
+// ClusterRequest ensures a cluster-scoped spec is semantically correct using the default Options.
+// On top of the Request checks, the file can't be within the area reserved
+// to the namespaced configurations. If so returns nil, otherwise a well known
+// Error (validate.Err*)
+func ClusterRequest(spec workshopv1alpha2.ConfigurationSpec) error {
+	return ClusterRequestWithOptions(spec, Options{})
+}
+
+// ClusterRequestWithOptions ensures a cluster-scoped spec is semantically correct like ClusterRequest,
+// using the given Options.
+func ClusterRequestWithOptions(spec workshopv1alpha2.ConfigurationSpec, opts Options) error {
+	if err := RequestWithOptions(spec, opts); err != nil {
+		return err
+	}
+	if configfile.IsNamespaced(spec.Filename) {
+		return ErrReservedFilename
+	}
+	return nil
+}
+
+// Namespace ensures the given namespace can write the file of the given spec.
+// If allowedNamespaces is not empty, the namespace must be one of them; the health
+// gate must honor HealthGate; if any of the policies selects the namespace, the filename
+// must match any of their paths, and the spec must honor the constraints of at least one
+// of the matching policies.
+// If so returns nil, otherwise a well known Error (validate.Err*), possibly wrapped.
+func Namespace(namespace string, spec workshopv1alpha2.ConfigurationSpec, allowedNamespaces []string, policies []workshopv1alpha2.ConfigurationPolicy) error {
+	if len(allowedNamespaces) > 0 && !slices.Contains(allowedNamespaces, namespace) {
+		return ErrForbiddenNamespace
+	}
+	if err := HealthGate(namespace, spec, policies); err != nil {
+		return err
+	}
+	selected := false
+	var violation error
+	for _, policy := range policies {
+		if !slices.Contains(policy.Spec.Namespaces, namespace) {
+			continue
+		}
+		selected = true
+		if !matchesAny(policy.Spec.Paths, spec.Filename) {
+			continue
+		}
+		err := honorsPolicy(policy, spec)
+		if err == nil {
+			return nil
+		}
+		if violation == nil {
+			violation = err
+		}
+	}
+	if violation != nil {
+		return violation
+	}
+	if selected {
+		return ErrForbiddenPath
+	}
+	return nil
+}
+
+// HealthGate ensures the health gate of the given spec, if any, checks only what the given namespace owns:
+// the pod readiness checks must select the pods of the namespace, and the HTTP checks, which can probe
+// any endpoint the rollout controller reaches, must be allowed by a policy selecting the namespace.
+// If so returns nil, otherwise ErrForbiddenHealthGate, wrapped.
+func HealthGate(namespace string, spec workshopv1alpha2.ConfigurationSpec, policies []workshopv1alpha2.ConfigurationPolicy) error {
+	if spec.Rollout == nil || spec.Rollout.Canary == nil {
+		return nil
+	}
+	gate := spec.Rollout.Canary.HealthGate
+	if gate.PodReadiness != nil && gate.PodReadiness.Namespace != namespace {
+		return fmt.Errorf("%w: pod readiness must select the pods of namespace %q, got %q", ErrForbiddenHealthGate, namespace, gate.PodReadiness.Namespace)
+	}
+	if gate.HTTPGet != nil && !slices.ContainsFunc(policies, func(policy workshopv1alpha2.ConfigurationPolicy) bool {
+		return policy.Spec.AllowHTTPHealthGates && slices.Contains(policy.Spec.Namespaces, namespace)
+	}) {
+		return fmt.Errorf("%w: HTTP checks require a configuration policy allowing them", ErrForbiddenHealthGate)
+	}
+	return nil
+}
+
+// IsForbidden returns true if the error reports the namespace can't write the file.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbiddenNamespace) || errors.Is(err, ErrForbiddenPath) || errors.Is(err, ErrForbiddenHealthGate)
+}
+
+// IsPolicyViolation returns true if the error reports the file violates the constraints of a policy.
+func IsPolicyViolation(err error) bool {
+	return errors.Is(err, ErrPolicyPermission) || errors.Is(err, ErrPolicyMaxSize) || errors.Is(err, ErrPolicyFormat)
+}
+
+func honorsPolicy(policy workshopv1alpha2.ConfigurationPolicy, spec workshopv1alpha2.ConfigurationSpec) error {
+	if maxPerm := policy.Spec.MaxPermission; maxPerm != nil {
+		perm := uint32(configfile.DefaultPermission)
+		if spec.Permission != nil {
+			perm = *spec.Permission
+		}
+		if perm&^*maxPerm != 0 {
+			return fmt.Errorf("%w: policy %q allows at most %04o, got %04o", ErrPolicyPermission, policy.Name, *maxPerm, perm)
+		}
+	}
+	if maxSize := policy.Spec.MaxSize; maxSize != nil && int64(len(spec.Content)) > *maxSize {
+		return fmt.Errorf("%w: policy %q allows at most %d bytes, got %d", ErrPolicyMaxSize, policy.Name, *maxSize, len(spec.Content))
+	}
+	if formats := policy.Spec.Formats; len(formats) > 0 && !slices.ContainsFunc(formats, func(format workshopv1alpha2.ContentFormat) bool {
+		return hasFormat(spec.Content, format)
+	}) {
+		return fmt.Errorf("%w: policy %q allows only %v", ErrPolicyFormat, policy.Name, formats)
 	}
 	return nil
 }
 
-func validPermission(perm uint32) error {
+func hasFormat(content string, format workshopv1alpha2.ContentFormat) bool {
+	switch format {
+	case workshopv1alpha2.ContentFormatJSON:
+		return json.Valid([]byte(content))
+	case workshopv1alpha2.ContentFormatYAML:
+		var doc any
+		return yaml.Unmarshal([]byte(content), &doc) == nil
+	case workshopv1alpha2.ContentFormatText:
+		return utf8.ValidString(content) && !strings.ContainsRune(content, 0)
+	default:
+		return false
+	}
+}
+
+func matchesAny(patterns []string, fileName string) bool {
+	for _, pattern := range patterns {
+		// malformed patterns never match
+		if ok, _ := path.Match(pattern, filepath.ToSlash(fileName)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validPermission checks the given permission, in the UNIX octal notation.
+// Note the special bits have a different value in fs.FileMode (see configfile.FileModeFromUnix).
+func validPermission(perm uint32, opts Options) error {
 	// no spurious bits
-	if (os.FileMode(perm) & os.ModeType) != 0 {
+	if perm&^(configfile.UnixSpecialBits|uint32(fs.ModePerm)) != 0 {
 		return ErrInvalidPermission
 	}
+	if perm&configfile.UnixSpecialBits != 0 && !opts.AllowSpecialBits {
+		return ErrSpecialPermission
+	}
+	maxPerm := opts.MaxPermission
+	if maxPerm == 0 {
+		maxPerm = DefaultMaxPermission
+	}
+	if perm&uint32(fs.ModePerm)&^maxPerm != 0 {
+		return ErrPermissionTooBroad
+	}
+	return nil
+}
+
+func validRollout(strategy *workshopv1alpha2.RolloutStrategy) error {
+	if strategy == nil {
+		return nil
+	}
+	if strategy.BatchSize != nil && *strategy.BatchSize < 1 {
+		return fmt.Errorf("%w: batch size must be at least 1, got %d", ErrInvalidRollout, *strategy.BatchSize)
+	}
+	if strategy.PauseBetweenBatches != nil && strategy.PauseBetweenBatches.Duration < 0 {
+		return fmt.Errorf("%w: pause between batches can't be negative, got %v", ErrInvalidRollout, strategy.PauseBetweenBatches.Duration)
+	}
+	if err := validNodeCount("max unavailable", strategy.MaxUnavailable); err != nil {
+		return err
+	}
+	if strategy.Canary != nil {
+		return validCanary(*strategy.Canary)
+	}
+	return nil
+}
+
+func validCanary(canary workshopv1alpha2.CanaryStrategy) error {
+	if err := validNodeCount("canary nodes", canary.Nodes); err != nil {
+		return err
+	}
+	gate := canary.HealthGate
+	if gate.HTTPGet == nil && gate.PodReadiness == nil {
+		return fmt.Errorf("%w: health gate must set at least one check", ErrInvalidRollout)
+	}
+	if gate.HTTPGet != nil {
+		if gate.HTTPGet.Port < 1 || gate.HTTPGet.Port > 65535 {
+			return fmt.Errorf("%w: health gate port must be within 1 and 65535, got %d", ErrInvalidRollout, gate.HTTPGet.Port)
+		}
+		if !slices.Contains([]string{"", "HTTP", "HTTPS"}, gate.HTTPGet.Scheme) {
+			return fmt.Errorf("%w: health gate scheme must be HTTP or HTTPS, got %q", ErrInvalidRollout, gate.HTTPGet.Scheme)
+		}
+	}
+	if gate.PodReadiness != nil {
+		if gate.PodReadiness.Namespace == "" {
+			return fmt.Errorf("%w: health gate pod readiness requires a namespace", ErrInvalidRollout)
+		}
+		if _, err := metav1.LabelSelectorAsSelector(&gate.PodReadiness.Selector); err != nil {
+			return fmt.Errorf("%w: health gate pod selector: %w", ErrInvalidRollout, err)
+		}
+	}
+	durations := []struct {
+		name string
+		dur  *metav1.Duration
+	}{
+		{name: "initial delay", dur: gate.InitialDelay},
+		{name: "period", dur: gate.Period},
+		{name: "timeout", dur: gate.Timeout},
+	}
+	for _, item := range durations {
+		if item.dur != nil && item.dur.Duration < 0 {
+			return fmt.Errorf("%w: health gate %s can't be negative, got %v", ErrInvalidRollout, item.name, item.dur.Duration)
+		}
+	}
+	if gate.Period != nil && gate.Period.Duration == 0 {
+		return fmt.Errorf("%w: health gate period must be positive", ErrInvalidRollout)
+	}
+	return nil
+}
+
+// validNodeCount checks the given absolute or percentage number of nodes, if set.
+func validNodeCount(name string, val *intstr.IntOrString) error {
+	if val == nil {
+		return nil
+	}
+	// scaled on 100 nodes, the percentages are checked for being within 1% and 100%
+	count, err := intstr.GetScaledValueFromIntOrPercent(val, 100, true)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRollout, err)
+	}
+	if count < 1 || (val.Type == intstr.String && count > 100) {
+		return fmt.Errorf("%w: %s must be at least 1 and at most 100%%, got %s", ErrInvalidRollout, name, val.String())
+	}
 	return nil
 }
@@ -17,28 +17,33 @@ limitations under the License.
 package validate
 
 import (
+	"errors"
+	"io/fs"
 	"testing"
+	"time"
 
-	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 )
 
 func TestRequest(t *testing.T) {
 	type testCase struct {
 		name        string
-		spec        workshopv1alpha1.ConfigurationSpec
+		spec        workshopv1alpha2.ConfigurationSpec
 		expectedErr error
 	}
 
 	testCases := []testCase{
 		{
 			name:        "empty",
-			spec:        workshopv1alpha1.ConfigurationSpec{},
+			spec:        workshopv1alpha2.ConfigurationSpec{},
 			expectedErr: ErrMissingFilename,
 		},
 		{
 			name: "good",
-			spec: workshopv1alpha1.ConfigurationSpec{
+			spec: workshopv1alpha2.ConfigurationSpec{
 				Filename:   "fooconf.json",
 				Content:    "{}",
 				Create:     true,
@@ -48,7 +53,7 @@ func TestRequest(t *testing.T) {
 		},
 		{
 			name: "empty content is fine",
-			spec: workshopv1alpha1.ConfigurationSpec{
+			spec: workshopv1alpha2.ConfigurationSpec{
 				Filename:   "fooconf.json",
 				Create:     true,
 				Permission: ptr.To[uint32](0644),
@@ -57,13 +62,63 @@ func TestRequest(t *testing.T) {
 		},
 		{
 			name: "bad permissions",
-			spec: workshopv1alpha1.ConfigurationSpec{
+			spec: workshopv1alpha2.ConfigurationSpec{
 				Filename:   "fooconf.json",
 				Create:     true,
 				Permission: ptr.To[uint32](0xCAFECAFE),
 			},
 			expectedErr: ErrInvalidPermission,
 		},
+		{
+			name: "negative max size",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "fooconf.json",
+				Create:   true,
+				MaxSize:  ptr.To[int64](-1),
+			},
+			expectedErr: ErrInvalidMaxSize,
+		},
+		{
+			name: "absolute filename",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "/etc/fooconf.json",
+				Create:   true,
+			},
+			expectedErr: ErrInvalidFilename,
+		},
+		{
+			name: "filename escaping the root",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "../fooconf.json",
+				Create:   true,
+			},
+			expectedErr: ErrInvalidFilename,
+		},
+		{
+			name: "filename not clean",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo/./../fooconf.json",
+				Create:   true,
+			},
+			expectedErr: ErrInvalidFilename,
+		},
+		{
+			name: "filename in subdirectory is fine",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.d/fooconf.json",
+				Create:   true,
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "zero max size is fine",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "fooconf.json",
+				Create:   true,
+				MaxSize:  ptr.To[int64](0),
+			},
+			expectedErr: nil,
+		},
 	}
 
 	for _, tcase := range testCases {
@@ -79,3 +134,702 @@ func TestRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestLockFiles(t *testing.T) {
+	type testCase struct {
+		name        string
+		filename    string
+		opts        Options
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:     "lock filename without locking",
+			filename: "fooconf.json.lock",
+		},
+		{
+			name:        "lock filename with locking",
+			filename:    "fooconf.json.lock",
+			opts:        Options{LockFiles: true},
+			expectedErr: ErrLockFilename,
+		},
+		{
+			name:        "lock filename in subdirectory with locking",
+			filename:    "foo.d/fooconf.json.lock",
+			opts:        Options{LockFiles: true},
+			expectedErr: ErrLockFilename,
+		},
+		{
+			name:     "regular filename with locking",
+			filename: "fooconf.json",
+			opts:     Options{LockFiles: true},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{Filename: tcase.filename, Create: true}
+			if gotErr := RequestWithOptions(spec, tcase.opts); !errors.Is(gotErr, tcase.expectedErr) {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestPermission(t *testing.T) {
+	type testCase struct {
+		name        string
+		permission  *uint32
+		opts        Options
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:       "default permission",
+			permission: nil,
+		},
+		{
+			name:       "read only",
+			permission: ptr.To[uint32](0400),
+		},
+		{
+			name:       "group writable",
+			permission: ptr.To[uint32](0664),
+		},
+		{
+			name:        "world-writable",
+			permission:  ptr.To[uint32](0666),
+			expectedErr: ErrPermissionTooBroad,
+		},
+		{
+			name:        "world-writable executable",
+			permission:  ptr.To[uint32](0777),
+			expectedErr: ErrPermissionTooBroad,
+		},
+		{
+			name:        "setuid",
+			permission:  ptr.To[uint32](04755),
+			expectedErr: ErrSpecialPermission,
+		},
+		{
+			name:        "setgid",
+			permission:  ptr.To[uint32](02755),
+			expectedErr: ErrSpecialPermission,
+		},
+		{
+			name:        "sticky",
+			permission:  ptr.To[uint32](01755),
+			expectedErr: ErrSpecialPermission,
+		},
+		{
+			name:       "setuid opted in",
+			permission: ptr.To[uint32](04755),
+			opts:       Options{AllowSpecialBits: true},
+		},
+		{
+			name:        "special bits opted in can't exceed the maximum",
+			permission:  ptr.To[uint32](04777),
+			opts:        Options{AllowSpecialBits: true},
+			expectedErr: ErrPermissionTooBroad,
+		},
+		{
+			name:       "world-writable within the configured maximum",
+			permission: ptr.To[uint32](0666),
+			opts:       Options{MaxPermission: 0777},
+		},
+		{
+			name:        "exceeds the configured maximum",
+			permission:  ptr.To[uint32](0640),
+			opts:        Options{MaxPermission: 0600},
+			expectedErr: ErrPermissionTooBroad,
+		},
+		{
+			name:        "default permission exceeds the configured maximum",
+			permission:  nil,
+			opts:        Options{MaxPermission: 0600},
+			expectedErr: ErrPermissionTooBroad,
+		},
+		{
+			name:        "FileMode setuid bit is not the octal one",
+			permission:  ptr.To(uint32(fs.ModeSetuid | 0755)),
+			opts:        Options{AllowSpecialBits: true},
+			expectedErr: ErrInvalidPermission,
+		},
+		{
+			name:        "bits outside of the octal notation",
+			permission:  ptr.To[uint32](010644),
+			opts:        Options{AllowSpecialBits: true},
+			expectedErr: ErrInvalidPermission,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{
+				Filename:   "fooconf.json",
+				Create:     true,
+				Permission: tcase.permission,
+			}
+			gotErr := RequestWithOptions(spec, tcase.opts)
+			if gotErr != tcase.expectedErr {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestRollout(t *testing.T) {
+	type testCase struct {
+		name        string
+		rollout     *workshopv1alpha2.RolloutStrategy
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name: "not set",
+		},
+		{
+			name:    "defaults",
+			rollout: &workshopv1alpha2.RolloutStrategy{},
+		},
+		{
+			name: "good",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				MaxUnavailable:      ptr.To(intstr.FromString("25%")),
+				BatchSize:           ptr.To[int32](2),
+				PauseBetweenBatches: &metav1.Duration{Duration: time.Minute},
+				Paused:              true,
+			},
+		},
+		{
+			name:        "zero batch size",
+			rollout:     &workshopv1alpha2.RolloutStrategy{BatchSize: ptr.To[int32](0)},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name:        "negative pause",
+			rollout:     &workshopv1alpha2.RolloutStrategy{PauseBetweenBatches: &metav1.Duration{Duration: -time.Second}},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name:        "zero max unavailable",
+			rollout:     &workshopv1alpha2.RolloutStrategy{MaxUnavailable: ptr.To(intstr.FromInt32(0))},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name:        "zero percent max unavailable",
+			rollout:     &workshopv1alpha2.RolloutStrategy{MaxUnavailable: ptr.To(intstr.FromString("0%"))},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name:        "max unavailable over 100%",
+			rollout:     &workshopv1alpha2.RolloutStrategy{MaxUnavailable: ptr.To(intstr.FromString("150%"))},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name:        "malformed max unavailable",
+			rollout:     &workshopv1alpha2.RolloutStrategy{MaxUnavailable: ptr.To(intstr.FromString("half"))},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name: "good canary",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				Canary: &workshopv1alpha2.CanaryStrategy{
+					Nodes: ptr.To(intstr.FromString("10%")),
+					HealthGate: workshopv1alpha2.HealthGate{
+						HTTPGet: &workshopv1alpha2.HTTPGetGate{Path: "/healthz", Port: 8080, Scheme: "HTTPS"},
+						PodReadiness: &workshopv1alpha2.PodReadinessGate{
+							Namespace: "app",
+							Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+						},
+						Period: &metav1.Duration{Duration: 5 * time.Second},
+					},
+				},
+			},
+		},
+		{
+			name: "canary without checks",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				Canary: &workshopv1alpha2.CanaryStrategy{},
+			},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name: "zero canary nodes",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				Canary: &workshopv1alpha2.CanaryStrategy{
+					Nodes:      ptr.To(intstr.FromInt32(0)),
+					HealthGate: workshopv1alpha2.HealthGate{HTTPGet: &workshopv1alpha2.HTTPGetGate{Port: 8080}},
+				},
+			},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name: "canary probe port out of range",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				Canary: &workshopv1alpha2.CanaryStrategy{
+					HealthGate: workshopv1alpha2.HealthGate{HTTPGet: &workshopv1alpha2.HTTPGetGate{Port: 70000}},
+				},
+			},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name: "canary pods without namespace",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				Canary: &workshopv1alpha2.CanaryStrategy{
+					HealthGate: workshopv1alpha2.HealthGate{PodReadiness: &workshopv1alpha2.PodReadinessGate{}},
+				},
+			},
+			expectedErr: ErrInvalidRollout,
+		},
+		{
+			name: "canary zero period",
+			rollout: &workshopv1alpha2.RolloutStrategy{
+				Canary: &workshopv1alpha2.CanaryStrategy{
+					HealthGate: workshopv1alpha2.HealthGate{
+						HTTPGet: &workshopv1alpha2.HTTPGetGate{Port: 8080},
+						Period:  &metav1.Duration{},
+					},
+				},
+			},
+			expectedErr: ErrInvalidRollout,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{
+				Filename: "fooconf.json",
+				Create:   true,
+				Rollout:  tcase.rollout,
+			}
+			gotErr := Request(spec)
+			if !errors.Is(gotErr, tcase.expectedErr) {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	type testCase struct {
+		name        string
+		schedule    *workshopv1alpha2.MaintenanceSchedule
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name: "not set",
+		},
+		{
+			name: "good",
+			schedule: &workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{
+					{Start: "0 2 * * sat,sun", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+				},
+			},
+		},
+		{
+			name:        "no windows",
+			schedule:    &workshopv1alpha2.MaintenanceSchedule{},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name: "malformed start",
+			schedule: &workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{
+					{Start: "0 25 * * *", Duration: metav1.Duration{Duration: time.Hour}},
+				},
+			},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name: "unknown time zone",
+			schedule: &workshopv1alpha2.MaintenanceSchedule{
+				Windows: []workshopv1alpha2.MaintenanceWindow{
+					{Start: "@daily", Duration: metav1.Duration{Duration: time.Hour}},
+				},
+				TimeZone: "Nowhere/Town",
+			},
+			expectedErr: ErrInvalidSchedule,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{
+				Filename: "fooconf.json",
+				Create:   true,
+				Schedule: tcase.schedule,
+			}
+			gotErr := Request(spec)
+			if !errors.Is(gotErr, tcase.expectedErr) {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestClusterRequest(t *testing.T) {
+	type testCase struct {
+		name        string
+		spec        workshopv1alpha2.ConfigurationSpec
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:        "empty",
+			spec:        workshopv1alpha2.ConfigurationSpec{},
+			expectedErr: ErrMissingFilename,
+		},
+		{
+			name: "good",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "fooconf.json",
+				Create:   true,
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "namespaced area",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "namespaces/default/fooconf.json",
+				Create:   true,
+			},
+			expectedErr: ErrReservedFilename,
+		},
+		{
+			name: "namespaced area itself",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "namespaces",
+				Create:   true,
+			},
+			expectedErr: ErrReservedFilename,
+		},
+		{
+			name: "similar name is fine",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "namespaces.conf",
+				Create:   true,
+			},
+			expectedErr: nil,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			gotErr := ClusterRequest(tcase.spec)
+			if gotErr != tcase.expectedErr {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	type testCase struct {
+		name              string
+		namespace         string
+		filename          string
+		allowedNamespaces []string
+		policies          []workshopv1alpha2.ConfigurationPolicy
+		expectedErr       error
+	}
+
+	policies := []workshopv1alpha2.ConfigurationPolicy{
+		makePolicy("team-a", []string{"team-a"}, "*.conf", "conf.d/*.json"),
+		makePolicy("team-a-extra", []string{"team-a"}, "extra.ini"),
+		makePolicy("locked", []string{"team-c"}),
+	}
+
+	testCases := []testCase{
+		{
+			name:      "no restrictions",
+			namespace: "team-a",
+			filename:  "golab.conf",
+		},
+		{
+			name:              "allowed namespace",
+			namespace:         "team-a",
+			filename:          "golab.conf",
+			allowedNamespaces: []string{"team-a", "team-b"},
+		},
+		{
+			name:              "not allowed namespace",
+			namespace:         "team-z",
+			filename:          "golab.conf",
+			allowedNamespaces: []string{"team-a", "team-b"},
+			expectedErr:       ErrForbiddenNamespace,
+		},
+		{
+			name:      "path allowed by policy",
+			namespace: "team-a",
+			filename:  "conf.d/golab.json",
+			policies:  policies,
+		},
+		{
+			name:      "path allowed by another policy",
+			namespace: "team-a",
+			filename:  "extra.ini",
+			policies:  policies,
+		},
+		{
+			name:        "path not allowed by policy",
+			namespace:   "team-a",
+			filename:    "golab.json",
+			policies:    policies,
+			expectedErr: ErrForbiddenPath,
+		},
+		{
+			name:        "glob does not cross directories",
+			namespace:   "team-a",
+			filename:    "conf.d/golab.conf",
+			policies:    policies,
+			expectedErr: ErrForbiddenPath,
+		},
+		{
+			name:      "namespace not selected by policies",
+			namespace: "team-b",
+			filename:  "anything.json",
+			policies:  policies,
+		},
+		{
+			name:        "policy without paths",
+			namespace:   "team-c",
+			filename:    "golab.conf",
+			policies:    policies,
+			expectedErr: ErrForbiddenPath,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{
+				Filename: tcase.filename,
+				Create:   true,
+			}
+			gotErr := Namespace(tcase.namespace, spec, tcase.allowedNamespaces, tcase.policies)
+			if gotErr != tcase.expectedErr {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+			if IsForbidden(gotErr) != (tcase.expectedErr != nil) {
+				t.Errorf("unexpected forbidden error: %v", gotErr)
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyConstraints(t *testing.T) {
+	type testCase struct {
+		name        string
+		spec        workshopv1alpha2.ConfigurationSpec
+		expectedErr error
+	}
+
+	strict := makePolicy("strict", []string{"team-a"}, "*.json")
+	strict.Spec.MaxPermission = ptr.To[uint32](0644)
+	strict.Spec.MaxSize = ptr.To[int64](16)
+	strict.Spec.Formats = []workshopv1alpha2.ContentFormat{workshopv1alpha2.ContentFormatJSON}
+	yamlOnly := makePolicy("yaml-only", []string{"team-a"}, "*.yaml")
+	yamlOnly.Spec.Formats = []workshopv1alpha2.ContentFormat{workshopv1alpha2.ContentFormatYAML}
+	textOnly := makePolicy("text-only", []string{"team-a"}, "*.txt")
+	textOnly.Spec.Formats = []workshopv1alpha2.ContentFormat{workshopv1alpha2.ContentFormatText}
+	// overlaps strict: the files honoring either policy are allowed
+	relaxed := makePolicy("relaxed", []string{"team-a"}, "big*.json")
+	relaxed.Spec.MaxSize = ptr.To[int64](1024)
+	policies := []workshopv1alpha2.ConfigurationPolicy{strict, yamlOnly, textOnly, relaxed}
+
+	testCases := []testCase{
+		{
+			name: "honors the policy",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "foo.json",
+				Content:    `{"answer": 42}`,
+				Permission: ptr.To[uint32](0600),
+			},
+		},
+		{
+			name: "default permission",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.json",
+				Content:  `{}`,
+			},
+		},
+		{
+			name: "world-writable",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "foo.json",
+				Content:    `{}`,
+				Permission: ptr.To[uint32](0666),
+			},
+			expectedErr: ErrPolicyPermission,
+		},
+		{
+			name: "setuid",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "foo.json",
+				Content:    `{}`,
+				Permission: ptr.To[uint32](04644),
+			},
+			expectedErr: ErrPolicyPermission,
+		},
+		{
+			name: "too large",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.json",
+				Content:  `{"answer": "forty-two"}`,
+			},
+			expectedErr: ErrPolicyMaxSize,
+		},
+		{
+			name: "too large for a policy, fine for another",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "big.json",
+				Content:  `{"answer": "forty-two"}`,
+			},
+		},
+		{
+			name: "not JSON",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.json",
+				Content:  `answer=42`,
+			},
+			expectedErr: ErrPolicyFormat,
+		},
+		{
+			name: "YAML",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.yaml",
+				Content:  "answer: 42\n",
+			},
+		},
+		{
+			name: "not YAML",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.yaml",
+				Content:  "answer: [42\n",
+			},
+			expectedErr: ErrPolicyFormat,
+		},
+		{
+			name: "text",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.txt",
+				Content:  "answer=42\n",
+			},
+		},
+		{
+			name: "binary",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.txt",
+				Content:  "answer\x00\xff",
+			},
+			expectedErr: ErrPolicyFormat,
+		},
+		{
+			name: "path not matching any policy",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "foo.conf",
+				Content:  "answer=42\n",
+			},
+			expectedErr: ErrForbiddenPath,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			gotErr := Namespace("team-a", tcase.spec, nil, policies)
+			if !errors.Is(gotErr, tcase.expectedErr) || (gotErr != nil) != (tcase.expectedErr != nil) {
+				t.Fatalf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+			if IsPolicyViolation(gotErr) != (tcase.expectedErr != nil && tcase.expectedErr != ErrForbiddenPath) {
+				t.Fatalf("unexpected policy violation error: %v", gotErr)
+			}
+		})
+	}
+}
+
+func TestHealthGate(t *testing.T) {
+	type testCase struct {
+		name        string
+		gate        workshopv1alpha2.HealthGate
+		policies    []workshopv1alpha2.ConfigurationPolicy
+		expectedErr error
+	}
+
+	httpGate := &workshopv1alpha2.HTTPGetGate{Path: "/healthz", Port: 8080}
+	allowHTTP := makePolicy("allow-http", []string{"team-a"}, "*.conf")
+	allowHTTP.Spec.AllowHTTPHealthGates = true
+	allowHTTPOther := makePolicy("allow-http-other", []string{"team-b"}, "*.conf")
+	allowHTTPOther.Spec.AllowHTTPHealthGates = true
+
+	testCases := []testCase{
+		{
+			name: "pods of the namespace",
+			gate: workshopv1alpha2.HealthGate{PodReadiness: &workshopv1alpha2.PodReadinessGate{Namespace: "team-a"}},
+		},
+		{
+			name:        "pods of another namespace",
+			gate:        workshopv1alpha2.HealthGate{PodReadiness: &workshopv1alpha2.PodReadinessGate{Namespace: "kube-system"}},
+			expectedErr: ErrForbiddenHealthGate,
+		},
+		{
+			name:        "HTTP without policies",
+			gate:        workshopv1alpha2.HealthGate{HTTPGet: httpGate},
+			expectedErr: ErrForbiddenHealthGate,
+		},
+		{
+			name:     "HTTP allowed by policy",
+			gate:     workshopv1alpha2.HealthGate{HTTPGet: httpGate},
+			policies: []workshopv1alpha2.ConfigurationPolicy{allowHTTP},
+		},
+		{
+			name:        "HTTP allowed for another namespace",
+			gate:        workshopv1alpha2.HealthGate{HTTPGet: httpGate},
+			policies:    []workshopv1alpha2.ConfigurationPolicy{allowHTTPOther},
+			expectedErr: ErrForbiddenHealthGate,
+		},
+		{
+			name:        "HTTP not allowed by policy",
+			gate:        workshopv1alpha2.HealthGate{HTTPGet: httpGate},
+			policies:    []workshopv1alpha2.ConfigurationPolicy{makePolicy("team-a", []string{"team-a"}, "*.conf")},
+			expectedErr: ErrForbiddenHealthGate,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			spec := workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.conf",
+				Create:   true,
+				Rollout: &workshopv1alpha2.RolloutStrategy{
+					Canary: &workshopv1alpha2.CanaryStrategy{HealthGate: tcase.gate},
+				},
+			}
+			gotErr := Namespace("team-a", spec, nil, tcase.policies)
+			if !errors.Is(gotErr, tcase.expectedErr) || (gotErr == nil) != (tcase.expectedErr == nil) {
+				t.Errorf("unexpected error got=%v expected=%v", gotErr, tcase.expectedErr)
+			}
+			if IsForbidden(gotErr) != (tcase.expectedErr != nil) {
+				t.Errorf("unexpected forbidden error: %v", gotErr)
+			}
+		})
+	}
+}
+
+func makePolicy(name string, namespaces []string, paths ...string) workshopv1alpha2.ConfigurationPolicy {
+	return workshopv1alpha2.ConfigurationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: workshopv1alpha2.ConfigurationPolicySpec{
+			Namespaces: namespaces,
+			Paths:      paths,
+		},
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/validate"
+)
+
+// SetupClusterConfigurationWebhookWithManager registers the webhook for ClusterConfiguration in the manager.
+func SetupClusterConfigurationWebhookWithManager(mgr ctrl.Manager, opts validate.Options) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&workshopv1alpha2.ClusterConfiguration{}).
+		WithValidator(&ClusterConfigurationCustomValidator{
+			Options: opts,
+		}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-workshop-golab-io-v1alpha2-clusterconfiguration,mutating=false,failurePolicy=fail,sideEffects=None,groups=workshop.golab.io,resources=clusterconfigurations,verbs=create;update,versions=v1alpha2,name=vclusterconfiguration-v1alpha2.kb.io,admissionReviewVersions=v1
+
+// ClusterConfigurationCustomValidator rejects the ClusterConfigurations which are not valid.
+// No policy applies to the cluster-scoped objects.
+// The same checks are done by the reconciler, which can't reject the objects.
+type ClusterConfigurationCustomValidator struct {
+	// Options tunes the validation of the spec
+	Options validate.Options
+}
+
+var _ webhook.CustomValidator = &ClusterConfigurationCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *ClusterConfigurationCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	conf, ok := obj.(*workshopv1alpha2.ClusterConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterConfiguration object but got %T", obj)
+	}
+	return nil, v.validate(ctx, conf)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *ClusterConfigurationCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldConf, ok := oldObj.(*workshopv1alpha2.ClusterConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterConfiguration object for the old object but got %T", oldObj)
+	}
+	conf, ok := newObj.(*workshopv1alpha2.ClusterConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterConfiguration object for the new object but got %T", newObj)
+	}
+	// the objects which became invalid after a change of the validation flags must still
+	// be updatable, like it happens removing the finalizer on deletion
+	if !conf.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldConf.Spec, conf.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, conf)
+}
+
+// ValidateDelete implements webhook.CustomValidator. Deletion is always allowed.
+func (v *ClusterConfigurationCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ClusterConfigurationCustomValidator) validate(ctx context.Context, conf *workshopv1alpha2.ClusterConfiguration) error {
+	logf.FromContext(ctx).V(2).Info("validating cluster configuration", "name", conf.Name)
+
+	if err := validate.ClusterRequestWithOptions(conf.Spec, v.Options); err != nil {
+		return apierrors.NewInvalid(workshopv1alpha2.GroupVersion.WithKind("ClusterConfiguration").GroupKind(), conf.Name, field.ErrorList{
+			specFieldError(conf.Spec, err),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/validate"
+)
+
+func TestClusterConfigurationValidator(t *testing.T) {
+	type testCase struct {
+		name      string
+		opts      validate.Options
+		spec      workshopv1alpha2.ConfigurationSpec
+		isInvalid bool
+	}
+
+	testCases := []testCase{
+		{
+			name: "good",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.conf",
+				Content:  "answer=42",
+				Create:   true,
+			},
+		},
+		{
+			name: "missing filename",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Content: "answer=42",
+				Create:  true,
+			},
+			isInvalid: true,
+		},
+		{
+			name: "filename escaping the root",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "../golab.conf",
+				Content:  "answer=42",
+				Create:   true,
+			},
+			isInvalid: true,
+		},
+		{
+			name: "filename reserved to the namespaces",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "namespaces/team-a/golab.conf",
+				Content:  "answer=42",
+				Create:   true,
+			},
+			isInvalid: true,
+		},
+		{
+			name: "world-writable",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "golab.conf",
+				Content:    "answer=42",
+				Create:     true,
+				Permission: ptr.To[uint32](0666),
+			},
+			isInvalid: true,
+		},
+		{
+			name: "above the max permission",
+			opts: validate.Options{MaxPermission: 0644},
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "golab.conf",
+				Content:    "answer=42",
+				Create:     true,
+				Permission: ptr.To[uint32](0664),
+			},
+			isInvalid: true,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			validator := &ClusterConfigurationCustomValidator{Options: tcase.opts}
+			conf := &workshopv1alpha2.ClusterConfiguration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-config",
+				},
+				Spec: tcase.spec,
+			}
+			_, err := validator.ValidateCreate(context.Background(), conf)
+			if apierrors.IsInvalid(err) != tcase.isInvalid {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tcase.isInvalid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestClusterConfigurationValidatorUpdate(t *testing.T) {
+	validator := &ClusterConfigurationCustomValidator{}
+	oldConf := &workshopv1alpha2.ClusterConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename: "golab.conf",
+			Content:  "answer=42",
+			Create:   true,
+		},
+	}
+
+	conf := oldConf.DeepCopy()
+	conf.Spec.Content = "answer=43"
+	if _, err := validator.ValidateUpdate(context.Background(), oldConf, conf); err != nil {
+		t.Fatalf("unexpected error updating the spec: %v", err)
+	}
+
+	conf = oldConf.DeepCopy()
+	conf.Spec.Filename = "namespaces/team-a/golab.conf"
+	if _, err := validator.ValidateUpdate(context.Background(), oldConf, conf); !apierrors.IsInvalid(err) {
+		t.Fatalf("unexpected error updating the spec: %v", err)
+	}
+
+	conf.DeletionTimestamp = ptr.To(metav1.Now())
+	if _, err := validator.ValidateUpdate(context.Background(), oldConf, conf); err != nil {
+		t.Fatalf("unexpected error updating a deleted object: %v", err)
+	}
+
+	if _, err := validator.ValidateDelete(context.Background(), oldConf); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+}
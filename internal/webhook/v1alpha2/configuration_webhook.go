@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha2 sets up the webhooks for the v1alpha2 API.
+// The v1alpha2 API is the conversion hub, so its webhook serves
+// the conversion from and to all the other versions.
+package v1alpha2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/configfile"
+	"golab.io/kubedredger/internal/validate"
+)
+
+// SetupConfigurationWebhookWithManager registers the webhook for Configuration in the manager.
+// The Configurations in namespaces other than allowedNamespaces are rejected, unless it is empty.
+func SetupConfigurationWebhookWithManager(mgr ctrl.Manager, allowedNamespaces []string, opts validate.Options) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&workshopv1alpha2.Configuration{}).
+		WithValidator(&ConfigurationCustomValidator{
+			Client:            mgr.GetClient(),
+			AllowedNamespaces: allowedNamespaces,
+			Options:           opts,
+		}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-workshop-golab-io-v1alpha2-configuration,mutating=false,failurePolicy=fail,sideEffects=None,groups=workshop.golab.io,resources=configurations,verbs=create;update,versions=v1alpha2,name=vconfiguration-v1alpha2.kb.io,admissionReviewVersions=v1
+
+// ConfigurationCustomValidator rejects the Configurations which are not valid,
+// or which violate the configuration policies.
+// The same checks are done by the reconciler, which can't reject the objects.
+type ConfigurationCustomValidator struct {
+	// Client reads the configuration policies
+	Client client.Reader
+	// AllowedNamespaces are the only namespaces which can have Configurations. If empty, all namespaces can.
+	AllowedNamespaces []string
+	// Options tunes the validation of the spec
+	Options validate.Options
+}
+
+var _ webhook.CustomValidator = &ConfigurationCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *ConfigurationCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	conf, ok := obj.(*workshopv1alpha2.Configuration)
+	if !ok {
+		return nil, fmt.Errorf("expected a Configuration object but got %T", obj)
+	}
+	return nil, v.validate(ctx, conf)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *ConfigurationCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldConf, ok := oldObj.(*workshopv1alpha2.Configuration)
+	if !ok {
+		return nil, fmt.Errorf("expected a Configuration object for the old object but got %T", oldObj)
+	}
+	conf, ok := newObj.(*workshopv1alpha2.Configuration)
+	if !ok {
+		return nil, fmt.Errorf("expected a Configuration object for the new object but got %T", newObj)
+	}
+	// the objects which became non compliant after a policy change must still
+	// be updatable, like it happens removing the finalizer on deletion
+	if !conf.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldConf.Spec, conf.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, conf)
+}
+
+// ValidateDelete implements webhook.CustomValidator. Deletion is always allowed.
+func (v *ConfigurationCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ConfigurationCustomValidator) validate(ctx context.Context, conf *workshopv1alpha2.Configuration) error {
+	logf.FromContext(ctx).V(2).Info("validating configuration", "name", conf.Name, "namespace", conf.Namespace)
+
+	if err := validate.RequestWithOptions(conf.Spec, v.Options); err != nil {
+		return apierrors.NewInvalid(workshopv1alpha2.GroupVersion.WithKind("Configuration").GroupKind(), conf.Name, field.ErrorList{
+			specFieldError(conf.Spec, err),
+		})
+	}
+
+	policies := workshopv1alpha2.ConfigurationPolicyList{}
+	if err := v.Client.List(ctx, &policies); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to list the configuration policies: %w", err))
+	}
+	if err := validate.Namespace(conf.Namespace, conf.Spec, v.AllowedNamespaces, policies.Items); err != nil {
+		return apierrors.NewForbidden(workshopv1alpha2.GroupVersion.WithResource("configurations").GroupResource(), conf.Name, err)
+	}
+	return nil
+}
+
+// specFieldError describes the given validation error as an error of the field of the spec causing it.
+func specFieldError(spec workshopv1alpha2.ConfigurationSpec, err error) *field.Error {
+	specPath := field.NewPath("spec")
+	switch {
+	case errors.Is(err, validate.ErrMissingFilename):
+		return field.Required(specPath.Child("filename"), err.Error())
+	case errors.Is(err, validate.ErrInvalidFilename), errors.Is(err, validate.ErrReservedFilename), errors.Is(err, validate.ErrLockFilename):
+		return field.Invalid(specPath.Child("filename"), spec.Filename, err.Error())
+	case errors.Is(err, validate.ErrInvalidPermission), errors.Is(err, validate.ErrSpecialPermission), errors.Is(err, validate.ErrPermissionTooBroad):
+		perm := uint32(configfile.DefaultPermission)
+		if spec.Permission != nil {
+			perm = *spec.Permission
+		}
+		return field.Invalid(specPath.Child("permission"), fmt.Sprintf("%04o", perm), err.Error())
+	case errors.Is(err, validate.ErrInvalidMaxSize) && spec.MaxSize != nil:
+		return field.Invalid(specPath.Child("maxSize"), *spec.MaxSize, err.Error())
+	case errors.Is(err, validate.ErrInvalidRollout) && spec.Rollout != nil:
+		return field.Invalid(specPath.Child("rollout"), spec.Rollout, err.Error())
+	case errors.Is(err, validate.ErrInvalidSchedule) && spec.Schedule != nil:
+		return field.Invalid(specPath.Child("schedule"), spec.Schedule, err.Error())
+	default:
+		return field.Invalid(specPath, spec, err.Error())
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	workshopv1alpha1 "golab.io/kubedredger/api/v1alpha1"
+	workshopv1alpha2 "golab.io/kubedredger/api/v1alpha2"
+)
+
+func TestConfigurationIsConvertible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := workshopv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("cannot register v1alpha1 to scheme: %v", err)
+	}
+	if err := workshopv1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatalf("cannot register v1alpha2 to scheme: %v", err)
+	}
+	ok, err := conversion.IsConvertible(scheme, &workshopv1alpha2.Configuration{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("configuration is not convertible")
+	}
+}
+
+func TestConfigurationValidator(t *testing.T) {
+	type testCase struct {
+		name              string
+		allowedNamespaces []string
+		spec              workshopv1alpha2.ConfigurationSpec
+		isInvalid         bool
+		isForbidden       bool
+	}
+
+	testCases := []testCase{
+		{
+			name: "good",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.json",
+				Content:  "{}",
+				Create:   true,
+			},
+		},
+		{
+			name: "filename escaping the root",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "../golab.json",
+				Content:  "{}",
+				Create:   true,
+			},
+			isInvalid: true,
+		},
+		{
+			name: "world-writable",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "golab.json",
+				Content:    "{}",
+				Create:     true,
+				Permission: ptr.To[uint32](0666),
+			},
+			isInvalid: true,
+		},
+		{
+			name:              "namespace not allowed",
+			allowedNamespaces: []string{"team-b"},
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.json",
+				Content:  "{}",
+				Create:   true,
+			},
+			isForbidden: true,
+		},
+		{
+			name: "path not allowed",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.conf",
+				Content:  "{}",
+				Create:   true,
+			},
+			isForbidden: true,
+		},
+		{
+			name: "policy violation",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename:   "golab.json",
+				Content:    "{}",
+				Create:     true,
+				Permission: ptr.To[uint32](0664),
+			},
+			isForbidden: true,
+		},
+		{
+			name: "health gate on the pods of another namespace",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.json",
+				Content:  "{}",
+				Create:   true,
+				Rollout: &workshopv1alpha2.RolloutStrategy{
+					Canary: &workshopv1alpha2.CanaryStrategy{
+						HealthGate: workshopv1alpha2.HealthGate{
+							PodReadiness: &workshopv1alpha2.PodReadinessGate{Namespace: "kube-system"},
+						},
+					},
+				},
+			},
+			isForbidden: true,
+		},
+		{
+			name: "HTTP health gate",
+			spec: workshopv1alpha2.ConfigurationSpec{
+				Filename: "golab.json",
+				Content:  "{}",
+				Create:   true,
+				Rollout: &workshopv1alpha2.RolloutStrategy{
+					Canary: &workshopv1alpha2.CanaryStrategy{
+						HealthGate: workshopv1alpha2.HealthGate{
+							HTTPGet: &workshopv1alpha2.HTTPGetGate{Port: 8080},
+						},
+					},
+				},
+			},
+			isForbidden: true,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			validator := newTestValidator(t, tcase.allowedNamespaces)
+			conf := &workshopv1alpha2.Configuration{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "team-a",
+					Name:      "test-config",
+				},
+				Spec: tcase.spec,
+			}
+			_, err := validator.ValidateCreate(context.Background(), conf)
+			if apierrors.IsInvalid(err) != tcase.isInvalid || apierrors.IsForbidden(err) != tcase.isForbidden {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tcase.isInvalid && !tcase.isForbidden && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigurationValidatorUpdate(t *testing.T) {
+	validator := newTestValidator(t, nil)
+	// violates the policy, which was created after the object
+	oldConf := &workshopv1alpha2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Name:      "test-config",
+		},
+		Spec: workshopv1alpha2.ConfigurationSpec{
+			Filename: "golab.json",
+			Content:  "answer=42",
+			Create:   true,
+		},
+	}
+
+	conf := oldConf.DeepCopy()
+	conf.Finalizers = []string{"workshop.golab.io/test"}
+	if _, err := validator.ValidateUpdate(context.Background(), oldConf, conf); err != nil {
+		t.Fatalf("unexpected error updating the metadata: %v", err)
+	}
+
+	conf = oldConf.DeepCopy()
+	conf.Spec.Content = "answer=43"
+	if _, err := validator.ValidateUpdate(context.Background(), oldConf, conf); !apierrors.IsForbidden(err) {
+		t.Fatalf("unexpected error updating the spec: %v", err)
+	}
+
+	conf.DeletionTimestamp = ptr.To(metav1.Now())
+	if _, err := validator.ValidateUpdate(context.Background(), oldConf, conf); err != nil {
+		t.Fatalf("unexpected error updating a deleted object: %v", err)
+	}
+
+	if _, err := validator.ValidateDelete(context.Background(), oldConf); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+}
+
+func newTestValidator(t *testing.T, allowedNamespaces []string) *ConfigurationCustomValidator {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := workshopv1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatalf("cannot register v1alpha2 to scheme: %v", err)
+	}
+	policy := &workshopv1alpha2.ConfigurationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+		},
+		Spec: workshopv1alpha2.ConfigurationPolicySpec{
+			Namespaces:    []string{"team-a"},
+			Paths:         []string{"*.json"},
+			MaxPermission: ptr.To[uint32](0644),
+			Formats:       []workshopv1alpha2.ContentFormat{workshopv1alpha2.ContentFormatJSON},
+		},
+	}
+	return &ConfigurationCustomValidator{
+		Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(policy).Build(),
+		AllowedNamespaces: allowedNamespaces,
+	}
+}
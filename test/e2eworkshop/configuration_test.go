@@ -15,12 +15,13 @@ import (
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"golab.io/kubedredger/api/v1alpha1"
+	"golab.io/kubedredger/api/v1alpha2"
+	"golab.io/kubedredger/internal/contenthash"
 )
 
 var _ = ginkgo.Describe("Configuration E2E", func() {
 	var (
-		configuration *v1alpha1.Configuration
+		configuration *v1alpha2.Configuration
 		testNamespace string
 		confRoot      string
 		confName      string
@@ -31,12 +32,12 @@ var _ = ginkgo.Describe("Configuration E2E", func() {
 		confRoot = "/tmp/config.d"
 		confName = "kubedredger.conf"
 
-		configuration = &v1alpha1.Configuration{
+		configuration = &v1alpha2.Configuration{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "test-config",
 				Namespace: testNamespace,
 			},
-			Spec: v1alpha1.ConfigurationSpec{
+			Spec: v1alpha2.ConfigurationSpec{
 				Filename: confName,
 				Content:  "test content for e2e",
 				Create:   true,
@@ -66,13 +67,8 @@ var _ = ginkgo.Describe("Configuration E2E", func() {
 		Expect(cl.Create(ctx, configuration)).To(Succeed())
 
 		ginkgo.By("waiting for the configuration to be processed")
-		Eventually(func() bool {
-			err := cl.Get(ctx, client.ObjectKeyFromObject(configuration), configuration)
-			if err != nil {
-				return false
-			}
-			return configuration.Status.LastUpdated.Time.After(time.Time{})
-		}).WithTimeout(time.Minute).WithPolling(time.Second).Should(BeTrue())
+		waitForObservedGeneration(ctx, configuration)
+		Expect(configuration.Status.Nodes).NotTo(BeEmpty())
 
 		ginkgo.By("verifying the configuration status")
 		Eventually(func() bool {
@@ -88,8 +84,8 @@ var _ = ginkgo.Describe("Configuration E2E", func() {
 			if err != nil {
 				return ""
 			}
-			return configuration.Status.Content
-		}).WithTimeout(time.Minute).WithPolling(time.Second).Should(Equal("test content for e2e"))
+			return configuration.Status.ContentHash
+		}).WithTimeout(time.Minute).WithPolling(time.Second).Should(Equal(contenthash.Sum([]byte("test content for e2e"))))
 
 		ginkgo.By("verifying the file in the kind container is created")
 		Eventually(func() bool {
@@ -100,7 +96,7 @@ var _ = ginkgo.Describe("Configuration E2E", func() {
 			return configuration.Status.FileExists
 		}).WithTimeout(time.Minute).WithPolling(time.Second).Should(BeTrue())
 
-		confPath := filepath.Join(confRoot, confName)
+		confPath := filepath.Join(confRoot, "namespaces", testNamespace, confName)
 		ginkgo.By("verifying the file content in the kind container using docker: " + confPath)
 		Eventually(func() string {
 			cmd := exec.Command("docker", "exec", "kubedredger-kind-control-plane", "cat", confPath)
@@ -112,3 +108,28 @@ var _ = ginkgo.Describe("Configuration E2E", func() {
 		}).WithTimeout(time.Minute).WithPolling(time.Second).Should(Equal("test content for e2e"))
 	})
 })
+
+// waitForObservedGeneration waits until the status of the given configuration, and all its
+// conditions, refer to the current generation of the object. Updates the object in place.
+func waitForObservedGeneration(ctx context.Context, conf *v1alpha2.Configuration) {
+	ginkgo.GinkgoHelper()
+	Eventually(func() bool {
+		err := cl.Get(ctx, client.ObjectKeyFromObject(conf), conf)
+		if err != nil {
+			return false
+		}
+		return isObserved(conf)
+	}).WithTimeout(time.Minute).WithPolling(time.Second).Should(BeTrue(), "generation %d not observed", conf.Generation)
+}
+
+func isObserved(conf *v1alpha2.Configuration) bool {
+	if conf.Status.ObservedGeneration != conf.Generation || len(conf.Status.Conditions) == 0 {
+		return false
+	}
+	for _, cond := range conf.Status.Conditions {
+		if cond.ObservedGeneration != conf.Generation {
+			return false
+		}
+	}
+	return true
+}
@@ -5,7 +5,7 @@ import (
 
 	"github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"golab.io/kubedredger/api/v1alpha1"
+	"golab.io/kubedredger/api/v1alpha2"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -38,7 +38,7 @@ var _ = ginkgo.AfterSuite(func() {
 func newClient() (client.Client, error) {
 	myScheme := runtime.NewScheme()
 
-	if err := v1alpha1.AddToScheme(myScheme); err != nil {
+	if err := v1alpha2.AddToScheme(myScheme); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,75 @@
+package e2e
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"golab.io/kubedredger/api/v1alpha2"
+)
+
+var _ = ginkgo.Describe("Rollout E2E", func() {
+	var configuration *v1alpha2.Configuration
+
+	ginkgo.BeforeEach(func() {
+		configuration = &v1alpha2.Configuration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-rollout",
+				Namespace: "golab-kubedredger",
+			},
+			Spec: v1alpha2.ConfigurationSpec{
+				Filename: "rollout.conf",
+				Content:  "test content for the rollout e2e",
+				Create:   true,
+				Rollout: &v1alpha2.RolloutStrategy{
+					MaxUnavailable: ptr.To(intstr.FromInt32(1)),
+				},
+			},
+		}
+	})
+
+	ginkgo.AfterEach(func() {
+		ctx := context.Background()
+		_ = cl.Delete(ctx, configuration)
+
+		ginkgo.By("ensuring configuration is removed")
+		Eventually(func() bool {
+			err := cl.Get(ctx, client.ObjectKeyFromObject(configuration), configuration)
+			return apierrors.IsNotFound(err)
+		}, time.Minute, time.Second).Should(BeTrue())
+	})
+
+	ginkgo.It("should roll out the configuration to all the nodes running an agent", func() {
+		ctx := context.Background()
+
+		ginkgo.By("creating the configuration")
+		Expect(cl.Create(ctx, configuration)).To(Succeed())
+
+		ginkgo.By("waiting for the rollout to complete")
+		Eventually(func() v1alpha2.RolloutPhase {
+			err := cl.Get(ctx, client.ObjectKeyFromObject(configuration), configuration)
+			if err != nil || configuration.Status.Rollout == nil {
+				return ""
+			}
+			return configuration.Status.Rollout.Phase
+		}).WithTimeout(2 * time.Minute).WithPolling(time.Second).Should(Equal(v1alpha2.RolloutPhaseCompleted))
+
+		rollout := configuration.Status.Rollout
+		Expect(rollout.TargetNodes).To(BeNumerically(">", 0), "no node targeted by the rollout")
+		Expect(rollout.UpdatedNodes).To(Equal(rollout.TargetNodes))
+		Expect(configuration.Status.Nodes).To(HaveLen(int(rollout.TargetNodes)))
+		for _, node := range configuration.Status.Nodes {
+			Expect(node.Revision).To(Equal(rollout.Revision), "node %q not updated", node.Name)
+			Expect(node.Error).To(BeEmpty(), "node %q failed", node.Name)
+		}
+	})
+})